@@ -12,6 +12,8 @@ import (
 type Node interface {
 	TokenLiteral() string
 	String() string
+	// Pos is the position of the start of the node
+	Pos() token.Position
 }
 
 // All statement nodes implement this
@@ -28,6 +30,9 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+	// Comments holds every comment in the source, in order. They are kept apart from the
+	// statements, so that they can appear anywhere, e.g. within an argument list.
+	Comments []*Comment
 }
 
 func (p *Program) TokenLiteral() string {
@@ -38,6 +43,21 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
+// startPos is the position of a node which begins with a sub-expression, e.g. the left side of an infix expression
+func startPos(n Node, tok token.Token) token.Position {
+	if n == nil {
+		return tok.Pos()
+	}
+	return n.Pos()
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -58,13 +78,14 @@ type AssignStatement struct {
 
 func (ls *AssignStatement) statementNode()       {}
 func (ls *AssignStatement) TokenLiteral() string { return ls.Token.Literal }
+func (ls *AssignStatement) Pos() token.Position  { return ls.Token.Pos() }
 func (ls *AssignStatement) String() string {
 	var out bytes.Buffer
 
 	if ls.Token.Type == token.VAR {
 		out.WriteString("var ")
 	}
-	out.WriteString(ls.Name.String())
+	out.WriteString(ls.Name.Declaration())
 	out.WriteString(" = ")
 
 	if ls.Value != nil {
@@ -83,6 +104,7 @@ type ReturnStatement struct {
 
 func (rs *ReturnStatement) statementNode()       {}
 func (rs *ReturnStatement) TokenLiteral() string { return rs.Token.Literal }
+func (rs *ReturnStatement) Pos() token.Position  { return rs.Token.Pos() }
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
 
@@ -136,6 +158,7 @@ type ExpressionStatement struct {
 
 func (es *ExpressionStatement) statementNode()       {}
 func (es *ExpressionStatement) TokenLiteral() string { return es.Token.Literal }
+func (es *ExpressionStatement) Pos() token.Position  { return es.Token.Pos() }
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -146,10 +169,12 @@ func (es *ExpressionStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Token // the closing } token
 }
 
 func (bs *BlockStatement) statementNode()       {}
 func (bs *BlockStatement) TokenLiteral() string { return bs.Token.Literal }
+func (bs *BlockStatement) Pos() token.Position  { return bs.Token.Pos() }
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -165,12 +190,52 @@ func (bs *BlockStatement) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+	Type  *TypeAnnotation // optional, for variable and parameter declarations
+	// Default and Variadic are only used for function parameters
+	Default  Expression // optional, e.g. the `10` in `fn(n = 10)`
+	Variadic bool       // collects any remaining arguments into an array, e.g. `fn(...rest)`
 }
 
 func (i *Identifier) expressionNode()      {}
 func (i *Identifier) TokenLiteral() string { return i.Token.Literal }
+func (i *Identifier) Pos() token.Position  { return i.Token.Pos() }
 func (i *Identifier) String() string       { return i.Value }
 
+// Declaration includes the type annotation and parameter details, if any
+func (i *Identifier) Declaration() string {
+	d := i.Value
+	if i.Variadic {
+		d = "..." + d
+	}
+	if i.Type != nil {
+		d += ": " + i.Type.String()
+	}
+	if i.Default != nil {
+		d += " = " + i.Default.String()
+	}
+	return d
+}
+
+// TypeAnnotation is an optional type, such as the `string` in `var x: string = "a"`
+type TypeAnnotation struct {
+	Token token.Token // the type name
+	Name  string
+}
+
+func (ta *TypeAnnotation) TokenLiteral() string { return ta.Token.Literal }
+func (ta *TypeAnnotation) Pos() token.Position  { return ta.Token.Pos() }
+func (ta *TypeAnnotation) String() string       { return ta.Name }
+
+// TypeNames maps the names which may be used in type annotations to the type of value they allow
+var TypeNames = map[string]string{
+	"string": "STRING",
+	"int":    "INTEGER",
+	"bool":   "BOOLEAN",
+	"array":  "ARRAY",
+	"hash":   "HASH",
+	"fn":     "FUNCTION",
+}
+
 type Boolean struct {
 	Token token.Token
 	Value bool
@@ -178,6 +243,7 @@ type Boolean struct {
 
 func (b *Boolean) expressionNode()      {}
 func (b *Boolean) TokenLiteral() string { return b.Token.Literal }
+func (b *Boolean) Pos() token.Position  { return b.Token.Pos() }
 func (b *Boolean) String() string       { return b.Token.Literal }
 
 type IntegerLiteral struct {
@@ -187,6 +253,7 @@ type IntegerLiteral struct {
 
 func (il *IntegerLiteral) expressionNode()      {}
 func (il *IntegerLiteral) TokenLiteral() string { return il.Token.Literal }
+func (il *IntegerLiteral) Pos() token.Position  { return il.Token.Pos() }
 func (il *IntegerLiteral) String() string       { return il.Token.Literal }
 
 type PrefixExpression struct {
@@ -197,6 +264,7 @@ type PrefixExpression struct {
 
 func (pe *PrefixExpression) expressionNode()      {}
 func (pe *PrefixExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PrefixExpression) Pos() token.Position  { return pe.Token.Pos() }
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 
@@ -217,6 +285,7 @@ type InfixExpression struct {
 
 func (oe *InfixExpression) expressionNode()      {}
 func (oe *InfixExpression) TokenLiteral() string { return oe.Token.Literal }
+func (oe *InfixExpression) Pos() token.Position  { return startPos(oe.Left, oe.Token) }
 func (oe *InfixExpression) String() string {
 	var out bytes.Buffer
 
@@ -238,6 +307,7 @@ type IfExpression struct {
 
 func (ie *IfExpression) expressionNode()      {}
 func (ie *IfExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IfExpression) Pos() token.Position  { return ie.Token.Pos() }
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 
@@ -267,6 +337,7 @@ type ForExpression struct {
 
 func (ie *ForExpression) expressionNode()      {}
 func (ie *ForExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *ForExpression) Pos() token.Position  { return ie.Token.Pos() }
 func (ie *ForExpression) String() string {
 	var out bytes.Buffer
 
@@ -293,6 +364,7 @@ type RangeExpression struct {
 
 func (ie *RangeExpression) expressionNode()      {}
 func (ie *RangeExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *RangeExpression) Pos() token.Position  { return ie.Token.Pos() }
 func (ie *RangeExpression) String() string {
 	var out bytes.Buffer
 
@@ -314,15 +386,23 @@ func (ie *RangeExpression) String() string {
 type PipeExpression struct {
 	Token       token.Token // The '|' token
 	Destination *CallExpression
+	// Target is what follows the '|' when it is not a call, in which case Destination is
+	// nil and the pipe does nothing
+	Target Expression
 }
 
 func (pe *PipeExpression) expressionNode()      {}
 func (pe *PipeExpression) TokenLiteral() string { return pe.Token.Literal }
+func (pe *PipeExpression) Pos() token.Position  { return pe.Token.Pos() }
 func (pe *PipeExpression) String() string {
 	var out bytes.Buffer
 
 	out.WriteString("|")
-	out.WriteString(pe.Destination.String())
+	if pe.Destination != nil {
+		out.WriteString(pe.Destination.String())
+	} else if pe.Target != nil {
+		out.WriteString(pe.Target.String())
+	}
 
 	return out.String()
 }
@@ -330,23 +410,29 @@ func (pe *PipeExpression) String() string {
 type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
+	ReturnType *TypeAnnotation // optional
 	Body       *BlockStatement
 }
 
 func (fl *FunctionLiteral) expressionNode()      {}
 func (fl *FunctionLiteral) TokenLiteral() string { return fl.Token.Literal }
+func (fl *FunctionLiteral) Pos() token.Position  { return fl.Token.Pos() }
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 
 	params := []string{}
 	for _, p := range fl.Parameters {
-		params = append(params, p.String())
+		params = append(params, p.Declaration())
 	}
 
 	out.WriteString(fl.TokenLiteral())
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(")")
+	if fl.ReturnType != nil {
+		out.WriteString(": " + fl.ReturnType.String())
+	}
+	out.WriteString(" {\n")
 	out.WriteString(fl.Body.String())
 	out.WriteString("}\n")
 	return out.String()
@@ -356,12 +442,14 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Token // The closing ')' token
 	Out       *Pipes
 	In        *Pipes
 }
 
 func (ce *CallExpression) expressionNode()      {}
 func (ce *CallExpression) TokenLiteral() string { return ce.Token.Literal }
+func (ce *CallExpression) Pos() token.Position  { return startPos(ce.Function, ce.Token) }
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -378,6 +466,29 @@ func (ce *CallExpression) String() string {
 	return out.String()
 }
 
+// NamedArgument passes a flag to a builtin by name, e.g. `head(n: 10)` or `ls(long: true)`
+type NamedArgument struct {
+	Token token.Token // the name
+	Name  *Identifier
+	Value Expression
+}
+
+func (na *NamedArgument) expressionNode()      {}
+func (na *NamedArgument) TokenLiteral() string { return na.Token.Literal }
+func (na *NamedArgument) Pos() token.Position  { return na.Token.Pos() }
+func (na *NamedArgument) String() string       { return na.Name.String() + ": " + na.Value.String() }
+
+// SpreadExpression expands an array into call arguments or array elements, e.g. `ls(...paths)`
+type SpreadExpression struct {
+	Token token.Token // the '...' token
+	Value Expression
+}
+
+func (se *SpreadExpression) expressionNode()      {}
+func (se *SpreadExpression) TokenLiteral() string { return se.Token.Literal }
+func (se *SpreadExpression) Pos() token.Position  { return se.Token.Pos() }
+func (se *SpreadExpression) String() string       { return "..." + se.Value.String() }
+
 type StringLiteral struct {
 	Token token.Token
 	Value string
@@ -385,6 +496,7 @@ type StringLiteral struct {
 
 func (sl *StringLiteral) expressionNode()      {}
 func (sl *StringLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *StringLiteral) Pos() token.Position  { return sl.Token.Pos() }
 func (sl *StringLiteral) String() string       { return "\"" + sl.Token.Literal + "\"" }
 
 type BacktickLiteral struct {
@@ -394,24 +506,28 @@ type BacktickLiteral struct {
 
 func (sl *BacktickLiteral) expressionNode()      {}
 func (sl *BacktickLiteral) TokenLiteral() string { return sl.Token.Literal }
+func (sl *BacktickLiteral) Pos() token.Position  { return sl.Token.Pos() }
 func (sl *BacktickLiteral) String() string       { return "`" + sl.Token.Literal + "`" }
 
-type CommentLiteral struct {
-	Token token.Token
-	Value string
+// Comment is a `#` comment, running to the end of the line
+type Comment struct {
+	Token token.Token // the '#' token, whose literal is the rest of the line
 }
 
-func (sl *CommentLiteral) expressionNode()      {}
-func (sl *CommentLiteral) TokenLiteral() string { return sl.Token.Literal }
-func (sl *CommentLiteral) String() string       { return sl.Token.Literal + "\n" }
+func (c *Comment) Pos() token.Position { return c.Token.Pos() }
+
+// Text returns the comment including its leading '#', without any trailing whitespace
+func (c *Comment) Text() string { return strings.TrimRight("#"+c.Token.Literal, " \t\r") }
 
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Token // the closing ']' token
 }
 
 func (al *ArrayLiteral) expressionNode()      {}
 func (al *ArrayLiteral) TokenLiteral() string { return al.Token.Literal }
+func (al *ArrayLiteral) Pos() token.Position  { return al.Token.Pos() }
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -428,13 +544,15 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Token // The closing ] token
 }
 
 func (ie *IndexExpression) expressionNode()      {}
 func (ie *IndexExpression) TokenLiteral() string { return ie.Token.Literal }
+func (ie *IndexExpression) Pos() token.Position  { return startPos(ie.Left, ie.Token) }
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -448,12 +566,15 @@ func (ie *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Keys   []Expression // the keys of Pairs, in source order
+	Rbrace token.Token  // the closing '}' token
 }
 
 func (hl *HashLiteral) expressionNode()      {}
 func (hl *HashLiteral) TokenLiteral() string { return hl.Token.Literal }
+func (hl *HashLiteral) Pos() token.Position  { return hl.Token.Pos() }
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -477,6 +598,7 @@ type MacroLiteral struct {
 
 func (ml *MacroLiteral) expressionNode()      {}
 func (ml *MacroLiteral) TokenLiteral() string { return ml.Token.Literal }
+func (ml *MacroLiteral) Pos() token.Position  { return ml.Token.Pos() }
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
 
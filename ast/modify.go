@@ -55,14 +55,34 @@ func Modify(node Node, modifier ModifierFunc) Node {
 
 	case *HashLiteral:
 		newPairs := make(map[Expression]Expression)
+		newKeys := []Expression{}
+		for _, key := range node.Keys {
+			newKey, _ := Modify(key, modifier).(Expression)
+			newPairs[newKey], _ = Modify(node.Pairs[key], modifier).(Expression)
+			newKeys = append(newKeys, newKey)
+		}
 		for key, val := range node.Pairs {
+			if containsKey(node.Keys, key) {
+				continue
+			}
 			newKey, _ := Modify(key, modifier).(Expression)
 			newVal, _ := Modify(val, modifier).(Expression)
 			newPairs[newKey] = newVal
+			newKeys = append(newKeys, newKey)
 		}
 		node.Pairs = newPairs
+		node.Keys = newKeys
 
 	}
 
 	return modifier(node)
 }
+
+func containsKey(keys []Expression, key Expression) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,127 @@
+package ast
+
+// Inspect traverses an AST in depth-first order, calling f for each node before its
+// children. The children are skipped if f returns false.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+	switch node := node.(type) {
+
+	case *Program:
+		inspectStatements(node.Statements, f)
+
+	case *ExpressionStatement:
+		inspectExpression(node.Expression, f)
+
+	case *AssignStatement:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+
+	case *ReturnStatement:
+		inspectExpression(node.ReturnValue, f)
+
+	case *BlockStatement:
+		inspectStatements(node.Statements, f)
+
+	case *PrefixExpression:
+		inspectExpression(node.Right, f)
+
+	case *InfixExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Right, f)
+
+	case *IfExpression:
+		inspectExpression(node.Condition, f)
+		inspectBlock(node.Consequence, f)
+		inspectBlock(node.Alternative, f)
+
+	case *ForExpression:
+		inspectStatement(node.Init, f)
+		inspectExpression(node.Condition, f)
+		inspectStatement(node.After, f)
+		inspectBlock(node.Body, f)
+
+	case *RangeExpression:
+		inspectExpression(node.Identifier, f)
+		inspectExpression(node.Iteree, f)
+		inspectExpression(node.Iterator, f)
+		inspectBlock(node.Body, f)
+
+	case *FunctionLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		inspectBlock(node.Body, f)
+
+	case *MacroLiteral:
+		for _, param := range node.Parameters {
+			Inspect(param, f)
+		}
+		inspectBlock(node.Body, f)
+
+	case *Identifier:
+		inspectExpression(node.Default, f)
+
+	case *CallExpression:
+		inspectExpression(node.Function, f)
+		for _, arg := range node.Arguments {
+			inspectExpression(arg, f)
+		}
+
+	case *NamedArgument:
+		Inspect(node.Name, f)
+		inspectExpression(node.Value, f)
+
+	case *SpreadExpression:
+		inspectExpression(node.Value, f)
+
+	case *PipeExpression:
+		if node.Destination != nil {
+			Inspect(node.Destination, f)
+		}
+		inspectExpression(node.Target, f)
+
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			inspectExpression(el, f)
+		}
+
+	case *HashLiteral:
+		for _, key := range node.Keys {
+			inspectExpression(key, f)
+			inspectExpression(node.Pairs[key], f)
+		}
+
+	case *IndexExpression:
+		inspectExpression(node.Left, f)
+		inspectExpression(node.Index, f)
+	}
+}
+
+func inspectStatements(statements []Statement, f func(Node) bool) {
+	for _, statement := range statements {
+		inspectStatement(statement, f)
+	}
+}
+
+// inspectStatement, inspectExpression and inspectBlock skip missing nodes, which would
+// otherwise be passed to Inspect as non-nil interfaces holding nil pointers
+
+func inspectStatement(statement Statement, f func(Node) bool) {
+	if statement != nil {
+		Inspect(statement, f)
+	}
+}
+
+func inspectExpression(exp Expression, f func(Node) bool) {
+	if exp != nil {
+		Inspect(exp, f)
+	}
+}
+
+func inspectBlock(block *BlockStatement, f func(Node) bool) {
+	if block != nil {
+		Inspect(block, f)
+	}
+}
@@ -0,0 +1,50 @@
+package ast
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	program := &Program{
+		Statements: []Statement{
+			&AssignStatement{
+				Name: &Identifier{Value: "f"},
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "a", Default: &IntegerLiteral{Value: 1}}},
+					Body: &BlockStatement{Statements: []Statement{
+						&ExpressionStatement{Expression: &InfixExpression{
+							Left:     &Identifier{Value: "a"},
+							Operator: "+",
+							Right:    &IntegerLiteral{Value: 2},
+						}},
+					}},
+				},
+			},
+			&ExpressionStatement{Expression: &CallExpression{
+				Function:  &Identifier{Value: "f"},
+				Arguments: []Expression{&ArrayLiteral{Elements: []Expression{&IntegerLiteral{Value: 3}}}},
+			}},
+			&ExpressionStatement{Expression: &PipeExpression{Target: &Identifier{Value: "x"}}},
+		},
+	}
+
+	visited := []string{}
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			visited = append(visited, node.Value)
+		case *IntegerLiteral:
+			visited = append(visited, strconv.FormatInt(node.Value, 10))
+		case *ArrayLiteral:
+			// skip the elements
+			return false
+		}
+		return true
+	})
+	expected := []string{"f", "a", "1", "a", "2", "f", "x"}
+	if !reflect.DeepEqual(visited, expected) {
+		t.Errorf("expected %v, got %v", expected, visited)
+	}
+}
@@ -0,0 +1,556 @@
+// Package checker performs static analysis of a smoosh program, without evaluating it.
+//
+// It infers the types of variables from the values assigned to them, and reports
+// undefined identifiers, type mismatches, calls with the wrong number of arguments,
+// misused flags and unreachable code.
+package checker
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+	"github.com/laher/smoosh/token"
+)
+
+// Error is a problem found by the checker
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// unknown is the type of anything which can't be inferred statically (e.g. the result of a builtin)
+const unknown = object.ObjectType("")
+
+type symbol struct {
+	typ   object.ObjectType
+	fn    *ast.FunctionLiteral // set when the symbol is bound to a function literal
+	macro bool
+}
+
+type scope struct {
+	symbols map[string]*symbol
+	outer   *scope
+	fn      *ast.FunctionLiteral // set for the scope of a function body
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{symbols: map[string]*symbol{}, outer: outer}
+}
+
+func (s *scope) get(name string) (*symbol, bool) {
+	for ; s != nil; s = s.outer {
+		if sym, ok := s.symbols[name]; ok {
+			return sym, true
+		}
+	}
+	return nil, false
+}
+
+// function returns the function whose body encloses this scope, if any
+func (s *scope) function() *ast.FunctionLiteral {
+	for ; s != nil; s = s.outer {
+		if s.fn != nil {
+			return s.fn
+		}
+	}
+	return nil
+}
+
+// annotated returns the type allowed by an annotation, or unknown if there isn't one
+func annotated(ta *ast.TypeAnnotation) object.ObjectType {
+	if ta == nil {
+		return unknown
+	}
+	return object.AnnotatedType(ta)
+}
+
+type checker struct {
+	env    *object.Environment
+	errors []*Error
+	// function bodies are checked once their enclosing block is done, because they may
+	// refer to variables which are assigned after the function is defined
+	pending []func()
+}
+
+// Check checks a program. env supplies any variables which are already defined, and
+// per-interpreter builtins. It may be nil. Errors are sorted by position.
+func Check(program *ast.Program, env *object.Environment) []*Error {
+	c := &checker{env: env}
+	c.checkStatements(program.Statements, newScope(nil))
+	for len(c.pending) > 0 {
+		next := c.pending[0]
+		c.pending = c.pending[1:]
+		next()
+	}
+	sort.SliceStable(c.errors, func(i, j int) bool {
+		a, b := c.errors[i].Pos, c.errors[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return c.errors
+}
+
+func (c *checker) errorf(pos token.Position, format string, a ...interface{}) {
+	c.errors = append(c.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
+func (c *checker) checkStatements(statements []ast.Statement, s *scope) {
+	returned := false
+	for _, statement := range statements {
+		if returned {
+			c.errorf(statement.Pos(), "unreachable code")
+			return
+		}
+		c.checkStatement(statement, s)
+		if _, ok := statement.(*ast.ReturnStatement); ok {
+			returned = true
+		}
+	}
+}
+
+func (c *checker) checkStatement(statement ast.Statement, s *scope) {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		c.checkExpression(statement.Expression, s)
+	case *ast.ReturnStatement:
+		typ := c.checkExpression(statement.ReturnValue, s)
+		if fn := s.function(); fn != nil {
+			if expected := annotated(fn.ReturnType); expected != unknown && typ != unknown && typ != expected {
+				c.errorf(statement.Pos(), "cannot return %s from a function returning %s", typ, expected)
+			}
+		}
+	case *ast.BlockStatement:
+		c.checkStatements(statement.Statements, s)
+	case *ast.AssignStatement:
+		c.checkAssign(statement, s)
+	}
+}
+
+func (c *checker) checkAssign(statement *ast.AssignStatement, s *scope) {
+	name := statement.Name.Value
+	prev, defined := c.lookupVar(name, s)
+	sym := &symbol{}
+	// define functions before checking them, so that they can be recursive
+	switch v := statement.Value.(type) {
+	case *ast.FunctionLiteral:
+		sym.fn = v
+		sym.typ = object.FUNCTION_OBJ
+		s.symbols[name] = sym
+	case *ast.MacroLiteral:
+		sym.macro = true
+		sym.typ = object.MACRO_OBJ
+	}
+	typ := c.checkExpression(statement.Value, s)
+	if declared := annotated(statement.Name.Type); declared != unknown {
+		if typ != unknown && typ != declared {
+			c.errorf(statement.Name.Pos(), "cannot assign %s to '%s' (type %s)", typ, name, declared)
+		}
+		typ = declared
+	}
+	if defined && prev.typ != unknown && typ != unknown && prev.typ != typ {
+		c.errorf(statement.Name.Pos(), "cannot assign %s to '%s' (type %s)", typ, name, prev.typ)
+		// keep the declared type, as the evaluator does
+		typ = prev.typ
+	}
+	sym.typ = typ
+	s.symbols[name] = sym
+}
+
+// lookupVar looks for a variable in scope, falling back to the environment
+func (c *checker) lookupVar(name string, s *scope) (*symbol, bool) {
+	if sym, ok := s.get(name); ok {
+		return sym, true
+	}
+	if c.env != nil {
+		if obj, ok := c.env.Get(name); ok {
+			return &symbol{typ: obj.Type(), macro: obj.Type() == object.MACRO_OBJ}, true
+		}
+	}
+	return nil, false
+}
+
+func (c *checker) lookupBuiltin(name string) (*object.Builtin, bool) {
+	if c.env != nil {
+		if builtin, ok := c.env.GetBuiltin(name); ok {
+			return builtin, true
+		}
+	}
+	return stdlib.GetFn(name)
+}
+
+func (c *checker) checkExpression(exp ast.Expression, s *scope) object.ObjectType {
+	switch exp := exp.(type) {
+	case nil:
+		return unknown
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.BacktickLiteral:
+		return object.BACKTICK_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.Identifier:
+		if sym, ok := c.lookupVar(exp.Value, s); ok {
+			return sym.typ
+		}
+		if _, ok := c.lookupBuiltin(exp.Value); ok {
+			return object.BUILTIN_OBJ
+		}
+		c.errorf(exp.Pos(), "identifier not found: %s", exp.Value)
+		return unknown
+
+	case *ast.PrefixExpression:
+		right := c.checkExpression(exp.Right, s)
+		switch exp.Operator {
+		case "!":
+			return object.BOOLEAN_OBJ
+		case "-":
+			if right != unknown && right != object.INTEGER_OBJ {
+				c.errorf(exp.Pos(), "unknown operator: -%s", right)
+			}
+			return object.INTEGER_OBJ
+		}
+		return unknown
+
+	case *ast.InfixExpression:
+		left := c.checkExpression(exp.Left, s)
+		right := c.checkExpression(exp.Right, s)
+		return c.checkInfix(exp, left, right)
+
+	case *ast.IfExpression:
+		c.checkExpression(exp.Condition, s)
+		c.checkStatements(exp.Consequence.Statements, s)
+		if exp.Alternative != nil {
+			c.checkStatements(exp.Alternative.Statements, s)
+		}
+		return unknown
+
+	case *ast.RangeExpression:
+		it := c.checkExpression(exp.Iterator, s)
+		if it != unknown && it != object.ARRAY_OBJ {
+			c.errorf(exp.Pos(), "only arrays supported: %s", it)
+		}
+		body := newScope(s)
+		body.symbols[exp.Identifier.String()] = &symbol{typ: object.INTEGER_OBJ}
+		if exp.Iteree != nil {
+			body.symbols[exp.Iteree.String()] = &symbol{}
+		}
+		c.checkStatements(exp.Body.Statements, body)
+		return unknown
+
+	case *ast.ForExpression:
+		c.checkStatement(exp.Init, s)
+		if cond := c.checkExpression(exp.Condition, s); cond != unknown && cond != object.BOOLEAN_OBJ {
+			c.errorf(exp.Pos(), "for condition must be BOOLEAN, got %s", cond)
+		}
+		c.checkStatement(exp.After, s)
+		c.checkStatements(exp.Body.Statements, newScope(s))
+		return unknown
+
+	case *ast.FunctionLiteral:
+		body := newScope(s)
+		body.fn = exp
+		c.pending = append(c.pending, func() {
+			for _, p := range exp.Parameters {
+				typ := annotated(p.Type)
+				switch {
+				case p.Variadic:
+					typ = object.ARRAY_OBJ
+				case p.Default != nil:
+					// defaults may refer to earlier parameters
+					def := c.checkExpression(p.Default, body)
+					if typ != unknown && def != unknown && def != typ {
+						c.errorf(p.Pos(), "default for '%s': type %s but expected %s", p.Value, def, typ)
+					}
+					if typ == unknown {
+						typ = def
+					}
+				}
+				body.symbols[p.Value] = &symbol{typ: typ}
+			}
+			c.checkStatements(exp.Body.Statements, body)
+		})
+		return object.FUNCTION_OBJ
+
+	case *ast.MacroLiteral:
+		// macro bodies are quoted code, which is only checked once expanded
+		return object.MACRO_OBJ
+
+	case *ast.CallExpression:
+		return c.checkCall(exp, s)
+
+	case *ast.NamedArgument:
+		// named arguments to builtins are checked by checkBuiltinArguments
+		c.errorf(exp.Pos(), "named arguments are only supported by builtins: %s", exp.String())
+		c.checkExpression(exp.Value, s)
+		return unknown
+
+	case *ast.SpreadExpression:
+		if typ := c.checkExpression(exp.Value, s); typ != unknown && typ != object.ARRAY_OBJ {
+			c.errorf(exp.Pos(), "cannot spread %s", typ)
+		}
+		return unknown
+
+	case *ast.PipeExpression:
+		if exp.Destination != nil {
+			c.checkCall(exp.Destination, s)
+		}
+		return unknown
+
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			c.checkExpression(el, s)
+		}
+		return object.ARRAY_OBJ
+
+	case *ast.HashLiteral:
+		for k, v := range exp.Pairs {
+			c.checkExpression(k, s)
+			c.checkExpression(v, s)
+		}
+		return object.HASH_OBJ
+
+	case *ast.IndexExpression:
+		left := c.checkExpression(exp.Left, s)
+		c.checkExpression(exp.Index, s)
+		if left != unknown && left != object.ARRAY_OBJ && left != object.HASH_OBJ {
+			c.errorf(exp.Pos(), "index operator not supported: %s", left)
+		}
+		return unknown
+	}
+	return unknown
+}
+
+func (c *checker) checkInfix(exp *ast.InfixExpression, left, right object.ObjectType) object.ObjectType {
+	switch exp.Operator {
+	case "==", "!=":
+		return object.BOOLEAN_OBJ
+	}
+	if left == unknown || right == unknown {
+		switch exp.Operator {
+		case "<", ">":
+			return object.BOOLEAN_OBJ
+		}
+		return unknown
+	}
+	switch {
+	case left != right:
+		c.errorf(exp.Pos(), "type mismatch: %s %s %s", left, exp.Operator, right)
+		return unknown
+	case left == object.INTEGER_OBJ:
+		switch exp.Operator {
+		case "<", ">":
+			return object.BOOLEAN_OBJ
+		}
+		return object.INTEGER_OBJ
+	case left == object.STRING_OBJ && exp.Operator == "+":
+		return object.STRING_OBJ
+	}
+	c.errorf(exp.Pos(), "unknown operator: %s %s %s", left, exp.Operator, right)
+	return unknown
+}
+
+func (c *checker) checkCall(call *ast.CallExpression, s *scope) object.ObjectType {
+	pos := call.Pos()
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		typ := c.checkExpression(call.Function, s)
+		if fn, ok := call.Function.(*ast.FunctionLiteral); ok {
+			return c.checkFunctionCall("fn", fn, call, s)
+		}
+		if typ != unknown && typ != object.FUNCTION_OBJ && typ != object.BUILTIN_OBJ {
+			c.errorf(pos, "not a function: %s", typ)
+		}
+		c.checkFunctionArguments(call.Arguments, s)
+		return unknown
+	}
+	name := ident.Value
+	if name == "quote" {
+		// quoted code is not evaluated
+		return object.QUOTE_OBJ
+	}
+	if sym, ok := c.lookupVar(name, s); ok {
+		switch {
+		case sym.macro:
+			// macro arguments are quoted
+		case sym.fn != nil:
+			return c.checkFunctionCall(name, sym.fn, call, s)
+		case sym.typ != unknown && sym.typ != object.FUNCTION_OBJ && sym.typ != object.BUILTIN_OBJ:
+			c.errorf(pos, "not a function: %s", sym.typ)
+			c.checkFunctionArguments(call.Arguments, s)
+		default:
+			c.checkFunctionArguments(call.Arguments, s)
+		}
+		return unknown
+	}
+	builtin, ok := c.lookupBuiltin(name)
+	if !ok {
+		c.errorf(ident.Pos(), "identifier not found: %s", name)
+		c.checkFunctionArguments(call.Arguments, s)
+		return unknown
+	}
+	c.checkBuiltinArguments(name, builtin, call, s)
+	return unknown
+}
+
+func (c *checker) checkArguments(args []ast.Expression, s *scope) {
+	for _, arg := range args {
+		c.checkExpression(arg, s)
+	}
+}
+
+// checkFunctionArguments checks the arguments of a call which isn't to a builtin
+func (c *checker) checkFunctionArguments(args []ast.Expression, s *scope) {
+	for _, arg := range args {
+		c.checkFunctionArgument(arg, s)
+	}
+}
+
+// checkFunctionArgument checks an argument to a function. As in the evaluator, named
+// arguments are flag values, for the function to forward to a builtin.
+func (c *checker) checkFunctionArgument(arg ast.Expression, s *scope) object.ObjectType {
+	if named, ok := arg.(*ast.NamedArgument); ok {
+		c.checkExpression(named.Value, s)
+		return object.FLAG_OBJ
+	}
+	return c.checkExpression(arg, s)
+}
+
+// checkFunctionCall checks the arguments of a call to a function literal, returning its return type
+func (c *checker) checkFunctionCall(name string, fn *ast.FunctionLiteral, call *ast.CallExpression, s *scope) object.ObjectType {
+	pos := call.Pos()
+	arity := object.ParameterArity(fn.Parameters)
+	if !hasSpread(call.Arguments) && !arity.Accepts(len(call.Arguments)) {
+		c.errorf(pos, "wrong number of arguments to `%s`. got=%d, want=%s",
+			name, len(call.Arguments), arity)
+	}
+	spread := false
+	for i, arg := range call.Arguments {
+		typ := c.checkFunctionArgument(arg, s)
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			// arguments are no longer matched to parameters
+			spread = true
+		}
+		param := parameterFor(fn.Parameters, i)
+		if spread || param == nil {
+			continue
+		}
+		if expected := annotated(param.Type); expected != unknown && typ != unknown && typ != expected {
+			c.errorf(arg.Pos(), "argument '%s' of `%s`: type %s but expected %s", param.Value, name, typ, expected)
+		}
+	}
+	return annotated(fn.ReturnType)
+}
+
+// checkBuiltinArguments checks arguments and flags, which are resolved as the evaluator does
+func (c *checker) checkBuiltinArguments(name string, builtin *object.Builtin, call *ast.CallExpression, s *scope) {
+	count := 0
+	spread := hasSpread(call.Arguments)
+	for _, arg := range call.Arguments {
+		if named, ok := arg.(*ast.NamedArgument); ok {
+			c.checkNamedArgument(name, builtin, named, s)
+			continue
+		}
+		if flag, value, ok := c.flagArgument(builtin, arg, s); ok {
+			c.checkFlag(name, flag, value, arg, s)
+			continue
+		}
+		c.checkExpression(arg, s)
+		count++
+	}
+	if !spread && !builtin.Arity.Accepts(count) {
+		c.errorf(call.Pos(), "wrong number of arguments to `%s`. got=%d, want=%s",
+			name, count, builtin.Arity)
+	}
+}
+
+// flagArgument returns the flag for an argument such as `l` or `n(5)`. Variables take precedence over flags.
+func (c *checker) flagArgument(builtin *object.Builtin, arg ast.Expression, s *scope) (*object.Flag, *ast.CallExpression, bool) {
+	var ident *ast.Identifier
+	var call *ast.CallExpression
+	switch arg := arg.(type) {
+	case *ast.Identifier:
+		ident = arg
+	case *ast.CallExpression:
+		call = arg
+		ident, _ = arg.Function.(*ast.Identifier)
+	}
+	if ident == nil {
+		return nil, nil, false
+	}
+	if _, ok := c.lookupVar(ident.Value, s); ok {
+		return nil, nil, false
+	}
+	flag, ok := builtin.Flag(ident.Value)
+	if !ok {
+		return nil, nil, false
+	}
+	return flag, call, true
+}
+
+func (c *checker) checkNamedArgument(name string, builtin *object.Builtin, arg *ast.NamedArgument, s *scope) {
+	typ := c.checkExpression(arg.Value, s)
+	flag, ok := builtin.Flag(arg.Name.Value)
+	if !ok {
+		c.errorf(arg.Pos(), "unknown flag '%s' for `%s`", arg.Name.Value, name)
+		return
+	}
+	expected := object.ObjectType(object.BOOLEAN_OBJ)
+	if flag.TakesValue() {
+		expected = flag.ParamType
+	}
+	if typ != unknown && typ != expected {
+		c.errorf(arg.Pos(), "unexpected value type %s for flag '%s' of `%s`. Expected %s",
+			typ, flag.Name, name, expected)
+	}
+}
+
+func (c *checker) checkFlag(name string, flag *object.Flag, value *ast.CallExpression, arg ast.Expression, s *scope) {
+	switch {
+	case value == nil && flag.TakesValue():
+		c.errorf(arg.Pos(), "flag '%s' of `%s` needs a %s value", flag.Name, name, flag.ParamType)
+	case value != nil && !flag.TakesValue():
+		c.errorf(value.Pos(), "flag '%s' of `%s` does not take a value", flag.Name, name)
+	case value != nil:
+		if len(value.Arguments) != 1 {
+			c.errorf(value.Pos(), "flag '%s' of `%s` takes 1 value, got %d", flag.Name, name, len(value.Arguments))
+			c.checkArguments(value.Arguments, s)
+			return
+		}
+		typ := c.checkExpression(value.Arguments[0], s)
+		if typ != unknown && typ != flag.ParamType {
+			c.errorf(value.Pos(), "unexpected value type %s for flag '%s' of `%s`. Expected %s",
+				typ, flag.Name, name, flag.ParamType)
+		}
+	}
+}
+
+// parameterFor returns the parameter for the i'th argument, if any
+func parameterFor(params []*ast.Identifier, i int) *ast.Identifier {
+	if len(params) == 0 {
+		return nil
+	}
+	if last := params[len(params)-1]; last.Variadic && i >= len(params)-1 {
+		return last
+	}
+	if i < len(params) {
+		return params[i]
+	}
+	return nil
+}
+
+func hasSpread(args []ast.Expression) bool {
+	for _, arg := range args {
+		if _, ok := arg.(*ast.SpreadExpression); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/parser"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"ok", `var x = 1; x = x + 2; echo("{{.x}}")`, nil},
+		{"undefined", `echo(y)`, []string{"1:6: identifier not found: y"}},
+		{"undefined fn", `nope("x")`, []string{"1:1: identifier not found: nope"}},
+		{"reassign type", `var x = 1
+x = "a"`, []string{"2:1: cannot assign STRING to 'x' (type INTEGER)"}},
+		{"type mismatch", `var x = 1; var y = x + "a"`, []string{"1:20: type mismatch: INTEGER + STRING"}},
+		{"unknown operator", `"a" - "b"`, []string{"1:1: unknown operator: STRING - STRING"}},
+		{"prefix", `-"a"`, []string{"1:1: unknown operator: -STRING"}},
+		{"builtin arity", `len()`, []string{"1:1: wrong number of arguments to `len`. got=0, want=1"}},
+		{"variadic arity", `echo()`, []string{"1:1: wrong number of arguments to `echo`. got=0, want=1 or more"}},
+		{"fn arity", `var f = fn(a, b) { a + b }
+f(1)`, []string{"2:1: wrong number of arguments to `f`. got=1, want=2"}},
+		{"not a function", `var x = 1; x()`, []string{"1:12: not a function: INTEGER"}},
+		{"bool flag", `ls(l, "x")`, nil},
+		{"unknown flag", `ls(q)`, []string{"1:4: identifier not found: q"}},
+		{"value flag", `head(n(3), "x")`, nil},
+		{"value flag type", `head(n("3"), "x")`, []string{"1:6: unexpected value type STRING for flag 'n' of `head`. Expected INTEGER"}},
+		{"value flag missing", `head(n, "x")`, []string{"1:6: flag 'n' of `head` needs a INTEGER value"}},
+		{"flag value not expected", `ls(l(1))`, []string{"1:4: flag 'l' of `ls` does not take a value"}},
+		{"flags only in call", `ls(l); echo(l)`, []string{"1:13: identifier not found: l"}},
+		{"unreachable", `var f = fn() {
+  return 1
+  # a comment
+  echo("x")
+  echo("y")
+}`, []string{"4:3: unreachable code"}},
+		{"recursion", `var f = fn(n) { if (n > 0) { f(n - 1) } }; f(3)`, nil},
+		{"later global", `var f = fn() { g() }; var g = fn() { 1 }; f()`, nil},
+		{"params", `var f = fn(a) { a }; f(1); echo(a)`, []string{"1:33: identifier not found: a"}},
+		{"range", `range (i, v = [1, 2]) { echo("{{.v}}") }; echo(i)`, []string{"1:48: identifier not found: i"}},
+		{"range over int", `range (i = 1) { echo("x") }`, []string{"1:1: only arrays supported: INTEGER"}},
+		{"index", `var x = 1; x[0]`, []string{"1:12: index operator not supported: INTEGER"}},
+		{"macro", `var m = macro(a) { quote(unquote(a) + 1) }; m(zzz)`, nil},
+		{"quote", `quote(zzz)`, nil},
+		{"annotated var", `var x: string = 1`, []string{"1:5: cannot assign INTEGER to 'x' (type STRING)"}},
+		{"annotated var later", `var x: int = len("a"); x = "b"`, []string{"1:24: cannot assign STRING to 'x' (type INTEGER)"}},
+		{"annotated params", `var f = fn(a: string, b: int) { a + "x" }
+f("a", "b")`, []string{"2:8: argument 'b' of `f`: type STRING but expected INTEGER"}},
+		{"annotated param use", `var f = fn(a: string) { a - 1 }`, []string{"1:25: type mismatch: STRING - INTEGER"}},
+		{"annotated return", `var f = fn(): int { return "a" }`, []string{"1:21: cannot return STRING from a function returning INTEGER"}},
+		{"inferred return", `var f = fn(): int { 1 }; var s = f() + "a"`, []string{"1:34: type mismatch: INTEGER + STRING"}},
+		{"default params", `var f = fn(a, b = 1) { a + b }; f(1); f(1, 2); f(1, 2, 3)`,
+			[]string{"1:48: wrong number of arguments to `f`. got=3, want=1 to 2"}},
+		{"default type", `var f = fn(a: int = "x") { a }`, []string{"1:12: default for 'a': type STRING but expected INTEGER"}},
+		{"inferred default", `var f = fn(a = 1) { a + "x" }`, []string{"1:21: type mismatch: INTEGER + STRING"}},
+		{"variadic", `var f = fn(a, ...rest: int) { len(rest) + a }; f(); f(1); f(1, 2, "c")`, []string{
+			"1:48: wrong number of arguments to `f`. got=0, want=1 or more",
+			"1:67: argument 'rest' of `f`: type STRING but expected INTEGER",
+		}},
+		{"spread", `var f = fn(a, b) { a }; var xs = [1, 2]; f(...xs); len(...xs); f(...1, 2)`, []string{"1:66: cannot spread INTEGER"}},
+		{"named flag", `head(n: 3, "x"); head(lines: 3, "x"); ls(long: true, "x")`, nil},
+		{"named flag type", `head(n: "3", "x"); ls(l: 1)`, []string{
+			"1:6: unexpected value type STRING for flag 'n' of `head`. Expected INTEGER",
+			"1:23: unexpected value type INTEGER for flag 'l' of `ls`. Expected BOOLEAN",
+		}},
+		{"unknown named flag", `head(nope: 3, "x")`, []string{"1:6: unknown flag 'nope' for `head`"}},
+		{"flags to fn", `var f = fn(...rest) { ls(...rest) }; f(l: true, long: true, "x")`, nil},
+		{"flag to typed param", `var f = fn(a: string) { a }; f(l: true)`, []string{"1:32: argument 'a' of `f`: type FLAG but expected STRING"}},
+		{"flag value checked", `var f = fn(...rest) { ls(...rest) }; f(n: y)`, []string{"1:43: identifier not found: y"}},
+		{"undefined arg to fn", `var f = fn(x) { x }; f(undefinedVar)`, []string{"1:24: identifier not found: undefinedVar"}},
+		{"undefined call in fn args", `var g = fn(a, b) { a }; g(1, nosuch(2))`, []string{"1:30: identifier not found: nosuch"}},
+		{"variable not shadowed", `var n = "x"; cat(n); head(n, "y")`, nil},
+		{"all errors", `echo(a)
+echo(b)
+len(1, 2)`, []string{
+			"1:6: identifier not found: a",
+			"2:6: identifier not found: b",
+			"3:1: wrong number of arguments to `len`. got=2, want=1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := check(t, tt.input, nil)
+			got := []string{}
+			for _, err := range errs {
+				got = append(got, err.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("got errors:\n%s\nexpected:\n%s", strings.Join(got, "\n"), strings.Join(tt.expected, "\n"))
+			}
+		})
+	}
+}
+
+func TestCheckWithEnv(t *testing.T) {
+	env := object.NewEnvironment(object.Streams{})
+	env.Set("x", &object.Integer{Value: 1})
+	errs := check(t, `echo("{{.x}}"); x = "a"`, env)
+	if len(errs) != 1 || errs[0].Msg != "cannot assign STRING to 'x' (type INTEGER)" {
+		t.Errorf("unexpected errors %v", errs)
+	}
+}
+
+func check(t *testing.T, input string, env *object.Environment) []*Error {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		t.Fatalf("parser errors: %v", p.Errors())
+	}
+	return Check(program, env)
+}
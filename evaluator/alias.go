@@ -0,0 +1,145 @@
+package evaluator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/parser"
+	"github.com/laher/smoosh/stdlib"
+)
+
+func init() {
+	stdlib.RegisterBuiltin("alias", &object.Builtin{
+		Fn:    alias,
+		Arity: &object.Arity{Min: 2, Max: 2},
+		Help: `Usage: alias(NAME, CALL)
+Define NAME as a call to a builtin with preset arguments, e.g. alias("ll", "ls(l, a)").
+Arguments to NAME follow the preset ones, so ll("dir") calls ls(l, a, "dir").`,
+	})
+	stdlib.RegisterBuiltin("unalias", &object.Builtin{
+		Fn:    unalias,
+		Arity: &object.Arity{Min: 1, Max: 1},
+		Help: `Usage: unalias(NAME)
+Remove the alias NAME.`,
+	})
+	stdlib.RegisterBuiltin("aliases", &object.Builtin{
+		Fn:    aliases,
+		Arity: &object.Arity{Min: 0, Max: 0},
+		Help: `Usage: aliases()
+List the aliases as calls to alias, ready to be saved in ~/.smooshrc.`,
+	})
+}
+
+func alias(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 to `alias` not supported, got %s", args[0].Type())
+	}
+	def, ok := args[1].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 2 to `alias` not supported, got %s", args[1].Type())
+	}
+	builtin, err := evalAlias(name.Value, def.Value, scope.Env)
+	if err != nil {
+		return nil, err
+	}
+	return func() object.Object {
+		scope.Env.Aliases[name.Value] = &object.Alias{Def: def.Value, Builtin: builtin}
+		return NULL
+	}, nil
+}
+
+func unalias(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 to `unalias` not supported, got %s", args[0].Type())
+	}
+	if _, ok := scope.Env.Aliases[name.Value]; !ok {
+		return nil, fmt.Errorf("no alias named %s", name.Value)
+	}
+	return func() object.Object {
+		delete(scope.Env.Aliases, name.Value)
+		return NULL
+	}, nil
+}
+
+func aliases(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=0",
+			len(args))
+	}
+	return func() object.Object {
+		names := []string{}
+		for name := range scope.Env.Aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		var out strings.Builder
+		for _, name := range names {
+			fmt.Fprintf(&out, "alias(%q, %q)\n", name, scope.Env.Aliases[name].Def)
+		}
+		if scope.Out != nil {
+			fmt.Fprint(scope.Env.Streams.Stdout, out.String())
+			return NULL
+		}
+		return &object.String{Value: out.String()}
+	}, nil
+}
+
+// parseAlias parses the definition of an alias, which must be a call to a named function
+func parseAlias(def string) (*ast.CallExpression, error) {
+	p := parser.New(lexer.New(def))
+	program := p.ParseProgram()
+	if errs := p.Errors(); len(errs) > 0 {
+		return nil, fmt.Errorf("alias %q: %s", def, errs[0])
+	}
+	if len(program.Statements) == 1 {
+		if stmt, ok := program.Statements[0].(*ast.ExpressionStatement); ok {
+			if call, ok := stmt.Expression.(*ast.CallExpression); ok {
+				if _, ok := call.Function.(*ast.Identifier); ok {
+					return call, nil
+				}
+			}
+		}
+	}
+	return nil, fmt.Errorf("alias %q: expected a call to a builtin", def)
+}
+
+// lookupBuiltin finds a builtin by name, skipping variables and aliases
+func lookupBuiltin(name string, env *object.Environment) (*object.Builtin, bool) {
+	if builtin, ok := env.GetBuiltin(name); ok {
+		return builtin, true
+	}
+	return stdlib.GetFn(name)
+}
+
+// evalAlias returns a builtin which calls the alias' builtin with its preset arguments.
+// Mistakes in the preset arguments are reported now, rather than when the alias is used.
+func evalAlias(name, def string, env *object.Environment) (*object.Builtin, error) {
+	call, err := parseAlias(def)
+	if err != nil {
+		return nil, err
+	}
+	callee := call.Function.(*ast.Identifier).Value
+	target, ok := lookupBuiltin(callee, env)
+	if !ok {
+		return nil, fmt.Errorf("alias %s: no builtin named %s", name, callee)
+	}
+	preset := evalBuiltinArguments(target, callee, call.Arguments, env)
+	if len(preset) == 1 && isError(preset[0]) {
+		return nil, fmt.Errorf("alias %s: %s", name, preset[0].(*object.Error).Message)
+	}
+	return partial(target, preset, fmt.Sprintf("alias for %s\n\n%s", def, target.Help)), nil
+}
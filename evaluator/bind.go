@@ -0,0 +1,112 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// bindBuiltin is recognised by the evaluator, which evaluates its flags as flags of the
+// builtin being bound. It's assigned in init, because bind calls back into the evaluator.
+var bindBuiltin *object.Builtin
+
+// composeBuiltin is recognised by the evaluator, which names each stage of the pipeline
+// it returns after the argument which gave it, as for pipeline
+var composeBuiltin *object.Builtin
+
+func init() {
+	bindBuiltin = &object.Builtin{
+		Fn:    bind,
+		Arity: &object.Arity{Min: 1, Max: -1},
+		Help: `Usage: bind(FN, ARGS...)
+Return FN with its leading arguments fixed, e.g. var errs = bind(grep, i, "error").
+Flags are those of FN, and arguments given later follow ARGS.`,
+	}
+	stdlib.RegisterBuiltin("bind", bindBuiltin)
+	composeBuiltin = &object.Builtin{
+		Fn:    compose,
+		Arity: &object.Arity{Min: 1, Max: -1},
+		Help: `Usage: compose(FN...)
+Return a pipeline which pipes each FN into the next, e.g. to be piped into itself:
+var errs = compose(bind(grep, i, "error"), bind(head, n(5))); cat("log") | errs()
+An FN is a builtin or a pipeline. Arguments are given to the first FN.`,
+	}
+	stdlib.RegisterBuiltin("compose", composeBuiltin)
+}
+
+// evalBindArguments evaluates the arguments to bind, as arguments to the builtin it binds
+func evalBindArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	if len(exps) == 0 {
+		return nil
+	}
+	fn := Eval(exps[0], env)
+	if isError(fn) {
+		return []object.Object{fn}
+	}
+	var rest []object.Object
+	if builtin, ok := fn.(*object.Builtin); ok {
+		rest = evalBuiltinArguments(builtin, exps[0].TokenLiteral(), exps[1:], env)
+	} else {
+		rest = evalExpressions(exps[1:], env)
+	}
+	if len(rest) == 1 && isError(rest[0]) {
+		return rest
+	}
+	return append([]object.Object{fn}, rest...)
+}
+
+func bind(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1+",
+			len(args))
+	}
+	preset := args[1:]
+	switch fn := args[0].(type) {
+	case *object.Builtin:
+		return func() object.Object {
+			return partial(fn, preset, "bound "+fn.Help)
+		}, nil
+	case *object.Function:
+		return func() object.Object {
+			return &object.Builtin{
+				Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+					all := append(append([]object.Object{}, preset...), args...)
+					return func() object.Object {
+						return applyFunction(fn, all, nil, nil, false, scope.Env, "fn", scope.Line)
+					}, nil
+				},
+			}
+		}, nil
+	}
+	return nil, fmt.Errorf("argument 1 to `bind` not supported, got %s", args[0].Type())
+}
+
+// partial returns a builtin which calls target, with preset arguments before any others.
+// It takes the flags of target, so that more can be given.
+func partial(target *object.Builtin, preset []object.Object, help string) *object.Builtin {
+	return &object.Builtin{
+		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+			all := append(append([]object.Object{}, preset...), args...)
+			return target.Fn(scope, all...)
+		},
+		Help:     help,
+		Flags:    target.Flags,
+		Mutating: target.Mutating,
+	}
+}
+
+func compose(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1+",
+			len(args))
+	}
+	p, err := joinStages("compose", args)
+	if err != nil {
+		return nil, err
+	}
+	return func() object.Object {
+		return p
+	}, nil
+}
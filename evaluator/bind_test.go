@@ -0,0 +1,59 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/laher/smoosh/object"
+)
+
+func TestBindAndCompose(t *testing.T) {
+	testPipes(t, `var errs = compose(bind(grep, i, "error"), bind(head, n(2)))`, []pipeTest{
+		{`cat("log") | errs()`, "error one\nERROR two\n", ""},
+		{`errs("log")`, "error one\nERROR two\n", ""},
+		{`cat("log") | errs() | wc(l)`, "2\n", ""},
+		{`var ok = bind(grep, "ok"); ok("log")`, "ok\n", ""},
+		{`var add = fn(a, b) { a + b }; var inc = bind(add, 1); echo(inc(2))`, "3\n", ""},
+		{`bind(grep, zz)`, "", "identifier not found: zz"},
+		{`bind(1)`, "", "argument 1 to `bind` not supported, got INTEGER"},
+		{`compose(grep, 1)`, "", "argument 2 to `compose` must be a builtin or a pipeline, got INTEGER"},
+	})
+}
+
+func TestComposeValue(t *testing.T) {
+	evaluated := testEval(`compose(bind(grep, i, "error"), head)`)
+	p, ok := evaluated.(*object.Pipeline)
+	if !ok {
+		t.Fatalf("object is not Pipeline. got=%T (%+v)", evaluated, evaluated)
+	}
+	names := []string{}
+	for _, stage := range p.Stages {
+		names = append(names, stage.Name)
+	}
+	if expected := []string{`bind(grep, i, "error")`, "head"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected stages %q, got %q", expected, names)
+	}
+
+	evaluated = testEval(`var errs = compose(bind(grep, "error"), head); stages(compose(errs, wc))`)
+	arr, ok := evaluated.(*object.Array)
+	if !ok {
+		t.Fatalf("object is not Array. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(arr.Elements) != 3 {
+		t.Fatalf("expected 3 stages, got %d", len(arr.Elements))
+	}
+	if got := arr.Elements[2].Inspect(); got != "pipeline(wc())" {
+		t.Errorf("expected last stage pipeline(wc()), got %s", got)
+	}
+}
+
+func TestBindValue(t *testing.T) {
+	evaluated := testEval(`bind(grep, i)`)
+	b, ok := evaluated.(*object.Builtin)
+	if !ok {
+		t.Fatalf("object is not Builtin. got=%T (%+v)", evaluated, evaluated)
+	}
+	if _, ok := b.Flag("v"); !ok {
+		t.Errorf("expected bound builtin to take the flags of grep")
+	}
+}
@@ -0,0 +1,101 @@
+package evaluator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// builtins which need to call back into the evaluator
+func init() {
+	stdlib.RegisterBuiltin("set", &object.Builtin{
+		Fn:    setOption(true),
+		Arity: &object.Arity{Min: 1, Max: 2},
+		Help: `Usage: set(OPTION [, FN])
+Switch on an interpreter option. With FN, the option is only switched on while FN runs.
+Options: ` + strings.Join(object.OptionNames(), ", "),
+	})
+	stdlib.RegisterBuiltin("unset", &object.Builtin{
+		Fn:    setOption(false),
+		Arity: &object.Arity{Min: 1, Max: 2},
+		Help: `Usage: unset(OPTION [, FN])
+Switch off an interpreter option. With FN, the option is only switched off while FN runs.
+Options: ` + strings.Join(object.OptionNames(), ", "),
+	})
+	stdlib.RegisterBuiltin("in_dir", &object.Builtin{
+		Fn:    inDir,
+		Arity: &object.Arity{Min: 2, Max: 2},
+		Help: `Usage: in_dir(DIR, FN)
+Call FN with DIR as the working directory, then change back.`,
+	})
+}
+
+func setOption(on bool) object.BuiltinFunction {
+	return func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+		if len(args) < 1 || len(args) > 2 {
+			return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1 or 2",
+				len(args))
+		}
+		name, ok := args[0].(*object.String)
+		if !ok {
+			return nil, fmt.Errorf("argument 1 to `set` not supported, got %s", args[0].Type())
+		}
+		if len(args) == 1 {
+			return func() object.Object {
+				if err := scope.Env.Options.Set(name.Value, on); err != nil {
+					return object.NewError("%s", err)
+				}
+				return NULL
+			}, nil
+		}
+		fn, ok := args[1].(*object.Function)
+		if !ok {
+			return nil, fmt.Errorf("argument 2 to `set` not supported, got %s", args[1].Type())
+		}
+		return func() object.Object {
+			prev, err := scope.Env.Options.Swap(name.Value, on)
+			if err != nil {
+				return object.NewError("%s", err)
+			}
+			defer scope.Env.Options.Set(name.Value, prev)
+			return applyFunction(fn, []object.Object{}, nil, nil, false, scope.Env, "set", scope.Line)
+		}, nil
+	}
+}
+
+func inDir(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	dir, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 to `in_dir` not supported, got %s", args[0].Type())
+	}
+	fn := args[1]
+	if _, ok := fn.(*object.Function); !ok {
+		return nil, fmt.Errorf("argument 2 to `in_dir` not supported, got %s", fn.Type())
+	}
+	d, err := stdlib.InterpolateEnv(scope.Env, nil, dir.Value)
+	if err != nil {
+		return nil, err
+	}
+	if err := scope.Env.CheckRead(d); err != nil {
+		return nil, err
+	}
+	fi, err := scope.Env.Files().Stat(d)
+	if err != nil {
+		return nil, err
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("in_dir: not a directory: %s", d)
+	}
+	return func() object.Object {
+		prev := scope.Env.WorkDir.Get()
+		scope.Env.WorkDir.Set(d)
+		defer scope.Env.WorkDir.Set(prev)
+		return applyFunction(fn, []object.Object{}, nil, nil, false, scope.Env, "in_dir", scope.Line)
+	}, nil
+}
@@ -3,6 +3,7 @@ package evaluator
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"sync"
 
 	"github.com/laher/smoosh/ast"
@@ -16,8 +17,16 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// Eval evaluates a node. Errors are given the position of the innermost node which raised them.
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	result := eval(node, env)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() && node != nil {
+		err.Pos = node.Pos()
+	}
+	return result
+}
 
+func eval(node ast.Node, env *object.Environment) object.Object {
 	switch node := node.(type) {
 
 	// Statements
@@ -28,6 +37,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalBlockStatement(node, env)
 
 	case *ast.ExpressionStatement:
+		if call, ok := node.Expression.(*ast.CallExpression); ok {
+			return evalCallExpression(call, env, true)
+		}
 		return Eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
@@ -42,6 +54,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if err := object.CheckAnnotation(node.Name.Type, val); err != nil {
+			return newError("%s: %s", node.Name.Value, err)
+		}
 		if v, ok := env.Get(node.Name.Value); ok {
 			if val.Type() != v.Type() {
 				return newError("type %s but expected %s", val.Type(), v.Type())
@@ -91,40 +106,10 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Parameters: params, ReturnType: node.ReturnType, Env: env, Body: body}
 
 	case *ast.CallExpression:
-		if node.Function.TokenLiteral() == "quote" {
-			return quote(node.Arguments[0], env)
-		}
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		enclosedEnv := object.NewEnclosedEnvironment(env)
-		// apply extra flags during argument parsing
-		switch fn := function.(type) {
-		case *object.Builtin:
-			if len(fn.Flags) > 0 {
-				for i := range fn.Flags {
-					switch fn.Flags[i].ParamType {
-					case object.INTEGER_OBJ, object.STRING_OBJ:
-						enclosedEnv.Set(fn.Flags[i].Name, flagFn(&fn.Flags[i]))
-					case object.BOOLEAN_OBJ, object.ObjectType(""):
-						// TODO maybe allow flags to be passed as 'false' (where default is true)... OR just force them to always default to false
-						// enclosedEnv.Set(fn.Flags[i].Name, flagFn(&fn.Flags[i]))
-						enclosedEnv.Set(fn.Flags[i].Name, &fn.Flags[i])
-					default:
-						return object.NewError("Unexpected flag ParamType [%v]", fn.Flags[i].ParamType)
-					}
-				}
-			}
-		}
-		args := evalExpressions(node.Arguments, enclosedEnv)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args, node.In, node.Out, env, node.Function.TokenLiteral())
+		return evalCallExpression(node, env, false)
 
 	case *ast.ArrayLiteral:
 		elements := evalExpressions(node.Elements, env)
@@ -148,8 +133,17 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalHashLiteral(node, env)
 
 	case *ast.PipeExpression:
+		if node.Destination == nil {
+			return nil
+		}
 		return Eval(node.Destination, env)
 
+	case *ast.NamedArgument:
+		return newError("named arguments are only supported by builtins: %s", node.String())
+
+	case *ast.SpreadExpression:
+		return newError("spread is only supported in call arguments and arrays: %s", node.String())
+
 	case *ast.RangeExpression:
 		return evalRangeExpression(node, env)
 
@@ -208,21 +202,30 @@ func evalBlockStatement(
 }
 
 func shouldBePiping(statement ast.Statement) bool {
-	if expS, ok := statement.(*ast.ExpressionStatement); ok {
-		if c, ok := expS.Expression.(*ast.CallExpression); ok {
-			return c.Out != nil
-		}
+	if c := statementCall(statement); c != nil {
+		return c.Out != nil
 	}
 	return false
 }
 
 func isPiping(statement ast.Statement) bool {
+	if c := statementCall(statement); c != nil {
+		return c.Out != nil && c.Out.Main != nil
+	}
+	return false
+}
+
+// statementCall returns the call made by a statement, which may be a stage of a pipeline
+func statementCall(statement ast.Statement) *ast.CallExpression {
 	if expS, ok := statement.(*ast.ExpressionStatement); ok {
-		if c, ok := expS.Expression.(*ast.CallExpression); ok {
-			return c.Out != nil && c.Out.Main != nil
+		switch e := expS.Expression.(type) {
+		case *ast.CallExpression:
+			return e
+		case *ast.PipeExpression:
+			return e.Destination
 		}
 	}
-	return false
+	return nil
 }
 
 func connectPipes(statements []ast.Statement) {
@@ -230,14 +233,12 @@ func connectPipes(statements []ast.Statement) {
 		if i > 0 {
 			prev := statements[i-1]
 			if expS, ok := this.(*ast.ExpressionStatement); ok {
-				if p, ok := expS.Expression.(*ast.PipeExpression); ok {
+				if p, ok := expS.Expression.(*ast.PipeExpression); ok && p.Destination != nil {
 					//this is a pipe ... hook up the outs and ins
 					pipes := &ast.Pipes{}
 
-					if expS, ok := prev.(*ast.ExpressionStatement); ok {
-						if callS, ok := expS.Expression.(*ast.CallExpression); ok {
-							callS.Out = pipes
-						}
+					if callS := statementCall(prev); callS != nil {
+						callS.Out = pipes
 					}
 					p.Destination.In = pipes
 				}
@@ -397,6 +398,9 @@ func evalRangeExpression(
 			extendedEnv.Set(fe.Iteree.String(), v)
 		}
 		ret = Eval(fe.Body, extendedEnv)
+		if isError(ret) && env.Options.Is(object.OptErrExit) {
+			return ret
+		}
 	}
 	return ret
 }
@@ -424,6 +428,9 @@ func evalForExpression(
 		extendedEnv := object.NewEnclosedEnvironment(env)
 		//TODO FOR varables
 		ret = Eval(fe.Body, extendedEnv)
+		if isError(ret) && env.Options.Is(object.OptErrExit) {
+			return ret
+		}
 		Eval(fe.After, env)
 	}
 	return ret
@@ -437,6 +444,14 @@ func evalIdentifier(
 		return val
 	}
 
+	if alias, ok := env.Aliases[node.Value]; ok {
+		return alias.Builtin
+	}
+
+	if builtin, ok := env.GetBuiltin(node.Value); ok {
+		return builtin
+	}
+
 	if builtin, ok := stdlib.GetFn(node.Value); ok {
 		return builtin
 	}
@@ -475,6 +490,18 @@ func evalExpressions(
 	var result []object.Object
 
 	for _, e := range exps {
+		if spread, ok := e.(*ast.SpreadExpression); ok {
+			evaluated := Eval(spread.Value, env)
+			if isError(evaluated) {
+				return []object.Object{evaluated}
+			}
+			arr, ok := evaluated.(*object.Array)
+			if !ok {
+				return []object.Object{newError("cannot spread %s", evaluated.Type())}
+			}
+			result = append(result, arr.Elements...)
+			continue
+		}
 		evaluated := Eval(e, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
@@ -485,7 +512,49 @@ func evalExpressions(
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object, in, out *ast.Pipes, env *object.Environment, tokenLiteral string) object.Object {
+// evalCallExpression evaluates a call, which may be a statement of its own
+func evalCallExpression(call *ast.CallExpression, env *object.Environment, statement bool) object.Object {
+	if call.Function.TokenLiteral() == "quote" {
+		return quote(call.Arguments[0], env)
+	}
+	function := Eval(call.Function, env)
+	if isError(function) {
+		return function
+	}
+	var args []object.Object
+	if function == object.Object(bindBuiltin) {
+		args = evalBindArguments(call.Arguments, env)
+	} else if function == object.Object(pipelineBuiltin) || function == object.Object(composeBuiltin) {
+		args = evalPipelineArguments(call.Arguments, env)
+	} else if fn, ok := function.(*object.Builtin); ok {
+		args = evalBuiltinArguments(fn, call.Function.TokenLiteral(), call.Arguments, env)
+	} else if p, ok := function.(*object.Pipeline); ok && len(p.Stages) > 0 {
+		// arguments are given to the first stage, so they may be its flags
+		args = evalBuiltinArguments(p.Stages[0].Fn, p.Stages[0].Name, call.Arguments, env)
+	} else {
+		args = evalFunctionArguments(call.Arguments, env)
+	}
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	name := call.Function.TokenLiteral()
+	result := applyFunction(function, args, call.In, call.Out, statement, env, name, call.Token.Line)
+	if err, ok := result.(*object.Error); ok {
+		if !err.Pos.IsValid() {
+			err.Pos = call.Pos()
+		}
+		err.Stack = append(err.Stack, object.Frame{Name: name, Args: args, Pos: call.Pos()})
+	}
+	return result
+}
+
+// Apply calls a function or builtin with arguments, as a call to name would, e.g. for a
+// host program calling back into smoosh
+func Apply(fn object.Object, name string, args []object.Object, env *object.Environment) object.Object {
+	return applyFunction(fn, args, nil, nil, false, env, name, 0)
+}
+
+func applyFunction(fn object.Object, args []object.Object, in, out *ast.Pipes, statement bool, env *object.Environment, tokenLiteral string, line int) object.Object {
 	defer func() {
 		if in != nil {
 			// defer guarantees this runs AFTER applyFunction.
@@ -496,12 +565,28 @@ func applyFunction(fn object.Object, args []object.Object, in, out *ast.Pipes, e
 	switch fn := fn.(type) {
 
 	case *object.Function:
-		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
-		return unwrapReturnValue(evaluated)
+		extendedEnv, err := extendFunctionEnv(fn, args, tokenLiteral)
+		if err != nil {
+			return err
+		}
+		evaluated := unwrapReturnValue(Eval(fn.Body, extendedEnv))
+		if isError(evaluated) {
+			return evaluated
+		}
+		if err := object.CheckAnnotation(fn.ReturnType, evaluated); err != nil {
+			return newError("return value of `%s`: %s", tokenLiteral, err)
+		}
+		return evaluated
 
 	case *object.Builtin:
+		if fn.Mutating && env.Options.Is(object.OptDryRun) {
+			return dryRun(env, tokenLiteral, args, line, out)
+		}
+		if env.Options.Is(object.OptTrace) {
+			env.Options.Tracef(line, "%s", formatCall(env, tokenLiteral, args))
+		}
 		myEnv := env
+		var stageOut, stageErr *io.PipeWriter
 		if in != nil || out != nil {
 			myEnv = object.NewEnclosedEnvironment(env)
 			if in != nil {
@@ -514,62 +599,132 @@ func applyFunction(fn object.Object, args []object.Object, in, out *ast.Pipes, e
 				r, w := io.Pipe()
 				myEnv.Streams.Stdout = w // this will be closed by the evaluator
 				out.Main = r
+				stageOut = w
 				r, w = io.Pipe()
 				myEnv.Streams.Stderr = w // this will be closed by the evaluator
 				out.Err = r
+				stageErr = w
 			}
 		}
 		op, err := fn.Fn(object.Scope{
-			Env: myEnv,
-			In:  in,
-			Out: out,
+			Env:       myEnv,
+			In:        in,
+			Out:       out,
+			Line:      line,
+			Statement: statement,
 		}, args...)
 		if err != nil {
 			return object.NewError(err.Error())
 		}
 		if out != nil {
-			doAsync(op, out, myEnv.Streams.Stderr)
+			doAsync(op, in, out, stageOut, stageErr, env.Options, tokenLiteral)
 			return NULL
 		}
 
-		return op()
+		result := op()
+		if in != nil && env.Options.Strict() && !isError(result) {
+			if err := waitUpstream(in); err != nil {
+				return object.NewError("%s", err)
+			}
+		}
+		return result
+	case *object.Pipeline:
+		return runPipeline(fn, args, in, out, env, line)
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
 }
 
-func doAsync(op object.Operation, out *ast.Pipes, stderr io.Writer) {
+// doAsync runs a piped stage. In strict mode (errexit or pipefail), out.Wait returns the
+// error of this stage or, failing that, of an earlier stage. Otherwise errors are logged to
+// the interpreter's stderr (not the stage's stderr, which may be a pipe that nobody reads).
+func doAsync(op object.Operation, in, out *ast.Pipes, stdout, stderrPipe io.Closer, opts *object.Options, name string) {
+	stderr := opts.Log
+	if stderr == nil {
+		stderr = ioutil.Discard
+	}
+	var stageErr error
 	wg := sync.WaitGroup{}
 	out.Wait = func() error {
 		wg.Wait()
-		return nil
+		return stageErr
 	}
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 		o := op()
 		if oe, ok := o.(*object.Error); ok {
-			fmt.Fprintf(stderr, "Error returned from piped func: [%s]\n", oe.Message)
+			if opts.Strict() {
+				stageErr = fmt.Errorf("piped stage '%s' failed: %s", name, oe.Message)
+			} else {
+				fmt.Fprintf(stderr, "Error returned from piped func: [%s]\n", oe.Message)
+			}
 		}
-		err := out.Main.Close()
-		if err != nil {
-			fmt.Fprintf(stderr, "Error closing pipe: [%s]\n", err)
+		// closing the writers signals EOF to the next stage
+		for _, w := range []io.Closer{stdout, stderrPipe} {
+			if err := w.Close(); err != nil {
+				fmt.Fprintf(stderr, "Error closing pipe: [%s]\n", err)
+			}
+		}
+		if stageErr == nil && in != nil && opts.Strict() {
+			stageErr = waitUpstream(in)
 		}
 	}()
 
 }
 
+// waitUpstream discards any unread input, so that the previous stage can finish, then waits for it
+func waitUpstream(in *ast.Pipes) error {
+	io.Copy(ioutil.Discard, in.Main)
+	if in.Wait == nil {
+		return nil
+	}
+	return in.Wait()
+}
+
 func extendFunctionEnv(
 	fn *object.Function,
 	args []object.Object,
-) *object.Environment {
+	name string,
+) (*object.Environment, *object.Error) {
 	env := object.NewEnclosedEnvironment(fn.Env)
 
+	if arity := object.ParameterArity(fn.Parameters); !arity.Accepts(len(args)) {
+		return nil, newError("wrong number of arguments to `%s`. got=%d, want=%s",
+			name, len(args), arity)
+	}
 	for paramIdx, param := range fn.Parameters {
-		env.Set(param.Value, args[paramIdx])
+		if param.Variadic {
+			rest := []object.Object{}
+			if paramIdx < len(args) {
+				rest = append(rest, args[paramIdx:]...)
+			}
+			// the annotation of a variadic parameter applies to each argument
+			for _, arg := range rest {
+				if err := object.CheckAnnotation(param.Type, arg); err != nil {
+					return nil, newError("argument '%s' of `%s`: %s", param.Value, name, err)
+				}
+			}
+			env.Set(param.Value, &object.Array{Elements: rest})
+			continue
+		}
+		var arg object.Object
+		if paramIdx < len(args) {
+			arg = args[paramIdx]
+		} else {
+			// defaults are evaluated when called, and may refer to earlier parameters
+			arg = Eval(param.Default, env)
+			if err, ok := arg.(*object.Error); ok {
+				return nil, err
+			}
+		}
+		if err := object.CheckAnnotation(param.Type, arg); err != nil {
+			return nil, newError("argument '%s' of `%s`: %s", param.Value, name, err)
+		}
+		env.Set(param.Value, arg)
 	}
 
-	return env
+	return env, nil
 }
 
 func unwrapReturnValue(obj object.Object) object.Object {
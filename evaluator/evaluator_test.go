@@ -2,11 +2,16 @@ package evaluator
 
 import (
 	"bytes"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/laher/smoosh/lexer"
 	"github.com/laher/smoosh/object"
 	"github.com/laher/smoosh/parser"
+	"github.com/laher/smoosh/vfs"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -226,6 +231,39 @@ if (10 > 1) {
 			`999[1]`,
 			"index operator not supported: INTEGER",
 		},
+		{
+			`var x: string = 1`,
+			"x: type INTEGER but expected STRING",
+		},
+		{
+			`var f = fn(a: string, b: int) { a }; f("x", "y")`,
+			"argument 'b' of `f`: type STRING but expected INTEGER",
+		},
+		{
+			`var f = fn(a): int { a }; f("x")`,
+			"return value of `f`: type STRING but expected INTEGER",
+		},
+		{
+			`var f = fn(a, b) { a }; f(1)`,
+			"wrong number of arguments to `f`. got=1, want=2",
+		},
+		{
+			`var f = fn(a) { a }
+f(1, 2)`,
+			"wrong number of arguments to `f`. got=2, want=1",
+		},
+		{
+			`var f = fn(a, b = 1, ...rest) { a }; f()`,
+			"wrong number of arguments to `f`. got=0, want=1 or more",
+		},
+		{
+			`var f = fn(...rest: int) { rest }; f(1, "a")`,
+			"argument 'rest' of `f`: type STRING but expected INTEGER",
+		},
+		{
+			`var f = fn(a) { a }; f(...1)`,
+			"cannot spread INTEGER",
+		},
 	}
 
 	for _, tt := range tests {
@@ -245,6 +283,54 @@ if (10 > 1) {
 	}
 }
 
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var x = 1\nvar y = x + \"a\"", "2:9"},
+		{"var f = fn() {\n  -true\n}\nf()", "2:3"},
+		{"var f = fn(a) { a }\n  f()", "2:3"},
+		{"echo(\"x\")\nlen(1)", "2:1"},
+	}
+
+	for _, tt := range tests {
+		errObj, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned for %q", tt.input)
+			continue
+		}
+		if errObj.Pos.String() != tt.expected {
+			t.Errorf("wrong position for %q. expected=%s, got=%s", errObj.Message, tt.expected, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	input := `var c = fn(x) { len(x, 2) }
+var b = fn(x, n) { c(x) }
+var a = fn() { b("abc", [1, 2]) }
+a()`
+	errObj, ok := testEval(input).(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned")
+	}
+	expected := []string{
+		`len("abc", 2) at 1:17`,
+		`c("abc") at 2:20`,
+		`b("abc", [1, 2]) at 3:16`,
+		`a() at 4:1`,
+	}
+	if len(errObj.Stack) != len(expected) {
+		t.Fatalf("wrong stack depth. expected=%d, got=%d: %v", len(expected), len(errObj.Stack), errObj.Stack)
+	}
+	for i, frame := range errObj.Stack {
+		if frame.String() != expected[i] {
+			t.Errorf("wrong frame %d. expected=%q, got=%q", i, expected[i], frame.String())
+		}
+	}
+}
+
 func TestLetStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -286,6 +372,42 @@ func TestFunctionObject(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotations(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var x: int = 5; x;", 5},
+		{"var add = fn(a: int, b: int): int { a + b }; add(5, 5);", 10},
+		{"var f = fn(g: fn, x: int): int { g(x) }; f(fn(x) { x * 2 }, 3);", 6},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestDefaultAndVariadicParameters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"var f = fn(a, b = 10) { a + b }; f(1);", 11},
+		{"var f = fn(a, b = 10) { a + b }; f(1, 2);", 3},
+		{"var f = fn(a, b = a * 2) { a + b }; f(3);", 9},
+		{"var f = fn(a, ...rest) { len(rest) }; f(1);", 0},
+		{"var f = fn(a, ...rest) { len(rest) }; f(1, 2, 3);", 2},
+		{"var f = fn(...rest) { rest[1] }; f(1, 2, 3);", 2},
+		{"var f = fn(a, b, c) { a + b + c }; var xs = [2, 3]; f(1, ...xs);", 6},
+		{"var f = fn(...rest) { len(rest) }; f(...[1, 2], 3, ...[]);", 3},
+		{"len([1, ...[2, 3]])", 3},
+	}
+
+	for _, tt := range tests {
+		testIntegerObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
 func TestFunctionApplication(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -603,6 +725,71 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// pipeTest is a program with the output it should print, followed by any string, integer
+// or pipeline it returns, or with the error it should fail with
+type pipeTest struct {
+	input  string
+	expOut string
+	expErr string
+}
+
+// testPipes runs setup and then each test in the same environment, with a file /log in
+// an in-memory filesystem
+func testPipes(t *testing.T, setup string, tests []pipeTest) {
+	t.Helper()
+	fs := vfs.NewMem()
+	f, err := vfs.Create(fs, "/log")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	f.Write([]byte("error one\nok\nERROR two\nerror three\n"))
+	f.Close()
+	out := bytes.NewBuffer([]byte{})
+	env := object.NewEnvironment(object.Streams{
+		Stdin:  bytes.NewBuffer([]byte{}),
+		Stdout: out,
+		Stderr: bytes.NewBuffer([]byte{}),
+	})
+	env.FS = fs
+	env.WorkDir.Set("/")
+	if res := Eval(parser.New(lexer.New(setup)).ParseProgram(), env); isError(res) {
+		t.Fatalf("unexpected error: %s", res.(*object.Error).Message)
+	}
+	for _, tt := range tests {
+		out.Reset()
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		res := Eval(program, env)
+		if tt.expErr != "" {
+			if err, ok := res.(*object.Error); !ok || !strings.Contains(err.Message, tt.expErr) {
+				t.Errorf("%s: expected error %q, got %v", tt.input, tt.expErr, res)
+			}
+			continue
+		}
+		if err, ok := res.(*object.Error); ok {
+			t.Errorf("%s: unexpected error: %s", tt.input, err.Message)
+			continue
+		}
+		if pipes, ok := res.(*object.Pipes); ok {
+			piped, err := ioutil.ReadAll(pipes.Main)
+			if err != nil {
+				t.Errorf("%s: unexpected error: %v", tt.input, err)
+				continue
+			}
+			out.Write(piped)
+		}
+		got := out.String()
+		switch res := res.(type) {
+		case *object.String:
+			got += res.Value
+		case *object.Integer, *object.Pipeline:
+			got += res.Inspect()
+		}
+		if got != tt.expOut {
+			t.Errorf("%s: got %q, expected %q", tt.input, got, tt.expOut)
+		}
+	}
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -639,3 +826,127 @@ func testNullObject(t *testing.T, obj object.Object) bool {
 	}
 	return true
 }
+
+func TestStatementCalls(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []bool
+	}{
+		{`record()`, []bool{true}},
+		{`var x = record()`, []bool{false}},
+		{`len([record()])`, []bool{false}},
+		{`if (true) { record() }`, []bool{true}},
+		{`var f = fn() { record() }; f()`, []bool{true}},
+		{`record(record())`, []bool{false, true}},
+	}
+	for _, tt := range tests {
+		got := []bool{}
+		env := object.NewEnvironment(object.Streams{
+			Stdin:  bytes.NewBuffer([]byte{}),
+			Stdout: bytes.NewBuffer([]byte{}),
+			Stderr: bytes.NewBuffer([]byte{}),
+		})
+		env.Builtins["record"] = &object.Builtin{Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+			got = append(got, scope.Statement)
+			return func() object.Object { return NULL }, nil
+		}}
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		if err, ok := Eval(program, env).(*object.Error); ok {
+			t.Fatalf("%s: %s", tt.input, err.Message)
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected statement flags %v, got %v", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestAliases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`alias("size", "len()"); size("abc")`, 3},
+		{`alias("ll", "record(l, 1)"); ll(a, 2)`, "[l 1 a 2]"},
+		{`alias("ll", "record(l)"); var ll = 5; ll`, 5},
+		{`alias("ll", "record(l)"); var f = fn(l) { ll("g") }; f("nope")`, "[l g]"},
+		{`var v = "x"; alias("rv", "record(v)"); v = "y"; rv()`, "[x]"},
+		{`alias("ll", "record(l)"); unalias("ll"); ll()`, "identifier not found: ll"},
+		{`unalias("ll")`, "no alias named ll"},
+		{`alias("ll", "nope(l)")`, "alias ll: no builtin named nope"},
+		{`alias("ll", "1 + 2")`, `alias "1 + 2": expected a call to a builtin`},
+		{`alias("ll", "record(z)")`, "alias ll: identifier not found: z"},
+		{`alias("ll", "record(a: 1)")`, "alias ll: unexpected value type INTEGER for flag 'a' of `record`. Expected BOOLEAN"},
+		{`alias("b", "record(a)"); alias("a", "record(l)"); aliases()`, "alias(\"a\", \"record(l)\")\nalias(\"b\", \"record(a)\")\n"},
+	}
+	for _, tt := range tests {
+		testRecorded(t, tt.input, tt.expected)
+	}
+}
+
+func TestForwardedFlags(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`var f = fn(...rest) { record(...rest) }; f(l: true, n: 2, "x")`, "[l n:2 x]"},
+		{`var f = fn(...rest) { record(...rest) }; f(l: false, "x")`, "[x]"},
+		{`var f = fn(x) { record(x, "y") }; f(a: true)`, "[a y]"},
+		{`var l = "v"; var f = fn(...rest) { record(...rest) }; f(l)`, "[v]"},
+		{`var f = fn(...rest) { record(...rest) }; f(z: true)`, "unknown flag 'z' for `record`"},
+		{`var f = fn(...rest) { record(...rest) }; f(l: 1)`, "unexpected value type INTEGER for flag 'l' of `record`. Expected BOOLEAN"},
+		{`var f = fn(...rest) { record(...rest) }; f(n: "x")`, "unexpected value type STRING for flag 'n' of `record`. Expected INTEGER"},
+		// only named arguments are flags, so mistakes are still reported
+		{`var f = fn(x) { x }; f(undefinedVar)`, "identifier not found: undefinedVar"},
+		{`var f = fn(...rest) { record(...rest) }; f(l)`, "identifier not found: l"},
+		{`var g = fn(a, b) { a + b }; g(1, nosuch(2))`, "identifier not found: nosuch"},
+	}
+	for _, tt := range tests {
+		testRecorded(t, tt.input, tt.expected)
+	}
+}
+
+// testRecorded evaluates input with a `record` builtin, which returns its arguments as a string
+func testRecorded(t *testing.T, input string, expected interface{}) {
+	env := object.NewEnvironment(object.Streams{
+		Stdin:  bytes.NewBuffer([]byte{}),
+		Stdout: bytes.NewBuffer([]byte{}),
+		Stderr: bytes.NewBuffer([]byte{}),
+	})
+	env.Builtins["record"] = &object.Builtin{
+		Flags: []object.Flag{{Name: "l"}, {Name: "a"}, {Name: "n", ParamType: object.INTEGER_OBJ}},
+		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+			words := []string{}
+			for _, arg := range args {
+				if flag, ok := arg.(*object.Flag); ok {
+					word := flag.Name
+					if flag.Param != nil {
+						word += ":" + flag.Param.Inspect()
+					}
+					words = append(words, word)
+				} else {
+					words = append(words, arg.Inspect())
+				}
+			}
+			return func() object.Object {
+				return &object.String{Value: fmt.Sprint(words)}
+			}, nil
+		},
+	}
+	program := parser.New(lexer.New(input)).ParseProgram()
+	result := Eval(program, env)
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, result, int64(expected))
+	case string:
+		var got string
+		switch result := result.(type) {
+		case *object.Error:
+			got = result.Message
+		case *object.String:
+			got = result.Value
+		}
+		if got != expected {
+			t.Errorf("%s: expected %q, got %q", input, expected, got)
+		}
+	}
+}
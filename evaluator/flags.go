@@ -1,25 +1,175 @@
 package evaluator
 
 import (
-	"fmt"
-
+	"github.com/laher/smoosh/ast"
 	"github.com/laher/smoosh/object"
 )
 
-func flagFn(flag *object.Flag) object.Object {
-	return &object.Builtin{
-		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
-			if len(args) < 1 {
-				return nil, fmt.Errorf("Value not supplied for flag [%s]", flag.Name)
+// evalBuiltinArguments evaluates arguments to a builtin, resolving its flags.
+// Flags may be named (`n: 10`, `long: true`), or given in short form (`l`, `n(10)`) as long as
+// there's no variable with the same name. Variables are never shadowed by flags.
+func evalBuiltinArguments(
+	fn *object.Builtin,
+	name string,
+	exps []ast.Expression,
+	env *object.Environment,
+) []object.Object {
+	var result []object.Object
+
+	for _, e := range exps {
+		flag, value, isFlag := flagArgument(fn, e, env)
+		if !isFlag {
+			evaluated := evalExpressions([]ast.Expression{e}, env)
+			if len(evaluated) == 1 && isError(evaluated[0]) {
+				return evaluated
 			}
-			if flag.ParamType != args[0].Type() {
-				return nil, fmt.Errorf("Unexpected value type [%v] for flag [%s]. Expected [%v]", args[0].Type(), flag.Name, flag.ParamType)
+			for _, arg := range evaluated {
+				// flags forwarded by a function, e.g. through `...rest`
+				if forwarded, ok := arg.(*object.Flag); ok {
+					f, err := resolveFlag(fn, name, forwarded)
+					if err != nil {
+						return []object.Object{err}
+					}
+					if f != nil {
+						result = append(result, f)
+					}
+					continue
+				}
+				result = append(result, arg)
+			}
+			continue
+		}
+		f, err := evalFlag(flag, name, e, value, env)
+		if err != nil {
+			return []object.Object{err}
+		}
+		if f != nil {
+			result = append(result, f)
+		}
+	}
+
+	return result
+}
+
+// flagArgument returns the flag given by an argument, and the expression for its value (if any)
+func flagArgument(fn *object.Builtin, e ast.Expression, env *object.Environment) (*object.Flag, ast.Expression, bool) {
+	var ident *ast.Identifier
+	var value ast.Expression
+	switch e := e.(type) {
+	case *ast.NamedArgument:
+		flag, _ := fn.Flag(e.Name.Value)
+		// an unknown flag is reported by evalFlag
+		return flag, e.Value, true
+	case *ast.Identifier:
+		ident = e
+	case *ast.CallExpression:
+		ident, _ = e.Function.(*ast.Identifier)
+		if len(e.Arguments) == 1 {
+			value = e.Arguments[0]
+		}
+	}
+	if ident == nil {
+		return nil, nil, false
+	}
+	if _, ok := env.Get(ident.Value); ok {
+		return nil, nil, false
+	}
+	flag, ok := fn.Flag(ident.Value)
+	if !ok {
+		return nil, nil, false
+	}
+	return flag, value, true
+}
+
+// evalFlag returns a copy of the flag with any value set, or nil for a boolean flag set to false
+func evalFlag(flag *object.Flag, name string, e, value ast.Expression, env *object.Environment) (*object.Flag, *object.Error) {
+	named, isNamed := e.(*ast.NamedArgument)
+	if flag == nil {
+		return nil, newError("unknown flag '%s' for `%s`", named.Name.Value, name)
+	}
+	_, isCall := e.(*ast.CallExpression)
+	switch {
+	case !flag.TakesValue() && isCall:
+		return nil, newError("flag '%s' of `%s` does not take a value", flag.Name, name)
+	case flag.TakesValue() && value == nil:
+		return nil, newError("flag '%s' of `%s` needs a %s value", flag.Name, name, flag.ParamType)
+	case !flag.TakesValue() && !isNamed:
+		f, _ := flag.WithParam(nil)
+		return f, nil
+	}
+	val := Eval(value, env)
+	if isError(val) {
+		return nil, val.(*object.Error)
+	}
+	if !flag.TakesValue() {
+		b, ok := val.(*object.Boolean)
+		if !ok {
+			return nil, newError("unexpected value type %s for flag '%s' of `%s`. Expected %s", val.Type(), flag.Name, name, object.BOOLEAN_OBJ)
+		}
+		if !b.Value {
+			return nil, nil
+		}
+		f, _ := flag.WithParam(nil)
+		return f, nil
+	}
+	f, err := flag.WithParam(val)
+	if err != nil {
+		return nil, newError("unexpected value type %s for flag '%s' of `%s`. Expected %s", val.Type(), flag.Name, name, flag.ParamType)
+	}
+	return f, nil
+}
+
+// resolveFlag checks a flag value, forwarded by a function, against the flags of a builtin.
+// It returns nil for a boolean flag set to false.
+func resolveFlag(fn *object.Builtin, name string, forwarded *object.Flag) (*object.Flag, *object.Error) {
+	flag, ok := fn.Flag(forwarded.Name)
+	if !ok {
+		return nil, newError("unknown flag '%s' for `%s`", forwarded.Name, name)
+	}
+	val := forwarded.Param
+	if !flag.TakesValue() {
+		if val == nil {
+			f, _ := flag.WithParam(nil)
+			return f, nil
+		}
+		b, ok := val.(*object.Boolean)
+		if !ok {
+			return nil, newError("unexpected value type %s for flag '%s' of `%s`. Expected %s", val.Type(), flag.Name, name, object.BOOLEAN_OBJ)
+		}
+		if !b.Value {
+			return nil, nil
+		}
+		f, _ := flag.WithParam(nil)
+		return f, nil
+	}
+	if val == nil {
+		return nil, newError("flag '%s' of `%s` needs a %s value", flag.Name, name, flag.ParamType)
+	}
+	f, err := flag.WithParam(val)
+	if err != nil {
+		return nil, newError("unexpected value type %s for flag '%s' of `%s`. Expected %s", val.Type(), flag.Name, name, flag.ParamType)
+	}
+	return f, nil
+}
+
+// evalFunctionArguments evaluates arguments to a function. Named arguments (`long: true`,
+// `n: 10`) become flag values, so that a function can forward them to a builtin.
+func evalFunctionArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+	for _, e := range exps {
+		if named, ok := e.(*ast.NamedArgument); ok {
+			val := Eval(named.Value, env)
+			if isError(val) {
+				return []object.Object{val}
 			}
-			return func() object.Object {
-				// takes in an arg and adds it into the Flag.Param
-				flag.Param = args[0]
-				return flag
-			}, nil
-		},
+			result = append(result, &object.Flag{Name: named.Name.Value, Param: val})
+			continue
+		}
+		evaluated := evalExpressions([]ast.Expression{e}, env)
+		if len(evaluated) == 1 && isError(evaluated[0]) {
+			return evaluated
+		}
+		result = append(result, evaluated...)
 	}
+	return result
 }
@@ -51,8 +51,13 @@ func addMacro(stmt ast.Statement, env *object.Environment) {
 	env.Set(letStatement.Name.Value, macro)
 }
 
-func ExpandMacros(program ast.Node, env *object.Environment) ast.Node {
-	return ast.Modify(program, func(node ast.Node) ast.Node {
+// ExpandMacros replaces macro calls with the code they return. It stops at the first macro which fails.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var macroErr *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		if macroErr != nil {
+			return node
+		}
 		callExpression, ok := node.(*ast.CallExpression)
 		if !ok {
 			return node
@@ -70,11 +75,28 @@ func ExpandMacros(program ast.Node, env *object.Environment) ast.Node {
 
 		quote, ok := evaluated.(*object.Quote)
 		if !ok {
-			panic("we only support returning AST-nodes from macros")
+			err, ok := evaluated.(*object.Error)
+			if !ok {
+				err = newError("macro `%s` returned %s, not a quote", callExpression.Function.TokenLiteral(), evaluated.Type())
+				err.Pos = callExpression.Pos()
+			}
+			frameArgs := []object.Object{}
+			for _, arg := range args {
+				frameArgs = append(frameArgs, arg)
+			}
+			err.Stack = append(err.Stack, object.Frame{
+				Name:  callExpression.Function.TokenLiteral(),
+				Args:  frameArgs,
+				Pos:   callExpression.Pos(),
+				Macro: true,
+			})
+			macroErr = err
+			return node
 		}
 
 		return quote.Node
 	})
+	return expanded, macroErr
 }
 
 func isMacroCall(
@@ -110,7 +110,10 @@ func TestExpandMacros(t *testing.T) {
 
 		env := object.NewEnvironment(streams)
 		DefineMacros(program, env)
-		expanded := ExpandMacros(program, env)
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err.Message)
+		}
 
 		if expanded.String() != expected.String() {
 			t.Errorf("not equal. want=%q, got=%q",
@@ -119,6 +122,33 @@ func TestExpandMacros(t *testing.T) {
 	}
 }
 
+func TestExpandMacrosError(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		frame    string
+	}{
+		{"var bad = macro(a) { 1 + true };\nbad(x);", "type mismatch: INTEGER + BOOLEAN", "macro bad(quote(x)) at 2:1"},
+		{"var one = macro() { 1 };\none();", "macro `one` returned INTEGER, not a quote", "macro one() at 2:1"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(tt.input)
+		env := object.NewEnvironment(object.Streams{})
+		DefineMacros(program, env)
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Fatalf("expected an error for %q", tt.input)
+		}
+		if err.Message != tt.expected {
+			t.Errorf("wrong message. expected=%q, got=%q", tt.expected, err.Message)
+		}
+		if len(err.Stack) != 1 || err.Stack[0].String() != tt.frame {
+			t.Errorf("wrong stack. expected=%q, got=%v", tt.frame, err.Stack)
+		}
+	}
+}
+
 func testParseProgram(input string) *ast.Program {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -0,0 +1,149 @@
+package evaluator
+
+import (
+	"fmt"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// pipelineBuiltin is recognised by the evaluator, which turns calls in its arguments into
+// stages instead of running them. It's assigned in init, like bindBuiltin.
+var pipelineBuiltin *object.Builtin
+
+func init() {
+	pipelineBuiltin = &object.Builtin{
+		Fn: pipeline,
+		Help: `Usage: pipeline(STAGE...)
+Return a pipeline, which pipes each STAGE into the next when it's called, e.g.
+var p = pipeline(cat("a"), grep("x"), wc(l)); p(); p()
+A STAGE is a call of a builtin, which isn't run until the pipeline is, a builtin, or a
+pipeline whose stages are added, e.g. pipeline(p, head(n(1))) or pipeline(...ps).
+Arguments given to the pipeline follow those of its first stage.`,
+	}
+	stdlib.RegisterBuiltin("pipeline", pipelineBuiltin)
+	stdlib.RegisterBuiltin("stages", &object.Builtin{
+		Fn:    stages,
+		Arity: &object.Arity{Min: 1, Max: 1},
+		Help: `Usage: stages(PIPELINE)
+Return an array of PIPELINE's stages, each as a pipeline of its own.`,
+	})
+}
+
+// makesValue reports whether a builtin returns a value to use as a stage (e.g. bind), so
+// that calls to it are run by pipeline() rather than becoming stages themselves
+func makesValue(fn *object.Builtin) bool {
+	for _, name := range []string{"bind", "compose", "pipeline", "stages"} {
+		if b, ok := stdlib.GetFn(name); ok && b == fn {
+			return true
+		}
+	}
+	return false
+}
+
+// evalPipelineArguments evaluates the arguments to pipeline. Calls of builtins become
+// single-stage pipelines, with their arguments evaluated but the builtin not run.
+func evalPipelineArguments(exps []ast.Expression, env *object.Environment) []object.Object {
+	var result []object.Object
+	for _, e := range exps {
+		if call, ok := e.(*ast.CallExpression); ok {
+			fn := Eval(call.Function, env)
+			if isError(fn) {
+				return []object.Object{fn}
+			}
+			if builtin, ok := fn.(*object.Builtin); ok && !makesValue(builtin) {
+				name := call.Function.String()
+				args := evalBuiltinArguments(builtin, name, call.Arguments, env)
+				if len(args) == 1 && isError(args[0]) {
+					return args
+				}
+				stage := &object.Stage{Name: name, Fn: builtin, Args: args}
+				result = append(result, &object.Pipeline{Stages: []*object.Stage{stage}})
+				continue
+			}
+		}
+		evaluated := evalExpressions([]ast.Expression{e}, env)
+		if len(evaluated) == 1 && isError(evaluated[0]) {
+			return evaluated
+		}
+		if len(evaluated) == 1 {
+			// name a builtin after the expression which gave it
+			if builtin, ok := evaluated[0].(*object.Builtin); ok {
+				stage := &object.Stage{Name: e.String(), Fn: builtin}
+				evaluated[0] = &object.Pipeline{Stages: []*object.Stage{stage}}
+			}
+		}
+		result = append(result, evaluated...)
+	}
+	return result
+}
+
+func pipeline(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	p, err := joinStages("pipeline", args)
+	if err != nil {
+		return nil, err
+	}
+	return func() object.Object {
+		return p
+	}, nil
+}
+
+// joinStages returns a pipeline of the stages of each argument, which is a pipeline or a builtin
+func joinStages(name string, args []object.Object) (*object.Pipeline, error) {
+	p := &object.Pipeline{}
+	for i, arg := range args {
+		switch arg := arg.(type) {
+		case *object.Pipeline:
+			p.Stages = append(p.Stages, arg.Stages...)
+		case *object.Builtin:
+			p.Stages = append(p.Stages, &object.Stage{Name: "builtin", Fn: arg})
+		default:
+			return nil, fmt.Errorf("argument %d to `%s` must be a builtin or a pipeline, got %s", i+1, name, arg.Type())
+		}
+	}
+	return p, nil
+}
+
+func stages(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	p, ok := args[0].(*object.Pipeline)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 to `stages` not supported, got %s", args[0].Type())
+	}
+	elements := []object.Object{}
+	for _, stage := range p.Stages {
+		elements = append(elements, &object.Pipeline{Stages: []*object.Stage{stage}})
+	}
+	return func() object.Object {
+		return &object.Array{Elements: elements}
+	}, nil
+}
+
+// runPipeline pipes each stage of a pipeline into the next. The first stage reads in and
+// is given args after its own, and the last writes to out, as for a call of a single builtin.
+func runPipeline(p *object.Pipeline, args []object.Object, in, out *ast.Pipes, env *object.Environment, line int) object.Object {
+	if len(p.Stages) == 0 {
+		return newError("empty pipeline")
+	}
+	last := len(p.Stages) - 1
+	for i, stage := range p.Stages {
+		stageArgs := stage.Args
+		if i == 0 {
+			stageArgs = append(append([]object.Object{}, stage.Args...), args...)
+		}
+		stageOut := out
+		if i < last {
+			stageOut = &ast.Pipes{}
+		}
+		res := applyFunction(stage.Fn, stageArgs, in, stageOut, false, env, stage.Name, line)
+		if i == last || isError(res) {
+			return res
+		}
+		in = stageOut
+	}
+	return NULL
+}
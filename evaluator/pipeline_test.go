@@ -0,0 +1,66 @@
+package evaluator
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/laher/smoosh/object"
+)
+
+func TestPipeline(t *testing.T) {
+	testPipes(t, `var p = pipeline(cat("log"), grep(i, "error"), wc(l))`, []pipeTest{
+		{`p(); p()`, "3\n3\n", ""},
+		{`var run = fn(q) { q() }; run(p)`, "3\n", ""},
+		{`p`, `pipeline(cat("log"), grep(i, "error"), wc(l))`, ""},
+		{`len(stages(p))`, "3", ""},
+		{`stages(p)[1]`, `pipeline(grep(i, "error"))`, ""},
+		{`var errs = pipeline(grep(i, "error"), head(n(1))); cat("log") | errs()`, "error one\n", ""},
+		{`var errs = pipeline(grep("error")); errs(v, "log")`, "ok\nERROR two\n", ""},
+		{`var q = pipeline(cat("log")); q = pipeline(q, grep("ok")); q()`, "ok\n", ""},
+		{`var ps = [pipeline(cat("log")), pipeline(head(n(1)))]; var q = pipeline(...ps, wc); q`, `pipeline(cat("log"), head(n: 1), wc())`, ""},
+		{`pipeline()()`, "", "empty pipeline"},
+		{`pipeline(cat("log"), 1)`, "", "argument 2 to `pipeline` must be a builtin or a pipeline, got INTEGER"},
+		{`pipeline(grep(zz))`, "", "identifier not found: zz"},
+		{`stages(1)`, "", "argument 1 to `stages` not supported, got INTEGER"},
+	})
+}
+
+func TestPipelineValue(t *testing.T) {
+	evaluated := testEval(`pipeline(cat("log"), grep(i, "error"), wc)`)
+	p, ok := evaluated.(*object.Pipeline)
+	if !ok {
+		t.Fatalf("object is not Pipeline. got=%T (%+v)", evaluated, evaluated)
+	}
+	expected := []struct {
+		name string
+		args []string
+	}{
+		{"cat", []string{`"log"`}},
+		{"grep", []string{"i", `"error"`}},
+		{"wc", []string{}},
+	}
+	if len(p.Stages) != len(expected) {
+		t.Fatalf("expected %d stages, got %d", len(expected), len(p.Stages))
+	}
+	for i, stage := range p.Stages {
+		if stage.Name != expected[i].name {
+			t.Errorf("stage %d: expected name %s, got %s", i, expected[i].name, stage.Name)
+		}
+		args := []string{}
+		for _, arg := range stage.Args {
+			args = append(args, object.FormatArg(arg))
+		}
+		if !reflect.DeepEqual(args, expected[i].args) {
+			t.Errorf("stage %d: expected args %q, got %q", i, expected[i].args, args)
+		}
+	}
+
+	evaluated = testEval(`var p = pipeline(cat("log"), wc); stages(p)[0]`)
+	stage, ok := evaluated.(*object.Pipeline)
+	if !ok {
+		t.Fatalf("object is not Pipeline. got=%T (%+v)", evaluated, evaluated)
+	}
+	if len(stage.Stages) != 1 || stage.Stages[0].Name != "cat" {
+		t.Errorf("expected a pipeline of cat, got %s", stage.Inspect())
+	}
+}
@@ -0,0 +1,44 @@
+package evaluator
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// formatCall renders a builtin call for trace and dry-run output, with interpolated string arguments
+func formatCall(env *object.Environment, name string, args []object.Object) string {
+	var envV map[string]interface{}
+	items := []string{}
+	for _, arg := range args {
+		if str, ok := arg.(*object.String); ok {
+			// strings are shown as they'll be used
+			if envV == nil {
+				envV = env.Export()
+			}
+			s, err := stdlib.Interpolate(envV, str.Value)
+			if err == nil {
+				arg = &object.String{Value: s}
+			}
+		}
+		items = append(items, object.FormatArg(arg))
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(items, ", "))
+}
+
+// dryRun logs a mutating builtin instead of running it. Piped output is left empty.
+func dryRun(env *object.Environment, name string, args []object.Object, line int, out *ast.Pipes) object.Object {
+	if env.Options.Log != nil {
+		fmt.Fprintf(env.Options.Log, "dry-run: %d: %s\n", line, formatCall(env, name, args))
+	}
+	if out != nil {
+		out.Main = ioutil.NopCloser(strings.NewReader(""))
+		out.Err = ioutil.NopCloser(strings.NewReader(""))
+		out.Wait = func() error { return nil }
+	}
+	return NULL
+}
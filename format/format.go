@@ -0,0 +1,43 @@
+// Package format formats smoosh source code in a standard style.
+//
+// Formatting is lossless: comments are kept where they were written, as are single
+// blank lines between statements, and line breaks within argument lists. Formatting
+// formatted source makes no further changes.
+package format
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/parser"
+)
+
+// Source formats source code, returning any syntax errors, one per line
+func Source(src []byte) ([]byte, error) {
+	p := parser.New(lexer.New(string(src)))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	var buf bytes.Buffer
+	if err := Fprint(&buf, program); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Fprint writes a formatted program, along with its comments, to w
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := &printer{comments: program.Comments}
+	p.statements(program.Statements)
+	p.flush(endOfFile)
+	if p.out.Len() > 0 {
+		p.out.WriteString("\n")
+	}
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
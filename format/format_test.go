@@ -0,0 +1,87 @@
+package format
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"spacing", "var x=1+2\necho( x,\"y\" )", "var x = 1 + 2\necho(x, \"y\")\n"},
+		{"indentation", "if (x) {\n  echo(1)\n} else {\necho(2)}", "if (x) {\n\techo(1)\n} else {\n\techo(2)\n}\n"},
+		{"empty block", "fn() {  }", "fn() {}\n"},
+		{"blank lines", "echo(1)\n\n\n\necho(2)\necho(3)", "echo(1)\n\necho(2)\necho(3)\n"},
+		{"no blank line after brace", "if (x) {\n\n  echo(1)\n}", "if (x) {\n\techo(1)\n}\n"},
+		{"leading comments", "#!/usr/bin/smoosh\n\n# say hi\necho(\"hi\")", "#!/usr/bin/smoosh\n\n# say hi\necho(\"hi\")\n"},
+		{"trailing comments", "var x = 1   # one  \necho(x)#two", "var x = 1 # one\necho(x) #two\n"},
+		{"comments in a block", "fn() { # start\n# inside\n  echo(1)\n    # end\n}", "fn() { # start\n\t# inside\n\techo(1)\n\t# end\n}\n"},
+		{"only comments", "# just\n# comments", "# just\n# comments\n"},
+		{"broken arguments", "echo(1,\n2, 3,\n  n: 4)", "echo(1,\n\t2, 3,\n\tn: 4)\n"},
+		{"comments in arguments", "echo(1, # one\n  2 # two\n)", "echo(1, # one\n\t2 # two\n)\n"},
+		{"pipes", "ls()|grep(\"x\")  |  wc()\nls()\n|wc()", "ls() | grep(\"x\") | wc()\nls()\n\t| wc()\n"},
+		{"precedence", "(1 + 2) * 3 - (4 - 5) + -(6 + 7)", "(1 + 2) * 3 - (4 - 5) + -(6 + 7)\n"},
+		{"redundant parentheses", "((1 * 2)) + (3)", "1 * 2 + 3\n"},
+		{"semicolons", "echo(1);\n(fn() { 2 })();\nx;\n[1][0];\n-(1 + 2);\n(1 + 2) * 3", "echo(1)\nfn() {\n\t2\n}()\nx;\n[1][0];\n-(1 + 2);\n(1 + 2) * 3\n"},
+		{"hash order", `{"b": 1, "a": 2}`, "{\"b\": 1, \"a\": 2}\n"},
+		{"functions", "var f=fn(a:int,b=2,...c):int{return a}", "var f = fn(a: int, b = 2, ...c): int {\n\treturn a\n}\n"},
+		{"loops", "for(i=0;i<3;i=i+1){echo(i)}\nrange(k,v=[1]){echo(v)}", "for (i = 0; i < 3; i = i + 1) {\n\techo(i)\n}\nrange (k, v = [1]) {\n\techo(v)\n}\n"},
+		{"spread and index", "echo(... x, y[0], !z)", "echo(...x, y[0], !z)\n"},
+		{"strings", "echo(\"a\\tb\", `c\nd`)", "echo(\"a\\tb\", `c\nd`)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Source([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Fatalf("expected:\n%s\ngot:\n%s", tt.expected, got)
+			}
+			again, err := Source(got)
+			if err != nil {
+				t.Fatalf("unexpected error formatting again: %v", err)
+			}
+			if string(again) != string(got) {
+				t.Errorf("not idempotent. expected:\n%s\ngot:\n%s", got, again)
+			}
+		})
+	}
+}
+
+func TestSourceError(t *testing.T) {
+	_, err := Source([]byte("echo(1"))
+	if err == nil {
+		t.Fatal("expected a syntax error")
+	}
+}
+
+func TestIdempotent(t *testing.T) {
+	files, err := filepath.Glob("../testdata/*.smoosh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		t.Run(f, func(t *testing.T) {
+			src, err := ioutil.ReadFile(f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			once, err := Source(src)
+			if err != nil {
+				t.Fatal(err)
+			}
+			twice, err := Source(once)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(once) != string(twice) {
+				t.Errorf("expected:\n%s\ngot:\n%s", once, twice)
+			}
+		})
+	}
+}
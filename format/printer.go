@@ -0,0 +1,486 @@
+package format
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/token"
+)
+
+// endOfFile is after every position in a file
+var endOfFile = token.Position{Line: int(^uint(0) >> 1)}
+
+// operator precedences, matching the parser's
+const (
+	lowest = iota
+	equals
+	lessGreater
+	sum
+	product
+	prefix
+	primary
+)
+
+var precedences = map[string]int{
+	"==": equals,
+	"!=": equals,
+	"<":  lessGreater,
+	">":  lessGreater,
+	"+":  sum,
+	"-":  sum,
+	"*":  product,
+	"/":  product,
+}
+
+type printer struct {
+	out      bytes.Buffer
+	indent   int
+	comments []*ast.Comment
+	next     int // index of the next comment to print
+	// line is the source line of the last thing printed. It decides whether a comment
+	// trails some code, or sits on its own line.
+	line int
+	// afterOpen is set after an opening brace, where blank lines are dropped
+	afterOpen bool
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+// newline starts a new, indented line, after a blank line if blank is set
+func (p *printer) newline(blank bool) {
+	if p.out.Len() == 0 {
+		return
+	}
+	if blank && !p.afterOpen {
+		p.out.WriteString("\n")
+	}
+	p.afterOpen = false
+	p.out.WriteString("\n" + strings.Repeat("\t", p.indent))
+}
+
+func (p *printer) setLine(line int) {
+	if line > p.line {
+		p.line = line
+	}
+}
+
+func (p *printer) hasCommentBefore(pos token.Position) bool {
+	if p.next >= len(p.comments) || !pos.IsValid() {
+		return false
+	}
+	c := p.comments[p.next].Pos()
+	return c.Line < pos.Line || c.Line == pos.Line && c.Column < pos.Column
+}
+
+// flush prints the comments which come before pos. A comment on the same line as the
+// last thing printed stays at the end of that line.
+func (p *printer) flush(pos token.Position) {
+	for p.hasCommentBefore(pos) {
+		c := p.comments[p.next]
+		p.next++
+		if c.Token.Line == p.line && p.out.Len() > 0 {
+			p.out.Truncate(len(bytes.TrimRight(p.out.Bytes(), " ")))
+			p.write(" ")
+		} else {
+			p.newline(c.Token.Line-p.line > 1)
+		}
+		p.write(c.Text())
+		p.line = c.Token.Line
+	}
+}
+
+// statements prints one statement per line, apart from the stages of a pipeline which
+// were written on one line. A single blank line between statements is kept.
+func (p *printer) statements(statements []ast.Statement) {
+	for i, stmt := range statements {
+		pos := stmt.Pos()
+		if i > 0 && needsSemicolon(stmt) {
+			p.write(";")
+		}
+		if i > 0 && isPipe(stmt) && pos.Line == p.line && !p.hasCommentBefore(pos) {
+			p.write(" ")
+		} else if i > 0 && isPipe(stmt) {
+			// a stage on a line of its own continues the pipeline
+			p.flush(pos)
+			p.indent++
+			p.newline(false)
+			p.indent--
+		} else {
+			p.flush(pos)
+			p.newline(pos.Line-p.line > 1)
+		}
+		p.statement(stmt)
+		p.setLine(endLine(stmt))
+	}
+}
+
+func (p *printer) statement(stmt ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.ExpressionStatement:
+		p.expr(stmt.Expression)
+	case *ast.AssignStatement:
+		if stmt.Token.Type == token.VAR {
+			p.write("var ")
+		}
+		p.write(stmt.Name.Value)
+		if stmt.Name.Type != nil {
+			p.write(": " + stmt.Name.Type.Name)
+		}
+		p.write(" = ")
+		p.expr(stmt.Value)
+	case *ast.ReturnStatement:
+		p.write("return")
+		if stmt.ReturnValue != nil {
+			p.write(" ")
+			p.expr(stmt.ReturnValue)
+		}
+	case *ast.BlockStatement:
+		p.block(stmt)
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.write("{")
+	p.setLine(block.Token.Line)
+	if len(block.Statements) == 0 && !p.hasCommentBefore(block.Rbrace.Pos()) {
+		p.write("}")
+		p.setLine(block.Rbrace.Line)
+		return
+	}
+	p.indent++
+	p.afterOpen = true
+	p.statements(block.Statements)
+	p.flush(block.Rbrace.Pos())
+	p.indent--
+	p.newline(false)
+	p.write("}")
+	p.setLine(block.Rbrace.Line)
+}
+
+func (p *printer) expr(exp ast.Expression) {
+	if exp == nil {
+		return
+	}
+	pos := exp.Pos()
+	if p.hasCommentBefore(pos) {
+		p.indent++
+		p.flush(pos)
+		p.newline(false)
+		p.indent--
+	}
+	p.setLine(pos.Line)
+
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		p.write(exp.Value)
+	case *ast.IntegerLiteral:
+		p.write(exp.Token.Literal)
+	case *ast.Boolean:
+		p.write(exp.Token.Literal)
+	case *ast.StringLiteral:
+		p.write(`"` + exp.Value + `"`)
+	case *ast.BacktickLiteral:
+		p.write("`" + exp.Value + "`")
+	case *ast.PrefixExpression:
+		p.write(exp.Operator)
+		p.operand(exp.Right, prefix)
+	case *ast.InfixExpression:
+		prec := precedences[exp.Operator]
+		p.operand(exp.Left, prec)
+		p.write(" " + exp.Operator + " ")
+		// operators are left-associative
+		p.operand(exp.Right, prec+1)
+	case *ast.SpreadExpression:
+		p.write("...")
+		p.operand(exp.Value, prefix)
+	case *ast.NamedArgument:
+		p.write(exp.Name.Value + ": ")
+		p.expr(exp.Value)
+	case *ast.PipeExpression:
+		p.write("| ")
+		if exp.Destination != nil {
+			p.expr(exp.Destination)
+		} else {
+			p.expr(exp.Target)
+		}
+	case *ast.CallExpression:
+		p.operand(exp.Function, primary)
+		p.write("(")
+		p.expressions(exp.Token, exp.Arguments, exp.Rparen)
+		p.write(")")
+	case *ast.IndexExpression:
+		p.operand(exp.Left, primary)
+		p.write("[")
+		p.expr(exp.Index)
+		p.write("]")
+	case *ast.ArrayLiteral:
+		p.write("[")
+		p.expressions(exp.Token, exp.Elements, exp.Rbracket)
+		p.write("]")
+	case *ast.HashLiteral:
+		p.hash(exp)
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expr(exp.Condition)
+		p.write(") ")
+		p.block(exp.Consequence)
+		if exp.Alternative != nil {
+			p.write(" else ")
+			p.block(exp.Alternative)
+		}
+	case *ast.ForExpression:
+		p.write("for (")
+		p.statement(exp.Init)
+		p.write("; ")
+		p.expr(exp.Condition)
+		p.write("; ")
+		p.statement(exp.After)
+		p.write(") ")
+		p.block(exp.Body)
+	case *ast.RangeExpression:
+		p.write("range (")
+		p.expr(exp.Identifier)
+		if exp.Iteree != nil {
+			p.write(", ")
+			p.expr(exp.Iteree)
+		}
+		p.write(" = ")
+		p.expr(exp.Iterator)
+		p.write(") ")
+		p.block(exp.Body)
+	case *ast.FunctionLiteral:
+		p.write("fn(")
+		p.parameters(exp.Parameters)
+		p.write(")")
+		if exp.ReturnType != nil {
+			p.write(": " + exp.ReturnType.Name)
+		}
+		p.write(" ")
+		p.block(exp.Body)
+	case *ast.MacroLiteral:
+		p.write("macro(")
+		p.parameters(exp.Parameters)
+		p.write(") ")
+		p.block(exp.Body)
+	}
+}
+
+// operand prints an expression, in parentheses if it binds less tightly than min
+func (p *printer) operand(exp ast.Expression, min int) {
+	if precedence(exp) < min {
+		p.write("(")
+		p.expr(exp)
+		p.write(")")
+		return
+	}
+	p.expr(exp)
+}
+
+func precedence(exp ast.Expression) int {
+	switch exp := exp.(type) {
+	case *ast.InfixExpression:
+		return precedences[exp.Operator]
+	case *ast.PrefixExpression, *ast.SpreadExpression:
+		return prefix
+	case *ast.PipeExpression:
+		return lowest
+	}
+	return primary
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	for i, param := range params {
+		if i > 0 {
+			p.write(", ")
+		}
+		if param.Variadic {
+			p.write("...")
+		}
+		p.write(param.Value)
+		if param.Type != nil {
+			p.write(": " + param.Type.Name)
+		}
+		if param.Default != nil {
+			p.write(" = ")
+			p.expr(param.Default)
+		}
+	}
+}
+
+func (p *printer) expressions(open token.Token, list []ast.Expression, close token.Token) {
+	p.list(open, close, len(list),
+		func(i int) token.Position { return list[i].Pos() },
+		func(i int) int { return endLine(list[i]) },
+		func(i int) { p.expr(list[i]) })
+}
+
+func (p *printer) hash(hash *ast.HashLiteral) {
+	keys := hash.Keys
+	if len(keys) != len(hash.Pairs) {
+		// not parsed from source, so the order is unknown
+		keys = []ast.Expression{}
+		for key := range hash.Pairs {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	}
+	p.write("{")
+	p.list(hash.Token, hash.Rbrace, len(keys),
+		func(i int) token.Position { return keys[i].Pos() },
+		func(i int) int { return endLine(hash.Pairs[keys[i]]) },
+		func(i int) {
+			p.expr(keys[i])
+			p.write(": ")
+			p.expr(hash.Pairs[keys[i]])
+		})
+	p.write("}")
+}
+
+// list prints comma-separated items. When the source broke the list over several lines,
+// or it contains comments, items which started a new line still do, as does the closing
+// bracket.
+func (p *printer) list(open, close token.Token, n int, pos func(int) token.Position, end func(int) int, item func(int)) {
+	p.setLine(open.Line)
+	broken := p.hasCommentBefore(close.Pos())
+	line := open.Line
+	for i := 0; i < n && !broken; i++ {
+		broken = line > 0 && pos(i).Line > line
+		line = end(i)
+	}
+
+	if broken {
+		p.indent++
+	}
+	line = open.Line
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			p.write(",")
+		}
+		if broken && (pos(i).Line > line || p.hasCommentBefore(pos(i))) {
+			p.flush(pos(i))
+			p.newline(false)
+		} else if i > 0 {
+			p.write(" ")
+		}
+		item(i)
+		line = end(i)
+		p.setLine(line)
+	}
+	if broken {
+		ownLine := p.hasCommentBefore(close.Pos()) || close.Line > line
+		p.flush(close.Pos())
+		p.indent--
+		if ownLine {
+			p.newline(false)
+		}
+	}
+}
+
+// needsSemicolon reports whether a statement could otherwise be read as continuing the
+// one before it, e.g. as a call or an index, or a subtraction
+func needsSemicolon(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	switch leading(es.Expression) {
+	case '(', '[', '-':
+		return true
+	}
+	return false
+}
+
+// leading returns the first character printed for an expression, if it is punctuation
+func leading(exp ast.Expression) byte {
+	switch exp := exp.(type) {
+	case *ast.PrefixExpression:
+		return exp.Operator[0]
+	case *ast.InfixExpression:
+		return leadingOperand(exp.Left, precedences[exp.Operator])
+	case *ast.CallExpression:
+		return leadingOperand(exp.Function, primary)
+	case *ast.IndexExpression:
+		return leadingOperand(exp.Left, primary)
+	case *ast.ArrayLiteral:
+		return '['
+	}
+	return 0
+}
+
+func leadingOperand(exp ast.Expression, min int) byte {
+	if precedence(exp) < min {
+		return '('
+	}
+	return leading(exp)
+}
+
+// isPipe reports whether a statement is a stage of a pipeline, following the one before it
+func isPipe(stmt ast.Statement) bool {
+	es, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+	_, ok = es.Expression.(*ast.PipeExpression)
+	return ok
+}
+
+// endLine returns the source line on which a node ends
+func endLine(node ast.Node) int {
+	switch node := node.(type) {
+	case nil:
+		return 0
+	case *ast.ExpressionStatement:
+		return endLine(node.Expression)
+	case *ast.AssignStatement:
+		return endLine(node.Value)
+	case *ast.ReturnStatement:
+		if node.ReturnValue != nil {
+			return endLine(node.ReturnValue)
+		}
+	case *ast.BlockStatement:
+		return node.Rbrace.Line
+	case *ast.CallExpression:
+		return node.Rparen.Line
+	case *ast.IndexExpression:
+		return node.Rbracket.Line
+	case *ast.ArrayLiteral:
+		return node.Rbracket.Line
+	case *ast.HashLiteral:
+		return node.Rbrace.Line
+	case *ast.StringLiteral:
+		return node.Token.Line + strings.Count(node.Value, "\n")
+	case *ast.BacktickLiteral:
+		return node.Token.Line + strings.Count(node.Value, "\n")
+	case *ast.PrefixExpression:
+		return endLine(node.Right)
+	case *ast.InfixExpression:
+		return endLine(node.Right)
+	case *ast.SpreadExpression:
+		return endLine(node.Value)
+	case *ast.NamedArgument:
+		return endLine(node.Value)
+	case *ast.PipeExpression:
+		if node.Destination != nil {
+			return endLine(node.Destination)
+		}
+		return endLine(node.Target)
+	case *ast.IfExpression:
+		if node.Alternative != nil {
+			return endLine(node.Alternative)
+		}
+		return endLine(node.Consequence)
+	case *ast.ForExpression:
+		return endLine(node.Body)
+	case *ast.RangeExpression:
+		return endLine(node.Body)
+	case *ast.FunctionLiteral:
+		return endLine(node.Body)
+	case *ast.MacroLiteral:
+		return endLine(node.Body)
+	}
+	return node.Pos().Line
+}
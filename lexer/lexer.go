@@ -7,6 +7,7 @@ These tokens are operators, keywords, identifiers or values (strings, numbers, e
 package lexer
 
 import (
+	"strings"
 	"unicode/utf8"
 
 	"github.com/laher/smoosh/token"
@@ -19,20 +20,35 @@ type Lexer struct {
 	readPosition int  // current reading position in input (after current rune)
 	ru           rune // current rune under examination
 	line         int  //current line number
+	lineStart    int  // position of the first rune of the current line
+	file         string
 }
 
 // New creates and initialises a new lexer
 func New(input string) *Lexer {
-	l := &Lexer{input: input, line: 1}
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer whose tokens record the name of the file they came from
+func NewFile(filename, input string) *Lexer {
+	l := &Lexer{input: input, line: 1, file: filename}
 	l.readRune()
 	return l
 }
 
 // NextToken attempts to find the next token in the program's input
 func (l *Lexer) NextToken() token.Token {
-	var tok token.Token
-
 	l.skipWhitespace()
+	line, col := l.line, l.column()
+	tok := l.nextToken()
+	tok.File = l.file
+	tok.Line = line
+	tok.Column = col
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.ru {
 	case '=':
@@ -40,57 +56,61 @@ func (l *Lexer) NextToken() token.Token {
 			ch := l.ru
 			l.readRune()
 			literal := string(ch) + string(l.ru)
-			tok = token.Token{Type: token.EQ, Literal: literal, Line: l.line}
+			tok = token.Token{Type: token.EQ, Literal: literal}
 		} else {
-			tok = newToken(token.ASSIGN, l.ru, l.line)
+			tok = newToken(token.ASSIGN, l.ru)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ru, l.line)
+		tok = newToken(token.PLUS, l.ru)
 	case '-':
-		tok = newToken(token.MINUS, l.ru, l.line)
+		tok = newToken(token.MINUS, l.ru)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ru
 			l.readRune()
 			literal := string(ch) + string(l.ru)
-			tok = token.Token{Type: token.NOT_EQ, Literal: literal, Line: l.line}
+			tok = token.Token{Type: token.NOT_EQ, Literal: literal}
 		} else {
-			tok = newToken(token.BANG, l.ru, l.line)
+			tok = newToken(token.BANG, l.ru)
 		}
 	case '/':
-		tok = newToken(token.SLASH, l.ru, l.line)
+		tok = newToken(token.SLASH, l.ru)
 	case '*':
-		tok = newToken(token.ASTERISK, l.ru, l.line)
+		tok = newToken(token.ASTERISK, l.ru)
 	case '<':
-		tok = newToken(token.LT, l.ru, l.line)
+		tok = newToken(token.LT, l.ru)
 	case '>':
-		tok = newToken(token.GT, l.ru, l.line)
+		tok = newToken(token.GT, l.ru)
 	case ';':
-		tok = newToken(token.SEMICOLON, l.ru, l.line)
+		tok = newToken(token.SEMICOLON, l.ru)
 	case ':':
-		tok = newToken(token.COLON, l.ru, l.line)
+		tok = newToken(token.COLON, l.ru)
 	case ',':
-		tok = newToken(token.COMMA, l.ru, l.line)
+		tok = newToken(token.COMMA, l.ru)
 	case '{':
-		tok = newToken(token.LBRACE, l.ru, l.line)
+		tok = newToken(token.LBRACE, l.ru)
 	case '}':
-		tok = newToken(token.RBRACE, l.ru, l.line)
+		tok = newToken(token.RBRACE, l.ru)
 	case '(':
-		tok = newToken(token.LPAREN, l.ru, l.line)
+		tok = newToken(token.LPAREN, l.ru)
 	case ')':
-		tok = newToken(token.RPAREN, l.ru, l.line)
+		tok = newToken(token.RPAREN, l.ru)
 	case '"':
 		tok.Type = token.STRING
 		tok.Literal = l.readString()
 	case '[':
-		tok = newToken(token.LBRACKET, l.ru, l.line)
+		tok = newToken(token.LBRACKET, l.ru)
 	case ']':
-		tok = newToken(token.RBRACKET, l.ru, l.line)
+		tok = newToken(token.RBRACKET, l.ru)
 	case '|':
-		tok = newToken(token.PIPE, l.ru, l.line)
+		tok = newToken(token.PIPE, l.ru)
 	case '#':
-		tok = newToken(token.HASH, l.ru, l.line)
+		tok = newToken(token.HASH, l.ru)
 		tok.Literal = l.readLine()
+		if l.ru == '\n' {
+			// the newline is consumed along with the comment
+			l.newLine()
+		}
 	case '`':
 		tok.Type = token.BACKY
 		tok.Literal = l.readUntil('`')
@@ -98,6 +118,14 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Literal = ""
 		tok.Type = token.EOF
 	default:
+		if strings.HasPrefix(l.input[l.position:], "...") {
+			tok.Type = token.ELLIPSIS
+			tok.Literal = "..."
+			l.readRune()
+			l.readRune()
+			l.readRune()
+			return tok
+		}
 		if isLetter(l.ru) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
@@ -108,7 +136,7 @@ func (l *Lexer) NextToken() token.Token {
 			tok.Literal = l.readNumber()
 			return tok
 		}
-		tok = newToken(token.ILLEGAL, l.ru, l.line)
+		tok = newToken(token.ILLEGAL, l.ru)
 	}
 
 	l.readRune()
@@ -118,12 +146,22 @@ func (l *Lexer) NextToken() token.Token {
 func (l *Lexer) skipWhitespace() {
 	for l.ru == ' ' || l.ru == '\t' || l.ru == '\n' || l.ru == '\r' {
 		if l.ru == '\n' {
-			l.line++
+			l.newLine()
 		}
 		l.readRune()
 	}
 }
 
+// newLine is called when the current rune is a newline
+func (l *Lexer) newLine() {
+	l.line++
+	l.lineStart = l.position + 1
+}
+
+func (l *Lexer) column() int {
+	return utf8.RuneCountInString(l.input[l.lineStart:l.position]) + 1
+}
+
 func (l *Lexer) readRune() {
 	var ln int
 	l.ru, ln = utf8.DecodeRuneInString(l.input[l.readPosition:])
@@ -155,6 +193,9 @@ func (l *Lexer) readUntil(ru rune) string {
 		if l.ru == ru || l.ru == utf8.RuneError || l.ru == 0 {
 			break
 		}
+		if l.ru == '\n' {
+			l.newLine()
+		}
 	}
 	return l.input[position:l.position]
 }
@@ -189,6 +230,6 @@ func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func newToken(tokenType token.TokenType, ch rune, line int) token.Token {
-	return token.Token{Type: tokenType, Literal: string(ch), Line: line}
+func newToken(tokenType token.TokenType, ch rune) token.Token {
+	return token.Token{Type: tokenType, Literal: string(ch)}
 }
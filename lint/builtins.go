@@ -0,0 +1,37 @@
+package lint
+
+import (
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// builtinCalls calls f for each call to a builtin, where the name isn't a variable
+func builtinCalls(pass *Pass, f func(name string, builtin *object.Builtin, call *ast.CallExpression)) {
+	refs := pass.variables().refs
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		if ident, ok := call.Function.(*ast.Identifier); ok && refs[ident] == nil {
+			if builtin, ok := stdlib.GetFn(ident.Value); ok {
+				f(ident.Value, builtin, call)
+			}
+		}
+		return true
+	})
+}
+
+// flagIdentifier returns the name of an argument which is written as a flag, e.g. `l`
+// or `n(5)`
+func flagIdentifier(arg ast.Expression) (*ast.Identifier, bool) {
+	switch arg := arg.(type) {
+	case *ast.Identifier:
+		return arg, true
+	case *ast.CallExpression:
+		ident, ok := arg.Function.(*ast.Identifier)
+		return ident, ok
+	}
+	return nil, false
+}
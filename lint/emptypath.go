@@ -0,0 +1,46 @@
+package lint
+
+import (
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+)
+
+func init() {
+	Register(&Rule{
+		Name: "emptypath",
+		Help: "recursive rm of a path built from variables, which could remove far more than meant if they are empty",
+		Run:  emptyPath,
+	})
+}
+
+func emptyPath(pass *Pass) {
+	refs := pass.variables().refs
+	builtinCalls(pass, func(name string, builtin *object.Builtin, call *ast.CallExpression) {
+		if name != "rm" || !isRecursive(call, refs) {
+			return
+		}
+		for _, arg := range call.Arguments {
+			if s, ok := arg.(*ast.StringLiteral); ok && len(templateActions(s.Value)) > 0 {
+				pass.Reportf(s.Pos(), "recursive rm of %q, which could remove the wrong files if an interpolated value is empty", s.Value)
+			}
+		}
+	})
+}
+
+// isRecursive reports whether a call to rm has the `r` flag
+func isRecursive(call *ast.CallExpression, refs map[*ast.Identifier]*variable) bool {
+	for _, arg := range call.Arguments {
+		switch arg := arg.(type) {
+		case *ast.Identifier:
+			if (arg.Value == "r" || arg.Value == "recursive") && refs[arg] == nil {
+				return true
+			}
+		case *ast.NamedArgument:
+			if arg.Name.Value == "r" || arg.Name.Value == "recursive" {
+				b, ok := arg.Value.(*ast.Boolean)
+				return !ok || b.Value
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,30 @@
+package lint
+
+import (
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+)
+
+func init() {
+	Register(&Rule{
+		Name: "flagshadow",
+		Help: "variables named like a builtin's flag, which are passed to it as values instead of the flag",
+		Run:  flagShadow,
+	})
+}
+
+func flagShadow(pass *Pass) {
+	refs := pass.variables().refs
+	builtinCalls(pass, func(name string, builtin *object.Builtin, call *ast.CallExpression) {
+		for _, arg := range call.Arguments {
+			ident, ok := flagIdentifier(arg)
+			if !ok || refs[ident] == nil {
+				continue
+			}
+			if _, ok := builtin.Flag(ident.Value); ok {
+				pass.Reportf(ident.Pos(), "'%s' is a variable, so it is passed to `%s` as a value rather than as its flag `%s`",
+					ident.Value, name, ident.Value)
+			}
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package lint
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+)
+
+func init() {
+	Register(&Rule{
+		Name: "glob",
+		Help: "unquoted glob patterns in external commands, which `$` passes on without expanding",
+		Run:  glob,
+	})
+}
+
+func glob(pass *Pass) {
+	builtinCalls(pass, func(name string, builtin *object.Builtin, call *ast.CallExpression) {
+		if name != "$" {
+			return
+		}
+		for i, arg := range call.Arguments {
+			var value string
+			switch arg := arg.(type) {
+			case *ast.StringLiteral:
+				value = arg.Value
+			case *ast.BacktickLiteral:
+				value = arg.Value
+			default:
+				continue
+			}
+			for j, w := range commandWords(value) {
+				if i == 0 && j == 0 || w.quoted {
+					// the command itself, or a quoted word
+					continue
+				}
+				if strings.ContainsAny(w.text, "*?[") {
+					pass.Reportf(arg.Pos(), "glob pattern %s is passed to the command as it is, because `$` doesn't expand globs", w.text)
+				}
+			}
+		}
+	})
+}
+
+type word struct {
+	text   string
+	quoted bool
+}
+
+// commandWords splits a command line into words as `$` does
+func commandWords(s string) []word {
+	words := []word{}
+	current := word{}
+	quote := rune(0)
+	for _, ru := range s + " " {
+		switch {
+		case ru == quote:
+			quote = 0
+			current.text += string(ru)
+		case quote != 0:
+			current.text += string(ru)
+		case unicode.In(ru, unicode.Quotation_Mark):
+			quote = ru
+			current.quoted = true
+			current.text += string(ru)
+		case unicode.IsSpace(ru):
+			if current.text != "" {
+				words = append(words, current)
+			}
+			current = word{}
+		default:
+			current.text += string(ru)
+		}
+	}
+	return words
+}
@@ -0,0 +1,159 @@
+// Package lint reports likely mistakes in smoosh programs: code which is legal, and
+// which the checker accepts, but which probably doesn't do what was meant.
+//
+// Each kind of mistake is found by a Rule. A rule can be disabled for a whole file with
+// a comment such as `# lint:disable unused`, or for a single line with
+// `# lint:ignore unused`, at the end of the line or on the line before it. Several rules
+// may be listed, separated by commas. Without any, all rules are disabled.
+package lint
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/token"
+)
+
+// Problem is a likely mistake, found by a rule
+type Problem struct {
+	Pos  token.Position
+	Rule string
+	Msg  string
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("%s: %s (%s)", p.Pos, p.Msg, p.Rule)
+}
+
+// Rule finds one kind of mistake
+type Rule struct {
+	Name string
+	Help string
+	Run  func(pass *Pass)
+}
+
+var rules = map[string]*Rule{}
+
+// Register registers a rule, which is run by Lint
+func Register(rule *Rule) {
+	if _, ok := rules[rule.Name]; ok {
+		panic("lint rule '" + rule.Name + "' already defined")
+	}
+	rules[rule.Name] = rule
+}
+
+// Rules returns the registered rules, sorted by name
+func Rules() []*Rule {
+	all := []*Rule{}
+	for _, rule := range rules {
+		all = append(all, rule)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Pass is a single rule's view of the program being linted
+type Pass struct {
+	Program *ast.Program
+	rule    *Rule
+	linter  *linter
+}
+
+// Reportf reports a problem at pos
+func (p *Pass) Reportf(pos token.Position, format string, a ...interface{}) {
+	p.linter.problems = append(p.linter.problems, &Problem{Pos: pos, Rule: p.rule.Name, Msg: fmt.Sprintf(format, a...)})
+}
+
+// variables resolves the program's variables, once for all rules
+func (p *Pass) variables() *resolver {
+	if p.linter.resolved == nil {
+		p.linter.resolved = resolve(p.Program)
+	}
+	return p.linter.resolved
+}
+
+type linter struct {
+	problems []*Problem
+	resolved *resolver
+}
+
+// Lint runs every rule which isn't disabled on a program. Problems are sorted by position.
+func Lint(program *ast.Program) []*Problem {
+	d := parseDirectives(program)
+	l := &linter{}
+	for _, rule := range Rules() {
+		if d.disabled[""] || d.disabled[rule.Name] {
+			continue
+		}
+		rule.Run(&Pass{Program: program, rule: rule, linter: l})
+	}
+	problems := []*Problem{}
+	for _, p := range l.problems {
+		if ignored := d.ignored[p.Pos.Line]; ignored[""] || ignored[p.Rule] {
+			continue
+		}
+		problems = append(problems, p)
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i].Pos, problems[j].Pos
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return problems
+}
+
+type directives struct {
+	// disabled and ignored hold the names of rules, or "" for all of them
+	disabled map[string]bool
+	ignored  map[int]map[string]bool // by line
+}
+
+func parseDirectives(program *ast.Program) *directives {
+	d := &directives{disabled: map[string]bool{}, ignored: map[int]map[string]bool{}}
+	// the column at which code starts on each line, to tell which comments follow code
+	starts := map[int]int{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		pos := node.Pos()
+		if col, ok := starts[pos.Line]; pos.IsValid() && (!ok || pos.Column < col) {
+			starts[pos.Line] = pos.Column
+		}
+		return true
+	})
+	for _, c := range program.Comments {
+		fields := strings.Fields(strings.TrimPrefix(c.Text(), "#"))
+		if len(fields) == 0 {
+			continue
+		}
+		names := []string{}
+		for _, f := range fields[1:] {
+			for _, name := range strings.Split(f, ",") {
+				if name != "" {
+					names = append(names, name)
+				}
+			}
+		}
+		if len(names) == 0 {
+			names = []string{""}
+		}
+		switch fields[0] {
+		case "lint:disable":
+			for _, name := range names {
+				d.disabled[name] = true
+			}
+		case "lint:ignore":
+			// the comment may be at the end of a line, or on the line before
+			line := c.Token.Line
+			if col, ok := starts[line]; !ok || col > c.Token.Column {
+				line++
+			}
+			if d.ignored[line] == nil {
+				d.ignored[line] = map[string]bool{}
+			}
+			for _, name := range names {
+				d.ignored[line][name] = true
+			}
+		}
+	}
+	return d
+}
@@ -0,0 +1,73 @@
+package lint
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/parser"
+)
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"ok", `var x = 1; x = x + 2; echo(x)`, nil},
+		{"unused", `var x = 1; var y = 2; echo(y)`, []string{"1:5: 'x' is assigned but never used (unused)"}},
+		{"reassigned but unused", `var x = 1; x = 2`, []string{"1:5: 'x' is assigned but never used (unused)"}},
+		{"used in template", `var x = 1; echo("{{.x}}")`, nil},
+		{"used in later function", `var f = fn() { echo(x) }; var x = 1; f()`, nil},
+		{"unused in function", `var f = fn(a) { var b = a }; f(1)`, []string{"1:21: 'b' is assigned but never used (unused)"}},
+		{"local in function", `var x = 1; var f = fn() { x = 2 }; f(); echo(x)`, []string{"1:27: 'x' is assigned but never used (unused)"}},
+		{"flag shadowed", `var l = "x"; ls(l)`, []string{"1:17: 'l' is a variable, so it is passed to `ls` as a value rather than as its flag `l` (flagshadow)"}},
+		{"flag with value shadowed", `var n = fn(x) { x }; head(n(5), "f")`, []string{"1:27: 'n' is a variable, so it is passed to `head` as a value rather than as its flag `n` (flagshadow)"}},
+		{"flag", `ls(l, "x")`, nil},
+		{"recursive rm", `var d = "x"; rm(r, "{{.d}}/")`, []string{"1:20: recursive rm of \"{{.d}}/\", which could remove the wrong files if an interpolated value is empty (emptypath)"}},
+		{"named recursive rm", `var d = "x"; rm("{{.d}}", recursive: true)`, []string{"1:17: recursive rm of \"{{.d}}\", which could remove the wrong files if an interpolated value is empty (emptypath)"}},
+		{"rm", `var d = "x"; rm("{{.d}}"); rm(r, "x")`, nil},
+		{"glob", "$(`ls *.go`)", []string{"1:3: glob pattern *.go is passed to the command as it is, because `$` doesn't expand globs (glob)"}},
+		{"quoted glob", `$("find . -name '*.go'")`, nil},
+		{"glob command", `$("./a?b")`, nil},
+		{"pipe", `ls() | x`, []string{"1:6: pipe to x is ignored, because it is not a call (pipe)"}},
+		{"retype", `var x = 1; x = "a"; echo(x)`, []string{"1:12: 'x' changes type from INTEGER to STRING (retype)"}},
+		{"retype in function", `var x = 1; var f = fn() { x = "a"; echo(x) }; f(); echo(x)`, []string{"1:27: 'x' changes type from INTEGER to STRING (retype)"}},
+		{"annotated", `var x: string = y(); x = 1; echo(x)`, []string{"1:22: 'x' changes type from STRING to INTEGER (retype)"}},
+		{"disabled", "# lint:disable unused\nvar x = 1", nil},
+		{"disabled all", "# lint:disable\nvar l = 1; ls(l)", nil},
+		{"disabled other", "# lint:disable retype, glob\nvar x = 1", []string{"2:5: 'x' is assigned but never used (unused)"}},
+		{"ignored at end of line", "var x = 1 # lint:ignore unused\nvar y = 1", []string{"2:5: 'y' is assigned but never used (unused)"}},
+		{"ignored on line before", "# lint:ignore unused,retype\nvar x = 1\nvar y = 1", []string{"3:5: 'y' is assigned but never used (unused)"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := parser.New(lexer.New(tt.input))
+			program := p.ParseProgram()
+			if len(p.Errors()) > 0 {
+				t.Fatalf("parser errors: %v", p.Errors())
+			}
+			got := []string{}
+			for _, problem := range Lint(program) {
+				got = append(got, problem.Error())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(tt.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestRules(t *testing.T) {
+	names := []string{}
+	for _, rule := range Rules() {
+		names = append(names, rule.Name)
+		if rule.Help == "" {
+			t.Errorf("rule %s has no help", rule.Name)
+		}
+	}
+	expected := "emptypath flagshadow glob pipe retype unused"
+	if got := strings.Join(names, " "); got != expected {
+		t.Errorf("expected rules %s, got %s", expected, got)
+	}
+}
@@ -0,0 +1,20 @@
+package lint
+
+import "github.com/laher/smoosh/ast"
+
+func init() {
+	Register(&Rule{
+		Name: "pipe",
+		Help: "pipes to something other than a call, which do nothing",
+		Run:  pipe,
+	})
+}
+
+func pipe(pass *Pass) {
+	ast.Inspect(pass.Program, func(node ast.Node) bool {
+		if p, ok := node.(*ast.PipeExpression); ok && p.Destination == nil && p.Target != nil {
+			pass.Reportf(p.Pos(), "pipe to %s is ignored, because it is not a call", p.Target.String())
+		}
+		return true
+	})
+}
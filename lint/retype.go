@@ -0,0 +1,20 @@
+package lint
+
+func init() {
+	Register(&Rule{
+		Name: "retype",
+		Help: "reassignments which change a variable's type, which fail when evaluated",
+		Run:  retype,
+	})
+}
+
+func retype(pass *Pass) {
+	for _, a := range pass.variables().assignments {
+		if a.prev == nil || a.prev.typ == unknown {
+			continue
+		}
+		if typ := valueType(a.statement.Value); typ != unknown && typ != a.prev.typ {
+			pass.Reportf(a.statement.Name.Pos(), "'%s' changes type from %s to %s", a.statement.Name.Value, a.prev.typ, typ)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package lint
+
+import (
+	"regexp"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/object"
+)
+
+// unknown is the type of a value which isn't obvious from the source
+const unknown = object.ObjectType("")
+
+// kinds of variable
+const (
+	assigned = iota
+	parameter
+	rangeVar
+)
+
+type variable struct {
+	ident *ast.Identifier
+	kind  int
+	// typ is the type of the first value, which later values must match
+	typ  object.ObjectType
+	uses int
+}
+
+// assignment is an assignment statement, and the variable it assigned to if that was
+// already defined
+type assignment struct {
+	statement *ast.AssignStatement
+	prev      *variable
+}
+
+type scope struct {
+	vars  map[string]*variable
+	outer *scope
+}
+
+func newScope(outer *scope) *scope {
+	return &scope{vars: map[string]*variable{}, outer: outer}
+}
+
+func (s *scope) lookup(name string) *variable {
+	for ; s != nil; s = s.outer {
+		if v, ok := s.vars[name]; ok {
+			return v
+		}
+	}
+	return nil
+}
+
+// resolver finds the variables of a program, and what refers to them. Scopes follow the
+// evaluator, as in the checker: assignments define variables in the current scope, and
+// function, for and range bodies have scopes of their own.
+type resolver struct {
+	vars        []*variable
+	assignments []*assignment
+	// refs maps identifiers to the variables they refer to
+	refs map[*ast.Identifier]*variable
+	// function bodies are resolved once their enclosing block is done, because they may
+	// refer to variables which are assigned after the function is defined
+	pending []func()
+}
+
+func resolve(program *ast.Program) *resolver {
+	r := &resolver{refs: map[*ast.Identifier]*variable{}}
+	r.statements(program.Statements, newScope(nil))
+	for len(r.pending) > 0 {
+		next := r.pending[0]
+		r.pending = r.pending[1:]
+		next()
+	}
+	return r
+}
+
+func (r *resolver) declare(s *scope, ident *ast.Identifier, kind int, typ object.ObjectType) {
+	v := &variable{ident: ident, kind: kind, typ: typ}
+	s.vars[ident.Value] = v
+	r.vars = append(r.vars, v)
+}
+
+func (r *resolver) use(s *scope, name string) *variable {
+	v := s.lookup(name)
+	if v != nil {
+		v.uses++
+	}
+	return v
+}
+
+func (r *resolver) statements(statements []ast.Statement, s *scope) {
+	for _, statement := range statements {
+		r.statement(statement, s)
+	}
+}
+
+func (r *resolver) statement(statement ast.Statement, s *scope) {
+	switch statement := statement.(type) {
+	case *ast.ExpressionStatement:
+		r.expression(statement.Expression, s)
+	case *ast.ReturnStatement:
+		r.expression(statement.ReturnValue, s)
+	case *ast.BlockStatement:
+		r.statements(statement.Statements, s)
+	case *ast.AssignStatement:
+		r.expression(statement.Value, s)
+		name := statement.Name.Value
+		prev := s.lookup(name)
+		r.assignments = append(r.assignments, &assignment{statement: statement, prev: prev})
+		if _, ok := s.vars[name]; ok {
+			return
+		}
+		typ := valueType(statement.Value)
+		if statement.Name.Type != nil {
+			typ = object.AnnotatedType(statement.Name.Type)
+		}
+		if prev != nil && prev.typ != unknown {
+			// the evaluator checks against the variable in the outer scope
+			typ = prev.typ
+		}
+		r.declare(s, statement.Name, assigned, typ)
+	}
+}
+
+func (r *resolver) expression(exp ast.Expression, s *scope) {
+	switch exp := exp.(type) {
+	case *ast.Identifier:
+		if v := r.use(s, exp.Value); v != nil {
+			r.refs[exp] = v
+		}
+	case *ast.StringLiteral:
+		r.template(exp.Value, s)
+	case *ast.BacktickLiteral:
+		r.template(exp.Value, s)
+	case *ast.PrefixExpression:
+		r.expression(exp.Right, s)
+	case *ast.InfixExpression:
+		r.expression(exp.Left, s)
+		r.expression(exp.Right, s)
+	case *ast.IfExpression:
+		r.expression(exp.Condition, s)
+		r.statements(exp.Consequence.Statements, s)
+		if exp.Alternative != nil {
+			r.statements(exp.Alternative.Statements, s)
+		}
+	case *ast.RangeExpression:
+		r.expression(exp.Iterator, s)
+		body := newScope(s)
+		if ident, ok := exp.Identifier.(*ast.Identifier); ok {
+			r.declare(body, ident, rangeVar, object.INTEGER_OBJ)
+		}
+		if ident, ok := exp.Iteree.(*ast.Identifier); ok {
+			r.declare(body, ident, rangeVar, unknown)
+		}
+		r.statements(exp.Body.Statements, body)
+	case *ast.ForExpression:
+		r.statement(exp.Init, s)
+		r.expression(exp.Condition, s)
+		r.statement(exp.After, s)
+		r.statements(exp.Body.Statements, newScope(s))
+	case *ast.FunctionLiteral:
+		body := newScope(s)
+		r.pending = append(r.pending, func() {
+			for _, p := range exp.Parameters {
+				typ := unknown
+				if p.Type != nil {
+					typ = object.AnnotatedType(p.Type)
+				}
+				if p.Default != nil {
+					// defaults may refer to earlier parameters
+					r.expression(p.Default, body)
+				}
+				r.declare(body, p, parameter, typ)
+			}
+			r.statements(exp.Body.Statements, body)
+		})
+	case *ast.MacroLiteral:
+		// macro bodies are quoted code
+	case *ast.CallExpression:
+		if ident, ok := exp.Function.(*ast.Identifier); ok && ident.Value == "quote" {
+			return
+		}
+		r.expression(exp.Function, s)
+		for _, arg := range exp.Arguments {
+			r.expression(arg, s)
+		}
+	case *ast.NamedArgument:
+		r.expression(exp.Value, s)
+	case *ast.SpreadExpression:
+		r.expression(exp.Value, s)
+	case *ast.PipeExpression:
+		if exp.Destination != nil {
+			r.expression(exp.Destination, s)
+		} else {
+			r.expression(exp.Target, s)
+		}
+	case *ast.ArrayLiteral:
+		for _, el := range exp.Elements {
+			r.expression(el, s)
+		}
+	case *ast.HashLiteral:
+		for _, key := range exp.Keys {
+			r.expression(key, s)
+			r.expression(exp.Pairs[key], s)
+		}
+	case *ast.IndexExpression:
+		r.expression(exp.Left, s)
+		r.expression(exp.Index, s)
+	}
+}
+
+// templateVar matches a variable in a string template, e.g. the `x` in `{{.x.y}}`
+var templateVar = regexp.MustCompile(`(?:^|[^\w.])\.([A-Za-z_]\w*)`)
+
+// template counts the variables referred to by a string's template actions as used
+func (r *resolver) template(value string, s *scope) {
+	for _, action := range templateActions(value) {
+		for _, m := range templateVar.FindAllStringSubmatch(action, -1) {
+			r.use(s, m[1])
+		}
+	}
+}
+
+var templateAction = regexp.MustCompile(`{{.*?}}`)
+
+// templateActions returns the actions in a string template, e.g. `{{.x}}`
+func templateActions(value string) []string {
+	return templateAction.FindAllString(value, -1)
+}
+
+// valueType returns the type of an expression, if it's obvious
+func valueType(exp ast.Expression) object.ObjectType {
+	switch exp := exp.(type) {
+	case *ast.IntegerLiteral:
+		return object.INTEGER_OBJ
+	case *ast.StringLiteral:
+		return object.STRING_OBJ
+	case *ast.BacktickLiteral:
+		return object.BACKTICK_OBJ
+	case *ast.Boolean:
+		return object.BOOLEAN_OBJ
+	case *ast.ArrayLiteral:
+		return object.ARRAY_OBJ
+	case *ast.HashLiteral:
+		return object.HASH_OBJ
+	case *ast.FunctionLiteral:
+		return object.FUNCTION_OBJ
+	case *ast.MacroLiteral:
+		return object.MACRO_OBJ
+	case *ast.PrefixExpression:
+		switch exp.Operator {
+		case "!":
+			return object.BOOLEAN_OBJ
+		case "-":
+			return object.INTEGER_OBJ
+		}
+	}
+	return unknown
+}
@@ -0,0 +1,17 @@
+package lint
+
+func init() {
+	Register(&Rule{
+		Name: "unused",
+		Help: "variables which are assigned but never used",
+		Run:  unused,
+	})
+}
+
+func unused(pass *Pass) {
+	for _, v := range pass.variables().vars {
+		if v.kind == assigned && v.uses == 0 && v.ident.Value != "_" {
+			pass.Reportf(v.ident.Pos(), "'%s' is assigned but never used", v.ident.Value)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package lsp
+
+import (
+	"strings"
+	"unicode/utf16"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/parser"
+	"github.com/laher/smoosh/token"
+)
+
+// document is an open text document, and its parsed program. The program holds whichever
+// statements parsed, so that features keep working while a document is being edited.
+type document struct {
+	uri     string
+	text    string
+	lines   []string
+	program *ast.Program
+	errs    []*parser.Error
+}
+
+func newDocument(uri, text string) *document {
+	d := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+	p := parser.New(lexer.New(text))
+	d.program = p.ParseProgram()
+	d.errs = p.ErrorList()
+	return d
+}
+
+func (d *document) line(n int) []rune {
+	if n < 0 || n >= len(d.lines) {
+		return nil
+	}
+	return []rune(strings.TrimRight(d.lines[n], "\r"))
+}
+
+// lspPosition converts a source position, with a column in runes, to a protocol position
+func (d *document) lspPosition(pos token.Position) Position {
+	line := d.line(pos.Line - 1)
+	col := pos.Column - 1
+	if col > len(line) {
+		col = len(line)
+	}
+	if col < 0 {
+		col = 0
+	}
+	return Position{Line: pos.Line - 1, Character: len(utf16.Encode(line[:col]))}
+}
+
+// position converts a protocol position to a source position
+func (d *document) position(p Position) token.Position {
+	line := d.line(p.Line)
+	units := 0
+	col := 0
+	for col < len(line) && units < p.Character {
+		units += len(utf16.Encode(line[col : col+1]))
+		col++
+	}
+	return token.Position{Line: p.Line + 1, Column: col + 1}
+}
+
+// valid reports whether a protocol position is within the text's lines
+func (d *document) valid(p Position) bool {
+	return p.Line >= 0 && p.Line < len(d.lines) && p.Character >= 0
+}
+
+// offset returns the byte offset of a protocol position in the text. Positions outside the
+// text are clamped to its start or end.
+func (d *document) offset(p Position) int {
+	if p.Line < 0 {
+		return 0
+	}
+	if p.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	offset := 0
+	for _, l := range d.lines[:p.Line] {
+		offset += len(l) + 1
+	}
+	line := d.line(p.Line)
+	col := d.position(p).Column - 1
+	return offset + len(string(line[:col]))
+}
+
+// end returns the position at the end of the text
+func (d *document) end() Position {
+	last := len(d.lines) - 1
+	return Position{Line: last, Character: len(utf16.Encode([]rune(d.lines[last])))}
+}
+
+// wordRange returns the range of the identifier or token at pos, or a single character
+func (d *document) wordRange(pos token.Position) Range {
+	if pos.Column < 1 {
+		// e.g. an error without a column
+		pos.Column = 1
+	}
+	line := d.line(pos.Line - 1)
+	end := pos.Column - 1
+	for end < len(line) && isWordRune(line[end]) {
+		end++
+	}
+	if end == pos.Column-1 && end < len(line) {
+		end++
+	}
+	return Range{Start: d.lspPosition(pos), End: d.lspPosition(token.Position{Line: pos.Line, Column: end + 1})}
+}
+
+func isWordRune(ru rune) bool {
+	return 'a' <= ru && ru <= 'z' || 'A' <= ru && ru <= 'Z' || '0' <= ru && ru <= '9' || ru == '_' || ru == '$' || ru == '.'
+}
+
+// identifierAt returns the identifier at a position, if there is one
+func (d *document) identifierAt(pos token.Position) *ast.Identifier {
+	var found *ast.Identifier
+	ast.Inspect(d.program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			start := ident.Pos()
+			if start.Line == pos.Line && start.Column <= pos.Column && pos.Column <= start.Column+len([]rune(ident.Value)) {
+				found = ident
+			}
+		}
+		return found == nil
+	})
+	return found
+}
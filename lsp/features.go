@@ -0,0 +1,198 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/format"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+	"github.com/laher/smoosh/token"
+)
+
+// hover describes the variable, builtin or flag at a position
+func hover(doc *document, p Position) *Hover {
+	ident := doc.identifierAt(doc.position(p))
+	if ident == nil {
+		return nil
+	}
+	all := bindings(doc.program)
+	var text string
+	if b := lookup(all, ident.Value, ident.Pos()); b != nil {
+		text = "```smoosh\n" + declaration(doc, b) + "\n```"
+	} else if name, builtin, ok := flagOf(doc.program, ident, all); ok {
+		flag, _ := builtin.Flag(ident.Value)
+		text = fmt.Sprintf("flag of `%s`: %s", name, flagHelp(flag))
+	} else if builtin, ok := stdlib.GetFn(ident.Value); ok {
+		text = builtinHelp(ident.Value, builtin)
+	} else {
+		return nil
+	}
+	r := doc.wordRange(ident.Pos())
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}
+}
+
+// definition returns where the variable at a position is defined
+func definition(doc *document, p Position) *Location {
+	ident := doc.identifierAt(doc.position(p))
+	if ident == nil {
+		return nil
+	}
+	b := lookup(bindings(doc.program), ident.Value, ident.Pos())
+	if b == nil {
+		return nil
+	}
+	return &Location{URI: doc.uri, Range: doc.wordRange(b.ident.Pos())}
+}
+
+// complete suggests the flags of the builtin being called, variables in scope, builtins
+// and keywords. Clients filter them by what has been typed.
+func complete(doc *document, p Position) []CompletionItem {
+	pos := doc.position(p)
+	all := bindings(doc.program)
+	items := []CompletionItem{}
+	if name := enclosingCall(doc.text[:doc.offset(p)]); name != "" && lookup(all, name, pos) == nil {
+		if builtin, ok := stdlib.GetFn(name); ok {
+			for _, flag := range builtin.Flags {
+				detail := fmt.Sprintf("flag of `%s`", name)
+				items = append(items, CompletionItem{Label: flag.Name, Kind: kindProperty, Detail: detail, Documentation: flag.Help})
+				if flag.Long != "" {
+					items = append(items, CompletionItem{Label: flag.Long, Kind: kindProperty, Detail: detail, Documentation: flag.Help})
+				}
+			}
+		}
+	}
+	for _, b := range visible(all, pos) {
+		items = append(items, CompletionItem{Label: b.ident.Value, Kind: kindVariable, Detail: declaration(doc, b)})
+	}
+	for _, name := range stdlib.Names() {
+		builtin, _ := stdlib.GetFn(name)
+		items = append(items, CompletionItem{Label: name, Kind: kindFunction, Detail: "builtin", Documentation: builtin.Help})
+	}
+	for _, keyword := range token.Keywords() {
+		items = append(items, CompletionItem{Label: keyword, Kind: kindKeyword})
+	}
+	return items
+}
+
+// formatting formats a whole document, as `smoosh -fmt` does
+func formatting(doc *document) ([]TextEdit, error) {
+	res, err := format.Source([]byte(doc.text))
+	if err != nil {
+		return nil, err
+	}
+	if string(res) == doc.text {
+		return []TextEdit{}, nil
+	}
+	return []TextEdit{{Range: Range{End: doc.end()}, NewText: string(res)}}, nil
+}
+
+// declaration returns the line of source which defines a variable
+func declaration(doc *document, b *binding) string {
+	return strings.TrimSpace(string(doc.line(b.ident.Pos().Line - 1)))
+}
+
+// flagOf returns the builtin call in which an identifier is a flag, e.g. the `l` in
+// `ls(l)`, `head(n(5))` or `ls(long: true)`
+func flagOf(program *ast.Program, ident *ast.Identifier, all []*binding) (string, *object.Builtin, bool) {
+	var name string
+	var found *object.Builtin
+	ast.Inspect(program, func(node ast.Node) bool {
+		if found != nil {
+			return false
+		}
+		call, ok := node.(*ast.CallExpression)
+		if !ok {
+			return true
+		}
+		callee, ok := call.Function.(*ast.Identifier)
+		if !ok || lookup(all, callee.Value, callee.Pos()) != nil {
+			return true
+		}
+		builtin, ok := stdlib.GetFn(callee.Value)
+		if !ok {
+			return true
+		}
+		if _, ok := builtin.Flag(ident.Value); !ok {
+			return true
+		}
+		for _, arg := range call.Arguments {
+			if isFlagArgument(arg, ident) {
+				name, found = callee.Value, builtin
+				return false
+			}
+		}
+		return true
+	})
+	return name, found, found != nil
+}
+
+func isFlagArgument(arg ast.Expression, ident *ast.Identifier) bool {
+	switch arg := arg.(type) {
+	case *ast.Identifier:
+		return arg == ident
+	case *ast.CallExpression:
+		return arg.Function == ast.Expression(ident)
+	case *ast.NamedArgument:
+		return arg.Name == ident
+	}
+	return false
+}
+
+// enclosingCall returns the name of the call whose arguments end text, if any
+func enclosingCall(text string) string {
+	depth := 0
+	for i := len(text) - 1; i >= 0; i-- {
+		switch text[i] {
+		case ')', ']', '}':
+			depth++
+		case '[', '{':
+			if depth == 0 {
+				return ""
+			}
+			depth--
+		case '(':
+			if depth > 0 {
+				depth--
+				continue
+			}
+			start := i
+			for start > 0 && isWordRune(rune(text[start-1])) {
+				start--
+			}
+			return text[start:i]
+		}
+	}
+	return ""
+}
+
+func builtinHelp(name string, builtin *object.Builtin) string {
+	text := fmt.Sprintf("`%s` (builtin)", name)
+	if builtin.Help != "" {
+		text += "\n\n" + builtin.Help
+	}
+	if len(builtin.Flags) > 0 {
+		text += "\n\nFlags:"
+		for i := range builtin.Flags {
+			text += "\n- " + flagHelp(&builtin.Flags[i])
+		}
+	}
+	return text
+}
+
+func flagHelp(flag *object.Flag) string {
+	name := "`" + flag.Name + "`"
+	if flag.Long != "" {
+		name += ", `" + flag.Long + "`"
+	}
+	typ := flag.ParamType
+	if typ == "" {
+		typ = object.BOOLEAN_OBJ
+	}
+	text := fmt.Sprintf("%s (%s)", name, typ)
+	if flag.Help != "" {
+		text += ": " + flag.Help
+	}
+	return text
+}
@@ -0,0 +1,118 @@
+package lsp
+
+import (
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/token"
+)
+
+// span is the text between two source positions, inclusive
+type span struct {
+	start, end token.Position
+}
+
+func (s span) contains(pos token.Position) bool {
+	return !before(pos, s.start) && !before(s.end, pos)
+}
+
+func before(a, b token.Position) bool {
+	return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+}
+
+// whole is the span of a document
+var whole = span{start: token.Position{Line: 1, Column: 1}, end: token.Position{Line: int(^uint(0) >> 1)}}
+
+// binding is where a variable is defined: its first assignment in a scope, or a
+// function parameter or range variable
+type binding struct {
+	ident *ast.Identifier
+	scope span
+	// value is the assigned value, if any
+	value ast.Expression
+}
+
+// bindings finds the variables defined in a program. As in the evaluator, assignments
+// define variables in the current scope, and function, for and range bodies have scopes
+// of their own.
+func bindings(program *ast.Program) []*binding {
+	scopes := []span{}
+	all := []*binding{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			scope := span{node.Pos(), node.Body.Rbrace.Pos()}
+			scopes = append(scopes, scope)
+			for _, param := range node.Parameters {
+				all = append(all, &binding{ident: param, scope: scope})
+			}
+		case *ast.RangeExpression:
+			scope := span{node.Pos(), node.Body.Rbrace.Pos()}
+			scopes = append(scopes, scope)
+			for _, exp := range []ast.Expression{node.Identifier, node.Iteree} {
+				if ident, ok := exp.(*ast.Identifier); ok {
+					all = append(all, &binding{ident: ident, scope: scope})
+				}
+			}
+		case *ast.ForExpression:
+			// the init statement belongs to the enclosing scope
+			scopes = append(scopes, span{node.Body.Pos(), node.Body.Rbrace.Pos()})
+		case *ast.AssignStatement:
+			scope := innermost(scopes, node.Pos())
+			for _, b := range all {
+				if b.scope == scope && b.ident.Value == node.Name.Value {
+					// already defined in this scope
+					return true
+				}
+			}
+			all = append(all, &binding{ident: node.Name, scope: scope, value: node.Value})
+		case *ast.MacroLiteral:
+			// macro bodies are quoted code
+			return false
+		}
+		return true
+	})
+	return all
+}
+
+// innermost returns the smallest scope containing pos
+func innermost(scopes []span, pos token.Position) span {
+	inner := whole
+	for _, scope := range scopes {
+		if scope.contains(pos) && before(inner.start, scope.start) {
+			inner = scope
+		}
+	}
+	return inner
+}
+
+// visible returns the bindings in scope at pos, innermost first, with only the first
+// binding of each name
+func visible(all []*binding, pos token.Position) []*binding {
+	found := []*binding{}
+	seen := map[string]bool{}
+	for {
+		var next *binding
+		for _, b := range all {
+			if seen[b.ident.Value] || !b.scope.contains(pos) {
+				continue
+			}
+			if next == nil || before(next.scope.start, b.scope.start) {
+				next = b
+			}
+		}
+		if next == nil {
+			return found
+		}
+		seen[next.ident.Value] = true
+		found = append(found, next)
+	}
+}
+
+// lookup returns the binding which a name refers to at pos
+func lookup(all []*binding, name string, pos token.Position) *binding {
+	for _, b := range visible(all, pos) {
+		if b.ident.Value == name {
+			return b
+		}
+	}
+	return nil
+}
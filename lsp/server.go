@@ -0,0 +1,201 @@
+// Package lsp is a language server for smoosh scripts, speaking the Language Server
+// Protocol over stdio.
+//
+// It publishes diagnostics from the parser, the checker and the linter, and provides
+// hover text for builtins and their flags, completion, go-to-definition for variables,
+// and formatting.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/laher/smoosh/checker"
+	"github.com/laher/smoosh/lint"
+)
+
+type server struct {
+	out      io.Writer
+	docs     map[string]*document
+	shutdown bool
+}
+
+// Serve reads messages from in and writes responses to out, until the client exits
+func Serve(in io.Reader, out io.Writer) error {
+	s := &server{out: out, docs: map[string]*document{}}
+	r := bufio.NewReader(in)
+	for {
+		content, err := readMessage(r)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		msg := &message{}
+		if err := json.Unmarshal(content, msg); err != nil {
+			if err := s.replyError(nil, codeParseError, err.Error()); err != nil {
+				return err
+			}
+			continue
+		}
+		if msg.Method == "exit" {
+			if !s.shutdown {
+				return errors.New("exit without shutdown")
+			}
+			return nil
+		}
+		if err := s.safeHandle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+// safeHandle handles a message, replying with an error rather than stopping the server if
+// handling it panics
+func (s *server) safeHandle(msg *message) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = nil
+			if msg.ID != nil {
+				err = s.replyError(msg.ID, codeRequestFailed, fmt.Sprintf("internal error: %v", p))
+			}
+		}
+	}()
+	return s.handle(msg)
+}
+
+// handle handles a message. Only errors writing to the client are returned.
+func (s *server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		return s.reply(msg.ID, map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				// full text on each change
+				"textDocumentSync":           1,
+				"hoverProvider":              true,
+				"completionProvider":         map[string]interface{}{"triggerCharacters": []string{"(", ",", " "}},
+				"definitionProvider":         true,
+				"documentFormattingProvider": true,
+			},
+			"serverInfo": map[string]string{"name": "smoosh"},
+		})
+	case "initialized":
+		return nil
+	case "shutdown":
+		s.shutdown = true
+		return s.reply(msg.ID, nil)
+
+	case "textDocument/didOpen":
+		params := &didOpenParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil
+		}
+		doc := newDocument(params.TextDocument.URI, params.TextDocument.Text)
+		s.docs[doc.uri] = doc
+		return s.publishDiagnostics(doc)
+	case "textDocument/didChange":
+		params := &didChangeParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return nil
+		}
+		for _, change := range params.ContentChanges {
+			text := change.Text
+			if change.Range != nil {
+				text = doc.text[:doc.offset(change.Range.Start)] + change.Text + doc.text[doc.offset(change.Range.End):]
+			}
+			doc = newDocument(doc.uri, text)
+		}
+		s.docs[doc.uri] = doc
+		return s.publishDiagnostics(doc)
+	case "textDocument/didClose":
+		params := &didCloseParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return nil
+		}
+		delete(s.docs, params.TextDocument.URI)
+		return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: params.TextDocument.URI, Diagnostics: []Diagnostic{}})
+
+	case "textDocument/hover", "textDocument/completion", "textDocument/definition":
+		params := &positionParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return s.replyError(msg.ID, codeInvalidParams, err.Error())
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.replyError(msg.ID, codeInvalidParams, "unknown document: "+params.TextDocument.URI)
+		}
+		if !doc.valid(params.Position) {
+			return s.replyError(msg.ID, codeInvalidParams, fmt.Sprintf("invalid position: %d:%d", params.Position.Line, params.Position.Character))
+		}
+		switch msg.Method {
+		case "textDocument/hover":
+			return s.reply(msg.ID, hover(doc, params.Position))
+		case "textDocument/completion":
+			return s.reply(msg.ID, complete(doc, params.Position))
+		default:
+			return s.reply(msg.ID, definition(doc, params.Position))
+		}
+	case "textDocument/formatting":
+		params := &formattingParams{}
+		if err := json.Unmarshal(msg.Params, params); err != nil {
+			return s.replyError(msg.ID, codeInvalidParams, err.Error())
+		}
+		doc, ok := s.docs[params.TextDocument.URI]
+		if !ok {
+			return s.replyError(msg.ID, codeInvalidParams, "unknown document: "+params.TextDocument.URI)
+		}
+		edits, err := formatting(doc)
+		if err != nil {
+			return s.replyError(msg.ID, codeRequestFailed, err.Error())
+		}
+		return s.reply(msg.ID, edits)
+	}
+	if msg.ID != nil {
+		return s.replyError(msg.ID, codeMethodNotFound, "method not supported: "+msg.Method)
+	}
+	// other notifications are ignored
+	return nil
+}
+
+func (s *server) reply(id *json.RawMessage, result interface{}) error {
+	b, err := json.Marshal(result)
+	if err != nil {
+		return s.replyError(id, codeRequestFailed, err.Error())
+	}
+	raw := json.RawMessage(b)
+	return writeMessage(s.out, &response{JSONRPC: "2.0", ID: id, Result: &raw})
+}
+
+func (s *server) replyError(id *json.RawMessage, code int, msg string) error {
+	return writeMessage(s.out, &response{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: msg}})
+}
+
+func (s *server) notify(method string, params interface{}) error {
+	return writeMessage(s.out, &notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// publishDiagnostics sends syntax errors, or if there are none, problems found by the
+// checker and the linter
+func (s *server) publishDiagnostics(doc *document) error {
+	diags := []Diagnostic{}
+	for _, err := range doc.errs {
+		diags = append(diags, Diagnostic{Range: doc.wordRange(err.Pos), Severity: severityError, Source: "smoosh", Message: err.Msg})
+	}
+	if len(doc.errs) == 0 {
+		for _, err := range checker.Check(doc.program, nil) {
+			diags = append(diags, Diagnostic{Range: doc.wordRange(err.Pos), Severity: severityError, Source: "smoosh", Message: err.Msg})
+		}
+		for _, p := range lint.Lint(doc.program) {
+			diags = append(diags, Diagnostic{Range: doc.wordRange(p.Pos), Severity: severityWarning, Code: p.Rule, Source: "smoosh-lint", Message: p.Msg})
+		}
+	}
+	return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{URI: doc.uri, Diagnostics: diags})
+}
@@ -0,0 +1,314 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/laher/smoosh/token"
+)
+
+const uri = "file:///test.smoosh"
+
+const src = `var dir = "x"
+ls(l, dir)
+var f = fn(msg) {
+	echo(msg)
+}
+f(1)`
+
+func request(id int, method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "id": id, "method": method, "params": params}
+}
+
+func notify(method string, params interface{}) map[string]interface{} {
+	return map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params}
+}
+
+func open(text string) map[string]interface{} {
+	return notify("textDocument/didOpen", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "languageId": "smoosh", "version": 1, "text": text},
+	})
+}
+
+func at(line, character int) map[string]interface{} {
+	return map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"position":     map[string]interface{}{"line": line, "character": character},
+	}
+}
+
+// session sends messages to a server, followed by shutdown and exit, and returns the
+// messages it sent back, keyed by request ID or by notification method
+func session(t *testing.T, msgs ...map[string]interface{}) map[string][]json.RawMessage {
+	in := bytes.NewBuffer([]byte{})
+	msgs = append(msgs, request(999, "shutdown", nil), notify("exit", nil))
+	for _, msg := range msgs {
+		if err := writeMessage(in, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := bytes.NewBuffer([]byte{})
+	if err := Serve(in, out); err != nil {
+		t.Fatal(err)
+	}
+	got := map[string][]json.RawMessage{}
+	r := bufio.NewReader(out)
+	for {
+		content, err := readMessage(r)
+		if err == io.EOF {
+			return got
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := struct {
+			ID     *int            `json:"id"`
+			Method string          `json:"method"`
+			Result json.RawMessage `json:"result"`
+			Params json.RawMessage `json:"params"`
+			Error  *responseError  `json:"error"`
+		}{}
+		if err := json.Unmarshal(content, &msg); err != nil {
+			t.Fatal(err)
+		}
+		switch {
+		case msg.Error != nil:
+			got["error"] = append(got["error"], json.RawMessage(msg.Error.Message))
+		case msg.ID != nil:
+			b, _ := json.Marshal(*msg.ID)
+			got[string(b)] = append(got[string(b)], msg.Result)
+		default:
+			got[msg.Method] = append(got[msg.Method], msg.Params)
+		}
+	}
+}
+
+func TestInitialize(t *testing.T) {
+	got := session(t, request(1, "initialize", map[string]interface{}{}), notify("initialized", nil))
+	result := struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}{}
+	if err := json.Unmarshal(got["1"][0], &result); err != nil {
+		t.Fatal(err)
+	}
+	for _, capability := range []string{"textDocumentSync", "hoverProvider", "completionProvider", "definitionProvider", "documentFormattingProvider"} {
+		if _, ok := result.Capabilities[capability]; !ok {
+			t.Errorf("missing capability %s", capability)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		expected []Diagnostic
+	}{
+		{"ok", src, []Diagnostic{}},
+		{"syntax", "var x = ;\necho(y)", []Diagnostic{
+			{Range: Range{Start: Position{0, 8}, End: Position{0, 9}}, Severity: severityError, Source: "smoosh", Message: "no prefix parse function for ; found"},
+		}},
+		{"checks", "echo(y)\nvar z = 1", []Diagnostic{
+			{Range: Range{Start: Position{0, 5}, End: Position{0, 6}}, Severity: severityError, Source: "smoosh", Message: "identifier not found: y"},
+			{Range: Range{Start: Position{1, 4}, End: Position{1, 5}}, Severity: severityWarning, Code: "unused", Source: "smoosh-lint", Message: "'z' is assigned but never used"},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := session(t, open(tt.text))
+			params := &publishDiagnosticsParams{}
+			if err := json.Unmarshal(got["textDocument/publishDiagnostics"][0], params); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(params.Diagnostics, tt.expected) {
+				t.Errorf("expected %+v, got %+v", tt.expected, params.Diagnostics)
+			}
+		})
+	}
+}
+
+func TestDidChange(t *testing.T) {
+	got := session(t, open("echo(y)"),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument":   map[string]interface{}{"uri": uri, "version": 2},
+			"contentChanges": []map[string]interface{}{{"text": "var y = 1\necho(y)"}},
+		}),
+		notify("textDocument/didChange", map[string]interface{}{
+			"textDocument": map[string]interface{}{"uri": uri, "version": 3},
+			"contentChanges": []map[string]interface{}{{
+				"range": map[string]interface{}{"start": map[string]int{"line": 1, "character": 5}, "end": map[string]int{"line": 1, "character": 6}},
+				"text":  "z",
+			}},
+		}),
+		notify("textDocument/didClose", map[string]interface{}{"textDocument": map[string]interface{}{"uri": uri}}))
+	counts := []int{}
+	for _, raw := range got["textDocument/publishDiagnostics"] {
+		params := &publishDiagnosticsParams{}
+		if err := json.Unmarshal(raw, params); err != nil {
+			t.Fatal(err)
+		}
+		counts = append(counts, len(params.Diagnostics))
+	}
+	// y is undefined; then defined; then z is undefined, and y unused; then closed
+	if expected := []int{1, 0, 2, 0}; !reflect.DeepEqual(counts, expected) {
+		t.Errorf("expected diagnostic counts %v, got %v", expected, counts)
+	}
+}
+
+func TestHover(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        string
+	}{
+		{1, 0, "`ls` (builtin)"},
+		{1, 3, "flag of `ls`: `l`, `long` (BOOLEAN)"},
+		{1, 7, "```smoosh\nvar dir = \"x\"\n```"},
+		{3, 6, "```smoosh\nvar f = fn(msg) {\n```"},
+		{2, 16, ""},
+	}
+	msgs := []map[string]interface{}{open(src)}
+	for i, tt := range tests {
+		msgs = append(msgs, request(i+1, "textDocument/hover", at(tt.line, tt.character)))
+	}
+	got := session(t, msgs...)
+	for i, tt := range tests {
+		hover := &Hover{}
+		if err := json.Unmarshal(got[strconv.Itoa(i+1)][0], &hover); err != nil {
+			t.Fatal(err)
+		}
+		value := ""
+		if hover != nil {
+			value = hover.Contents.Value
+		}
+		if !strings.HasPrefix(value, tt.expected) || (tt.expected == "" && value != "") {
+			t.Errorf("hover at %d:%d: expected %q, got %q", tt.line, tt.character, tt.expected, value)
+		}
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	tests := []struct {
+		line, character int
+		expected        *Location
+	}{
+		{1, 7, &Location{URI: uri, Range: Range{Start: Position{0, 4}, End: Position{0, 7}}}},
+		{3, 6, &Location{URI: uri, Range: Range{Start: Position{2, 11}, End: Position{2, 14}}}},
+		{5, 0, &Location{URI: uri, Range: Range{Start: Position{2, 4}, End: Position{2, 5}}}},
+		{1, 0, nil},
+	}
+	msgs := []map[string]interface{}{open(src)}
+	for i, tt := range tests {
+		msgs = append(msgs, request(i+1, "textDocument/definition", at(tt.line, tt.character)))
+	}
+	got := session(t, msgs...)
+	for i, tt := range tests {
+		var loc *Location
+		if err := json.Unmarshal(got[strconv.Itoa(i+1)][0], &loc); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(loc, tt.expected) {
+			t.Errorf("definition at %d:%d: expected %+v, got %+v", tt.line, tt.character, tt.expected, loc)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	got := session(t, open(src),
+		request(1, "textDocument/completion", at(1, 3)),
+		request(2, "textDocument/completion", at(3, 6)))
+
+	labels := func(raw json.RawMessage) map[string]int {
+		items := []CompletionItem{}
+		if err := json.Unmarshal(raw, &items); err != nil {
+			t.Fatal(err)
+		}
+		kinds := map[string]int{}
+		for _, item := range items {
+			kinds[item.Label] = item.Kind
+		}
+		return kinds
+	}
+	inLs := labels(got["1"][0])
+	for label, kind := range map[string]int{"l": kindProperty, "long": kindProperty, "dir": kindVariable, "f": kindVariable, "echo": kindFunction, "var": kindKeyword} {
+		if inLs[label] != kind {
+			t.Errorf("expected completion %s of kind %d, got %d", label, kind, inLs[label])
+		}
+	}
+	if _, ok := inLs["msg"]; ok {
+		t.Errorf("parameter msg is not in scope")
+	}
+	inEcho := labels(got["2"][0])
+	if inEcho["msg"] != kindVariable {
+		t.Errorf("expected parameter msg in scope")
+	}
+	if _, ok := inEcho["l"]; ok {
+		t.Errorf("flags of ls are not relevant to echo")
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	got := session(t, open("var x=1\necho( x )"), request(1, "textDocument/formatting", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri},
+		"options":      map[string]interface{}{"tabSize": 4, "insertSpaces": false},
+	}))
+	edits := []TextEdit{}
+	if err := json.Unmarshal(got["1"][0], &edits); err != nil {
+		t.Fatal(err)
+	}
+	expected := []TextEdit{{Range: Range{End: Position{1, 9}}, NewText: "var x = 1\necho(x)\n"}}
+	if !reflect.DeepEqual(edits, expected) {
+		t.Errorf("expected %+v, got %+v", expected, edits)
+	}
+}
+
+func TestWordRange(t *testing.T) {
+	doc := newDocument(uri, "echo(1)\n")
+	// a position without a column is taken as the start of the line
+	r := doc.wordRange(token.Position{Line: 1, Column: 0})
+	if r.Start != (Position{Line: 0, Character: 0}) || r.End != (Position{Line: 0, Character: 4}) {
+		t.Errorf("unexpected range %+v", r)
+	}
+	if got := doc.offset(Position{Line: -1, Character: 0}); got != 0 {
+		t.Errorf("expected offset 0 for a negative line, got %d", got)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	got := session(t, request(1, "textDocument/unknown", nil), request(2, "textDocument/hover", at(0, 0)))
+	if len(got["error"]) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(got["error"]))
+	}
+
+	// positions outside the document are rejected, rather than crashing the server
+	change := notify("textDocument/didChange", map[string]interface{}{
+		"textDocument": map[string]interface{}{"uri": uri, "version": 2},
+		"contentChanges": []map[string]interface{}{{
+			"range": map[string]interface{}{
+				"start": map[string]interface{}{"line": -1, "character": -1},
+				"end":   map[string]interface{}{"line": 0, "character": 0},
+			},
+			"text": "echo(1)\n",
+		}},
+	})
+	got = session(t, open(src), request(1, "textDocument/hover", at(-1, 0)), request(2, "textDocument/completion", at(0, -1)), change, request(3, "textDocument/hover", at(0, 1)))
+	if len(got["error"]) != 2 {
+		t.Errorf("expected 2 errors, got %d", len(got["error"]))
+	}
+	if len(got["3"]) != 1 {
+		t.Errorf("expected a hover after the errors, got %v", got["3"])
+	}
+
+	in := bytes.NewBuffer([]byte{})
+	if err := writeMessage(in, notify("exit", nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := Serve(in, bytes.NewBuffer([]byte{})); err == nil {
+		t.Error("expected an error for exit without shutdown")
+	}
+}
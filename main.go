@@ -9,6 +9,8 @@ import (
 	"net/http"
 	_ "net/http/pprof"
 
+	"github.com/laher/smoosh/lsp"
+	"github.com/laher/smoosh/object"
 	"github.com/laher/smoosh/run"
 )
 
@@ -16,10 +18,30 @@ func main() {
 	runner := run.NewRunner()
 	flag.BoolVar(&runner.Evaluate, "eval", true, "evaluate input")
 	flag.BoolVar(&runner.Parse, "parse", true, "parse input")
-	flag.BoolVar(&runner.Format, "fmt", false, "format inut")
+	flag.BoolVar(&runner.Format, "fmt", false, "format input")
+	flag.BoolVar(&runner.FormatWrite, "w", false, "with -fmt, write formatted files in place")
+	flag.BoolVar(&runner.FormatList, "l", false, "with -fmt, list files which are not formatted")
+	flag.BoolVar(&runner.FormatDiff, "d", false, "with -fmt, print diffs for files which are not formatted")
+	flag.BoolVar(&runner.Check, "check", false, "type-check input without evaluating it")
+	flag.BoolVar(&runner.Lint, "lint", false, "report likely mistakes in input without evaluating it")
+	flag.BoolVar(&runner.Trace, "x", false, "trace builtin calls to stderr")
+	flag.BoolVar(&runner.DryRun, "dryrun", false, "log mutating builtins instead of running them")
 	diagPort := ""
 	flag.StringVar(&diagPort, "diag", "", "diagnostics port (e.g. ':6060')")
+	sandbox := ""
+	flag.StringVar(&sandbox, "sandbox", "", "restrict file access to a root directory, and deny exec, network and exit")
 	flag.Parse()
+	if sandbox != "" {
+		runner.Policy = &object.Policy{
+			Root:        sandbox,
+			DenyExec:    true,
+			DenyNetwork: true,
+			DenyExit:    true,
+		}
+	}
+	if runner.FormatWrite || runner.FormatList || runner.FormatDiff {
+		runner.Format = true
+	}
 	if runner.Format {
 		runner.Evaluate = false
 	}
@@ -30,10 +52,31 @@ func main() {
 		}()
 	}
 
+	if flag.Arg(0) == "lsp" {
+		// serve the Language Server Protocol over stdio
+		if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 	if len(flag.Args()) == 0 {
 		runner.Start(os.Stdin, os.Stdout, os.Stderr)
 		return
 	}
+	if runner.Format {
+		failed := false
+		for _, filename := range flag.Args() {
+			if err := runner.FormatFile(filename, os.Stdout); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				failed = true
+			}
+		}
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
 	// Run a Smoosh script
 	if err := runner.RunFile(flag.Arg(0), os.Stdout, os.Stderr); err != nil {
 		fmt.Fprintln(os.Stderr, err)
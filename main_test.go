@@ -54,3 +54,77 @@ func TestBad(t *testing.T) {
 		})
 	}
 }
+
+func TestCheck(t *testing.T) {
+	runner := run.NewRunner()
+	runner.Check = true
+	for _, pattern := range []string{"testdata/*.smoosh", "testdata/bad/*.smoosh"} {
+		files, err := filepath.Glob(pattern)
+		if err != nil {
+			t.Errorf("failed: %s", err)
+			t.FailNow()
+		}
+		bad := filepath.Dir(pattern) == "testdata/bad"
+		for _, f := range files {
+			t.Run(f, func(t *testing.T) {
+				wbuf := bytes.NewBuffer([]byte{})
+				ebuf := bytes.NewBuffer([]byte{})
+				err := runner.RunFile(f, wbuf, ebuf)
+				if bad && err == nil {
+					t.Errorf("File should have failed checks")
+				}
+				if !bad && err != nil {
+					t.Errorf("Failed to check file: %v", err)
+				}
+			})
+		}
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	f := "testdata/bad/reassign.smoosh"
+	tests := []struct {
+		check    bool
+		expected string
+	}{
+		{false, f + ":3:1: type STRING but expected INTEGER\nx = \"a\"\n^"},
+		{true, f + ":3:1: cannot assign STRING to 'x' (type INTEGER)\nx = \"a\"\n^"},
+	}
+	for _, tt := range tests {
+		runner := run.NewRunner()
+		runner.Check = tt.check
+		err := runner.RunFile(f, bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("expected error %q, got %v", tt.expected, err)
+		}
+	}
+}
+
+func TestErrorStack(t *testing.T) {
+	runner := run.NewRunner()
+	err := runner.Run(bytes.NewBufferString("var f = fn(x) {\n  len(x, 1)\n}\nf(\"a\")\n"), bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+	expected := `2:3: wrong number of arguments. got=2, want=1
+  len(x, 1)
+  ^
+call stack:
+  len("a", 1) at 2:3
+  f("a") at 4:1`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error:\n%s\ngot:\n%v", expected, err)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	f := "testdata/bad/syntax.smoosh"
+	runner := run.NewRunner()
+	err := runner.RunFile(f, bytes.NewBuffer([]byte{}), bytes.NewBuffer([]byte{}))
+	expected := f + `:1:9: no prefix parse function for ; found
+var x = ;
+        ^
+` + f + `:3:7: expected next token to be ), got INT instead
+len(1 2)
+      ^`
+	if err == nil || err.Error() != expected {
+		t.Errorf("expected error:\n%s\ngot:\n%v", expected, err)
+	}
+}
@@ -0,0 +1,99 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// FromGo converts a Go value into a smoosh Object.
+// Supported values are nil, bools, strings, integers, slices, maps, BuiltinFunctions and Objects.
+func FromGo(v interface{}) (Object, error) {
+	switch v := v.(type) {
+	case nil:
+		return &Null{}, nil
+	case Object:
+		return v, nil
+	case BuiltinFunction:
+		return &Builtin{Fn: v}, nil
+	case bool:
+		return &Boolean{Value: v}, nil
+	case string:
+		return &String{Value: v}, nil
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Integer{Value: rv.Int()}, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Integer{Value: int64(rv.Uint())}, nil
+	case reflect.String:
+		return &String{Value: rv.String()}, nil
+	case reflect.Bool:
+		return &Boolean{Value: rv.Bool()}, nil
+	case reflect.Slice, reflect.Array:
+		arr := &Array{Elements: make([]Object, 0, rv.Len())}
+		for i := 0; i < rv.Len(); i++ {
+			el, err := FromGo(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("index %d: %v", i, err)
+			}
+			arr.Elements = append(arr.Elements, el)
+		}
+		return arr, nil
+	case reflect.Map:
+		hash := &Hash{Pairs: map[HashKey]HashPair{}}
+		for _, k := range rv.MapKeys() {
+			key, err := FromGo(k.Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %v", k, err)
+			}
+			hk, ok := key.(Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			val, err := FromGo(rv.MapIndex(k).Interface())
+			if err != nil {
+				return nil, fmt.Errorf("key %v: %v", k, err)
+			}
+			hash.Pairs[hk.HashKey()] = HashPair{Key: key, Value: val}
+		}
+		return hash, nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return &Null{}, nil
+		}
+		return FromGo(rv.Elem().Interface())
+	}
+	return nil, fmt.Errorf("unsupported Go type %T", v)
+}
+
+// ToGo converts a smoosh Object into a plain Go value.
+// Integers become int64, arrays []interface{} and hashes map[string]interface{}.
+// Other objects (functions, builtins, ...) are returned as-is.
+func ToGo(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case nil, *Null:
+		return nil
+	case *Integer:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *String:
+		return obj.Value
+	case *BacktickExpression:
+		return obj.Value
+	case *Array:
+		items := make([]interface{}, 0, len(obj.Elements))
+		for _, el := range obj.Elements {
+			items = append(items, ToGo(el))
+		}
+		return items
+	case *Hash:
+		ret := map[string]interface{}{}
+		for _, pair := range obj.Pairs {
+			ret[fmt.Sprint(ToGo(pair.Key))] = ToGo(pair.Value)
+		}
+		return ret
+	}
+	return obj
+}
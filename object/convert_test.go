@@ -0,0 +1,35 @@
+package object
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromGoToGo(t *testing.T) {
+	tests := []struct {
+		in       interface{}
+		expected interface{}
+	}{
+		{nil, nil},
+		{5, int64(5)},
+		{uint8(5), int64(5)},
+		{"x", "x"},
+		{true, true},
+		{[]string{"a", "b"}, []interface{}{"a", "b"}},
+		{map[string]int{"a": 1}, map[string]interface{}{"a": int64(1)}},
+	}
+	for _, tt := range tests {
+		obj, err := FromGo(tt.in)
+		if err != nil {
+			t.Errorf("unexpected error for %#v: %v", tt.in, err)
+			continue
+		}
+		res := ToGo(obj)
+		if !reflect.DeepEqual(res, tt.expected) {
+			t.Errorf("%#v doesnt match expected %#v", res, tt.expected)
+		}
+	}
+	if _, err := FromGo(1.5); err == nil {
+		t.Errorf("expected an error for unsupported float")
+	}
+}
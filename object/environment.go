@@ -3,17 +3,31 @@ package object
 import (
 	"fmt"
 	"io"
+	"os"
+	"sort"
+
+	"github.com/laher/smoosh/vfs"
 )
 
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment(outer.Streams)
 	env.outer = outer
+	env.Builtins = outer.Builtins
+	env.Aliases = outer.Aliases
+	env.Policy = outer.Policy
+	env.FS = outer.FS
+	env.WorkDir = outer.WorkDir
+	env.Options = outer.Options
 	return env
 }
 
 func NewEnvironment(streams Streams) *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s, outer: nil, Streams: streams}
+	wd, err := os.Getwd()
+	if err != nil {
+		wd = "/"
+	}
+	return &Environment{store: s, outer: nil, Streams: streams, Builtins: map[string]*Builtin{}, Aliases: map[string]*Alias{}, FS: vfs.OS{}, WorkDir: NewWorkDir(wd), Options: NewOptions(streams.Stderr)}
 }
 
 type Streams struct {
@@ -26,6 +40,43 @@ type Environment struct {
 	store   map[string]Object
 	outer   *Environment
 	Streams Streams
+	// Builtins holds builtins registered for this interpreter only.
+	// It is shared by enclosed environments, and takes precedence over the stdlib registry.
+	Builtins map[string]*Builtin
+	// Aliases maps names to the builtin calls they stand for, e.g. "ll" to "ls(l, a)".
+	// It is shared by enclosed environments.
+	Aliases map[string]*Alias
+	// Policy restricts what builtins may do. nil means unrestricted.
+	Policy *Policy
+	// FS is the filesystem used by builtins. It defaults to the OS filesystem.
+	FS vfs.FileSystem
+	// WorkDir is the interpreter's working directory. It is shared by enclosed environments.
+	WorkDir *WorkDir
+	// Options holds runtime settings such as tracing. It is shared by enclosed environments.
+	Options *Options
+}
+
+// Files returns the filesystem, resolving relative paths against the working directory
+func (e *Environment) Files() vfs.FileSystem {
+	return vfs.Relative(e.FS, e.WorkDir.Get())
+}
+
+// CheckRead resolves paths against the working directory and checks them against the Policy
+func (e *Environment) CheckRead(paths ...string) error {
+	return e.Policy.CheckRead(e.resolveAll(paths)...)
+}
+
+// CheckWrite resolves paths against the working directory and checks them against the Policy
+func (e *Environment) CheckWrite(paths ...string) error {
+	return e.Policy.CheckWrite(e.resolveAll(paths)...)
+}
+
+func (e *Environment) resolveAll(paths []string) []string {
+	resolved := make([]string, 0, len(paths))
+	for _, p := range paths {
+		resolved = append(resolved, e.WorkDir.Resolve(p))
+	}
+	return resolved
 }
 
 func (e *Environment) Export() map[string]interface{} {
@@ -74,7 +125,37 @@ func (e *Environment) Get(name string) (Object, bool) {
 	return obj, ok
 }
 
+// Names returns the names of the variables in scope, sorted
+func (e *Environment) Names() []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for env := e; env != nil; env = env.outer {
+		for name := range env.store {
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func (e *Environment) Set(name string, val Object) Object {
 	e.store[name] = val
 	return val
 }
+
+// GetBuiltin returns a builtin registered on this environment, if defined
+func (e *Environment) GetBuiltin(name string) (*Builtin, bool) {
+	bi, ok := e.Builtins[name]
+	return bi, ok
+}
+
+// Alias is a call to a builtin with preset arguments, which are evaluated when the alias is
+// defined, so that they can't be taken over by variables where it's used
+type Alias struct {
+	// Def is the call as it was given, e.g. "ls(l, a)"
+	Def     string
+	Builtin *Builtin
+}
@@ -4,15 +4,22 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"strconv"
 	"strings"
 
 	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/token"
 )
 
 // Scope for a builtin function
 type Scope struct {
 	Env     *Environment
 	In, Out *ast.Pipes
+	// Line is the source line of the call
+	Line int
+	// Statement is set when the call is a statement of its own, rather than part of an
+	// expression or pipeline
+	Statement bool
 }
 
 // helper for async/sync versions of functions.
@@ -39,7 +46,8 @@ const (
 	ARRAY_OBJ = "ARRAY"
 	HASH_OBJ  = "HASH"
 
-	PIPES_OBJ = "PIPES"
+	PIPES_OBJ    = "PIPES"
+	PIPELINE_OBJ = "PIPELINE"
 
 	BACKTICK_OBJ = "BACKTICK"
 
@@ -103,17 +111,71 @@ func (rv *ReturnValue) Inspect() string  { return rv.Value.Inspect() }
 
 type Error struct {
 	Message string
+	// Pos is where the error was raised, if known
+	Pos token.Position
+	// Stack is the chain of calls which led to the error, innermost first
+	Stack []Frame
+	// ExitCode is the exit status of a command which failed, or 0 for other errors
+	ExitCode int
 }
 
 func (e *Error) Type() ObjectType { return ERROR_OBJ }
 func (e *Error) Inspect() string  { return "ERROR: " + e.Message }
 
+// Frame is a call to a function, builtin or macro on the way to an error
+type Frame struct {
+	Name  string
+	Args  []Object
+	Pos   token.Position // position of the call
+	Macro bool
+}
+
+// FormatArg renders an argument as it would be written in a call, for traces, stack traces
+// and pipelines: strings are quoted, flags are shown as `l` or `n: 10`, and functions as fn
+func FormatArg(arg Object) string {
+	switch arg := arg.(type) {
+	case *String:
+		return strconv.Quote(arg.Value)
+	case *Flag:
+		if arg.Param != nil {
+			return arg.Name + ": " + FormatArg(arg.Param)
+		}
+		return arg.Name
+	case *Function:
+		return "fn"
+	}
+	return arg.Inspect()
+}
+
+// maxFrameArg is the length at which arguments are truncated in a stack trace
+const maxFrameArg = 40
+
+func (f Frame) String() string {
+	args := []string{}
+	for _, arg := range f.Args {
+		s := FormatArg(arg)
+		if r := []rune(s); len(r) > maxFrameArg {
+			s = string(r[:maxFrameArg]) + "..."
+		}
+		args = append(args, s)
+	}
+	call := fmt.Sprintf("%s(%s)", f.Name, strings.Join(args, ", "))
+	if f.Macro {
+		call = "macro " + call
+	}
+	if f.Pos.IsValid() {
+		call += " at " + f.Pos.String()
+	}
+	return call
+}
+
 func NewError(format string, a ...interface{}) *Error {
 	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
 type Function struct {
 	Parameters []*ast.Identifier
+	ReturnType *ast.TypeAnnotation
 	Body       *ast.BlockStatement
 	Env        *Environment
 }
@@ -124,19 +186,53 @@ func (f *Function) Inspect() string {
 
 	params := []string{}
 	for _, p := range f.Parameters {
-		params = append(params, p.String())
+		params = append(params, p.Declaration())
 	}
 
 	out.WriteString("fn")
 	out.WriteString("(")
 	out.WriteString(strings.Join(params, ", "))
-	out.WriteString(") {\n")
+	out.WriteString(")")
+	if f.ReturnType != nil {
+		out.WriteString(": " + f.ReturnType.String())
+	}
+	out.WriteString(" {\n")
 	out.WriteString(f.Body.String())
 	out.WriteString("\n}")
 
 	return out.String()
 }
 
+// ParameterArity returns the number of arguments accepted by a function with these parameters
+func ParameterArity(params []*ast.Identifier) *Arity {
+	arity := &Arity{Max: len(params)}
+	for _, p := range params {
+		switch {
+		case p.Variadic:
+			arity.Max = -1
+		case p.Default == nil:
+			arity.Min++
+		}
+	}
+	return arity
+}
+
+// AnnotatedType returns the type of value allowed by a type annotation
+func AnnotatedType(ta *ast.TypeAnnotation) ObjectType {
+	return ObjectType(ast.TypeNames[ta.Name])
+}
+
+// CheckAnnotation returns an error if obj is not allowed by a type annotation (which may be nil)
+func CheckAnnotation(ta *ast.TypeAnnotation, obj Object) error {
+	if ta == nil || obj == nil {
+		return nil
+	}
+	if expected := AnnotatedType(ta); obj.Type() != expected {
+		return fmt.Errorf("type %s but expected %s", obj.Type(), expected)
+	}
+	return nil
+}
+
 type String struct {
 	Value string
 }
@@ -167,13 +263,50 @@ type Builtin struct {
 	Fn    BuiltinFunction
 	Flags []Flag
 	Help  string
+	// Mutating builtins change files or run commands. They are skipped in dry-run mode.
+	Mutating bool
+	// Arity is the number of (non-flag) arguments accepted, if known
+	Arity *Arity
+}
+
+// Arity is a range of argument counts. Max is -1 when there's no upper limit.
+type Arity struct {
+	Min, Max int
+}
+
+// Accepts reports whether n arguments are within range
+func (a *Arity) Accepts(n int) bool {
+	return a == nil || (n >= a.Min && (a.Max < 0 || n <= a.Max))
+}
+
+func (a *Arity) String() string {
+	switch {
+	case a.Max < 0:
+		return fmt.Sprintf("%d or more", a.Min)
+	case a.Min == a.Max:
+		return fmt.Sprintf("%d", a.Min)
+	default:
+		return fmt.Sprintf("%d to %d", a.Min, a.Max)
+	}
 }
 
 func (b *Builtin) Type() ObjectType { return BUILTIN_OBJ }
 func (b *Builtin) Inspect() string  { return "builtin function" }
 
+// Flag finds a flag by its name or long name
+func (b *Builtin) Flag(name string) (*Flag, bool) {
+	for i := range b.Flags {
+		if b.Flags[i].Name == name || (b.Flags[i].Long != "" && b.Flags[i].Long == name) {
+			return &b.Flags[i], true
+		}
+	}
+	return nil, false
+}
+
 type Flag struct {
-	Name      string
+	Name string
+	// Long is an optional alias, e.g. `recursive` for `r`
+	Long      string
 	ParamType ObjectType
 	Param     Object
 	Help      string
@@ -182,6 +315,21 @@ type Flag struct {
 func (b *Flag) Type() ObjectType { return FLAG_OBJ }
 func (b *Flag) Inspect() string  { return "flag" }
 
+// TakesValue reports whether a flag needs a value, e.g. `n: 10`. Other flags are booleans.
+func (b *Flag) TakesValue() bool {
+	return b.ParamType == INTEGER_OBJ || b.ParamType == STRING_OBJ
+}
+
+// WithParam returns a copy of the flag with its value set, so that the builtin's definition is left alone
+func (b *Flag) WithParam(val Object) (*Flag, error) {
+	if val != nil && val.Type() != b.ParamType {
+		return nil, fmt.Errorf("unexpected value type %s for flag '%s'. Expected %s", val.Type(), b.Name, b.ParamType)
+	}
+	f := *b
+	f.Param = val
+	return &f, nil
+}
+
 const (
 	Bool = 0
 )
@@ -281,3 +429,34 @@ func (h *Pipes) Type() ObjectType { return PIPES_OBJ }
 func (h *Pipes) Inspect() string {
 	return "|"
 }
+
+// Pipeline is a sequence of builtin calls which can be run, each piped into the next,
+// as many times as needed
+type Pipeline struct {
+	Stages []*Stage
+}
+
+func (p *Pipeline) Type() ObjectType { return PIPELINE_OBJ }
+
+func (p *Pipeline) Inspect() string {
+	stages := []string{}
+	for _, s := range p.Stages {
+		stages = append(stages, s.String())
+	}
+	return "pipeline(" + strings.Join(stages, ", ") + ")"
+}
+
+// Stage is one call in a pipeline, with its arguments already evaluated
+type Stage struct {
+	Name string
+	Fn   *Builtin
+	Args []Object
+}
+
+func (s *Stage) String() string {
+	args := []string{}
+	for _, arg := range s.Args {
+		args = append(args, FormatArg(arg))
+	}
+	return s.Name + "(" + strings.Join(args, ", ") + ")"
+}
@@ -58,3 +58,22 @@ func TestIntegerHashKey(t *testing.T) {
 		t.Errorf("integers with twoerent content have same hash keys")
 	}
 }
+
+func TestFormatArg(t *testing.T) {
+	tests := []struct {
+		arg      Object
+		expected string
+	}{
+		{&String{Value: "a b"}, `"a b"`},
+		{&Integer{Value: 3}, "3"},
+		{&Flag{Name: "l"}, "l"},
+		{&Flag{Name: "n", Param: &Integer{Value: 10}}, "n: 10"},
+		{&Flag{Name: "s", Param: &String{Value: "x"}}, `s: "x"`},
+		{&Function{}, "fn"},
+	}
+	for _, tt := range tests {
+		if got := FormatArg(tt.arg); got != tt.expected {
+			t.Errorf("expected %s, got %s", tt.expected, got)
+		}
+	}
+}
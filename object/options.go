@@ -0,0 +1,100 @@
+package object
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+)
+
+// Options are the interpreter's runtime settings, toggled with `set` and `unset`.
+// They are shared by enclosed environments.
+type Options struct {
+	mu     sync.RWMutex
+	values map[string]bool
+	// Log receives trace and dry-run output. It is the interpreter's stderr, even inside pipes.
+	Log io.Writer
+}
+
+// Option names
+const (
+	// OptTrace prints each builtin call to stderr before it runs
+	OptTrace = "trace"
+	// OptDryRun logs mutating builtins instead of running them
+	OptDryRun = "dryrun"
+	// OptErrExit aborts on any failure, including failures in loops and piped stages
+	OptErrExit = "errexit"
+	// OptNoUnset makes undefined variables in string templates an error
+	OptNoUnset = "nounset"
+	// OptPipefail fails a pipeline when any of its stages fail, not just the last one
+	OptPipefail = "pipefail"
+)
+
+var optionHelp = map[string]string{
+	OptTrace:    "print each builtin call to stderr before it runs",
+	OptDryRun:   "log mutating builtins instead of running them",
+	OptErrExit:  "abort on any failure, including failures in loops and piped stages",
+	OptNoUnset:  "make undefined variables in string templates an error",
+	OptPipefail: "fail a pipeline when any of its stages fail",
+}
+
+// NewOptions initializes Options with everything switched off
+func NewOptions(log io.Writer) *Options {
+	return &Options{values: map[string]bool{}, Log: log}
+}
+
+// OptionNames returns the names of all supported options
+func OptionNames() []string {
+	names := []string{}
+	for name := range optionHelp {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Set switches an option on or off
+func (o *Options) Set(name string, on bool) error {
+	if _, ok := optionHelp[name]; !ok {
+		return fmt.Errorf("unknown option '%s'", name)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.values[name] = on
+	return nil
+}
+
+// Swap switches an option on or off, returning its previous value
+func (o *Options) Swap(name string, on bool) (bool, error) {
+	if _, ok := optionHelp[name]; !ok {
+		return false, fmt.Errorf("unknown option '%s'", name)
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	prev := o.values[name]
+	o.values[name] = on
+	return prev, nil
+}
+
+// Is reports whether an option is switched on
+func (o *Options) Is(name string) bool {
+	if o == nil {
+		return false
+	}
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.values[name]
+}
+
+// Strict reports whether failed piped stages should fail the pipeline
+func (o *Options) Strict() bool {
+	return o.Is(OptErrExit) || o.Is(OptPipefail)
+}
+
+// Tracef prints a trace line for the given source line, when tracing is on
+func (o *Options) Tracef(line int, format string, a ...interface{}) {
+	if !o.Is(OptTrace) || o.Log == nil {
+		return
+	}
+	fmt.Fprintf(o.Log, "+ %d: %s\n", line, fmt.Sprintf(format, a...))
+}
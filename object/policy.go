@@ -0,0 +1,169 @@
+package object
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Policy restricts the capabilities available to builtins, for running untrusted scripts.
+// A nil Policy allows everything.
+type Policy struct {
+	// DenyExec prevents running external commands with `$`
+	DenyExec bool
+	// ExecAllowList, when non-empty, permits only the listed commands. Listing "ls" allows
+	// the name ls, found in $PATH, but not a path such as ./ls, which must be listed itself.
+	ExecAllowList []string
+	// DenyNetwork prevents network access (e.g. `http.Get`)
+	DenyNetwork bool
+	// NetworkAllowList, when non-empty, permits only the listed hosts
+	NetworkAllowList []string
+	// DenyWrite prevents creating, modifying or removing files
+	DenyWrite bool
+	// Root, when set, denies access to any path outside of this directory
+	Root string
+	// DenyExit prevents `exit` from exiting the process
+	DenyExit bool
+}
+
+// CheckExec reports whether an external command may be run
+func (p *Policy) CheckExec(command string) error {
+	if p == nil {
+		return nil
+	}
+	if p.DenyExec {
+		return fmt.Errorf("sandbox: exec denied: '%s'", command)
+	}
+	if len(p.ExecAllowList) > 0 && !p.execAllowed(command) {
+		return fmt.Errorf("sandbox: exec denied: '%s' is not in the allow list", command)
+	}
+	return nil
+}
+
+// execAllowed matches a command against the allow list exactly. A path such as ./ls isn't
+// allowed by listing ls, as it could be any program.
+func (p *Policy) execAllowed(command string) bool {
+	if contains(p.ExecAllowList, command) {
+		return true
+	}
+	if strings.ContainsRune(command, '/') || strings.ContainsRune(command, filepath.Separator) {
+		return false
+	}
+	// a name is allowed by listing the program it resolves to
+	resolved, err := exec.LookPath(command)
+	return err == nil && contains(p.ExecAllowList, resolved)
+}
+
+// CheckNetwork reports whether a URL may be accessed
+func (p *Policy) CheckNetwork(rawurl string) error {
+	if p == nil {
+		return nil
+	}
+	if p.DenyNetwork {
+		return fmt.Errorf("sandbox: network access denied: '%s'", rawurl)
+	}
+	if len(p.NetworkAllowList) > 0 {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return fmt.Errorf("sandbox: network access denied: %v", err)
+		}
+		if !contains(p.NetworkAllowList, u.Host, u.Hostname()) {
+			return fmt.Errorf("sandbox: network access denied: '%s' is not in the allow list", u.Host)
+		}
+	}
+	return nil
+}
+
+// CheckRead reports whether paths may be accessed
+func (p *Policy) CheckRead(paths ...string) error {
+	if p == nil {
+		return nil
+	}
+	for _, path := range paths {
+		if err := p.checkRoot(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckWrite reports whether paths may be created, modified or removed
+func (p *Policy) CheckWrite(paths ...string) error {
+	if p == nil {
+		return nil
+	}
+	for _, path := range paths {
+		if p.DenyWrite {
+			return fmt.Errorf("sandbox: write denied: '%s'", path)
+		}
+		if err := p.checkRoot(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckExit reports whether the process may be exited
+func (p *Policy) CheckExit() error {
+	if p != nil && p.DenyExit {
+		return fmt.Errorf("sandbox: exit denied")
+	}
+	return nil
+}
+
+func (p *Policy) checkRoot(path string) error {
+	if p.Root == "" {
+		return nil
+	}
+	root, err := realPath(p.Root)
+	if err != nil {
+		return fmt.Errorf("sandbox: invalid root: %v", err)
+	}
+	real, err := realPath(path)
+	if err != nil {
+		return fmt.Errorf("sandbox: %v", err)
+	}
+	rel, err := filepath.Rel(root, real)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("sandbox: path '%s' is outside of root '%s'", path, p.Root)
+	}
+	return nil
+}
+
+// realPath resolves symlinks for as much of the path as exists already
+func realPath(path string) (string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	real, err := filepath.EvalSymlinks(abs)
+	if err == nil {
+		return real, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", err
+	}
+	dir, base := filepath.Split(abs)
+	if dir == abs || base == "" {
+		return abs, nil
+	}
+	realDir, err := realPath(filepath.Clean(dir))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(realDir, base), nil
+}
+
+func contains(list []string, candidates ...string) bool {
+	for _, item := range list {
+		for _, c := range candidates {
+			if item == c {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package object
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+)
+
+// WorkDir is an interpreter's working directory, along with its pushd/popd stack.
+// It is shared by enclosed environments, so that `cd` inside a function applies to the whole interpreter.
+type WorkDir struct {
+	mu    sync.RWMutex
+	dir   string
+	stack []string
+}
+
+// NewWorkDir initializes a WorkDir
+func NewWorkDir(dir string) *WorkDir {
+	return &WorkDir{dir: filepath.Clean(dir)}
+}
+
+// Get returns the current directory
+func (w *WorkDir) Get() string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.dir
+}
+
+// Set changes the current directory. Relative paths are resolved against the current directory
+func (w *WorkDir) Set(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.dir = w.resolve(dir)
+}
+
+// Resolve returns an absolute path, resolving relative paths against the current directory
+func (w *WorkDir) Resolve(path string) string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.resolve(path)
+}
+
+func (w *WorkDir) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(w.dir, path)
+}
+
+// Push saves the current directory onto the stack and changes to dir
+func (w *WorkDir) Push(dir string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stack = append(w.stack, w.dir)
+	w.dir = w.resolve(dir)
+}
+
+// Pop returns to the most recently pushed directory
+func (w *WorkDir) Pop() (string, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.stack) == 0 {
+		return "", errors.New("directory stack empty")
+	}
+	w.dir = w.stack[len(w.stack)-1]
+	w.stack = w.stack[:len(w.stack)-1]
+	return w.dir, nil
+}
+
+// Stack returns the current directory followed by the pushed directories, most recent first
+func (w *WorkDir) Stack() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	dirs := []string{w.dir}
+	for i := len(w.stack) - 1; i >= 0; i-- {
+		dirs = append(dirs, w.stack[i])
+	}
+	return dirs
+}
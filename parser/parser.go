@@ -53,7 +53,15 @@ type (
 // Parser attempts to make sense of tokens
 type Parser struct {
 	l      *lexer.Lexer
-	errors []string
+	errors []*Error
+	// failed is set once the current statement has an error. Any further errors in the
+	// statement are dropped, as they most likely follow on from the first.
+	failed bool
+	// comments are collected as they are read, rather than parsed as part of a statement
+	comments []*ast.Comment
+	// brackets are those open at the current token, so that a failed statement can be
+	// skipped as a whole. The `(` of a for loop is recorded as FOR, as it holds statements.
+	brackets []token.TokenType
 
 	curToken  token.Token
 	peekToken token.Token
@@ -66,7 +74,7 @@ type Parser struct {
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
 		l:      l,
-		errors: []string{},
+		errors: []*Error{},
 	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
@@ -86,7 +94,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.LBRACE, p.parseHashLiteral)
 
 	// non-monkey, smoosh prefixes:
-	p.registerPrefix(token.HASH, p.parseCommentLiteral)
+	p.registerPrefix(token.ELLIPSIS, p.parseSpreadExpression)
 	p.registerPrefix(token.FOR, p.parseForExpression)
 	p.registerPrefix(token.RANGE, p.parseRangeExpression)
 	p.registerPrefix(token.BACKY, p.parseBacktickLiteral)
@@ -113,8 +121,27 @@ func New(l *lexer.Lexer) *Parser {
 }
 
 func (p *Parser) nextToken() {
+	prev := p.curToken.Type
 	p.curToken = p.peekToken
+	switch p.curToken.Type {
+	case token.LPAREN:
+		if prev == token.FOR {
+			p.brackets = append(p.brackets, token.FOR)
+		} else {
+			p.brackets = append(p.brackets, token.LPAREN)
+		}
+	case token.LBRACE, token.LBRACKET:
+		p.brackets = append(p.brackets, p.curToken.Type)
+	case token.RPAREN, token.RBRACE, token.RBRACKET:
+		if len(p.brackets) > 0 {
+			p.brackets = p.brackets[:len(p.brackets)-1]
+		}
+	}
 	p.peekToken = p.l.NextToken()
+	for p.peekToken.Type == token.HASH {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.l.NextToken()
+	}
 }
 
 func (p *Parser) curTokenIs(t token.TokenType) bool {
@@ -134,20 +161,44 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	return false
 }
 
+// Error is a parse error at a position in the source
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
 // Errors returns a list of parse errors
 func (p *Parser) Errors() []string {
+	msgs := []string{}
+	for _, err := range p.errors {
+		msgs = append(msgs, err.Error())
+	}
+	return msgs
+}
+
+// ErrorList returns the parse errors along with their positions
+func (p *Parser) ErrorList() []*Error {
 	return p.errors
 }
 
+func (p *Parser) errorf(pos token.Position, format string, a ...interface{}) {
+	if p.failed {
+		return
+	}
+	p.failed = true
+	p.errors = append(p.errors, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)})
+}
+
 func (p *Parser) peekError(t token.TokenType) {
-	msg := fmt.Sprintf("L%d: expected next token to be %s, got %s instead", p.peekToken.Line,
-		t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.peekToken.Pos(), "expected next token to be %s, got %s instead", t, p.peekToken.Type)
 }
 
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	msg := fmt.Sprintf("L%d: no prefix parse function for %s found", p.curToken.Line, t)
-	p.errors = append(p.errors, msg)
+	p.errorf(p.curToken.Pos(), "no prefix parse function for %s found", t)
 }
 
 // ParseProgram parses an entire proram into an AST
@@ -162,11 +213,63 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	program.Comments = p.comments
 
 	return program
 }
 
+// parseStatement parses a statement. A statement with errors is skipped, so that parsing
+// can carry on with the next one.
 func (p *Parser) parseStatement() ast.Statement {
+	outer := p.failed
+	p.failed = false
+	// the brackets which were open before the statement
+	depth := len(p.brackets)
+	if p.curTokenIs(token.LPAREN) || p.curTokenIs(token.LBRACE) || p.curTokenIs(token.LBRACKET) {
+		depth--
+	}
+	stmt := p.parseStatementKind()
+	failed := p.failed
+	p.failed = outer
+	if failed {
+		p.synchronize(depth)
+		return nil
+	}
+	return stmt
+}
+
+// synchronize skips to the end of a failed statement: once any brackets it opened are
+// closed, that's a `;`, the end of the line, or the end of the enclosing block
+func (p *Parser) synchronize(depth int) {
+	for !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.SEMICOLON) || p.peekTokenIs(token.VAR) || p.peekTokenIs(token.RETURN) {
+			// these only appear in blocks (or a for loop's header), so any brackets
+			// which are open within the statement were never closed
+			p.closeBrackets(depth)
+		}
+		if len(p.brackets) <= depth && (p.curTokenIs(token.SEMICOLON) ||
+			p.peekTokenIs(token.RBRACE) || p.peekTokenIs(token.EOF) ||
+			p.peekToken.Line != p.curToken.Line) {
+			return
+		}
+		p.nextToken()
+	}
+}
+
+// closeBrackets forgets the parentheses and square brackets left open since depth, back to
+// the innermost block
+func (p *Parser) closeBrackets(depth int) {
+	for len(p.brackets) > depth {
+		switch p.brackets[len(p.brackets)-1] {
+		case token.LPAREN, token.LBRACKET:
+			p.brackets = p.brackets[:len(p.brackets)-1]
+		default:
+			return
+		}
+	}
+}
+
+func (p *Parser) parseStatementKind() ast.Statement {
 	switch p.curToken.Type {
 	case token.VAR:
 		return p.parseVarStatement()
@@ -182,15 +285,15 @@ func (p *Parser) parseStatement() ast.Statement {
 }
 
 func (p *Parser) parseVarStatement() *ast.AssignStatement {
+	stmt := &ast.AssignStatement{Token: p.curToken}
 	if !p.expectPeek(token.IDENT) {
 		return nil
 	}
-	stmt := &ast.AssignStatement{Token: p.curToken}
 	return p.parseAssignStament(stmt)
 }
 
 func (p *Parser) parseReassignStatement() *ast.AssignStatement {
-	stmt := &ast.AssignStatement{Token: token.Token{Type: token.VAR}}
+	stmt := &ast.AssignStatement{Token: p.curToken}
 	return p.parseAssignStament(stmt)
 }
 
@@ -199,6 +302,7 @@ func (p *Parser) parseAssignStament(stmt *ast.AssignStatement) *ast.AssignStatem
 		Token: p.curToken,
 		Value: p.curToken.Literal,
 	}
+	stmt.Name.Type = p.parseTypeAnnotation()
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -287,8 +391,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("L%d: could not parse %q as integer", p.curToken.Line, p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		p.errorf(p.curToken.Pos(), "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -305,10 +408,6 @@ func (p *Parser) parseBacktickLiteral() ast.Expression {
 	return &ast.BacktickLiteral{Token: p.curToken, Value: p.curToken.Literal}
 }
 
-func (p *Parser) parseCommentLiteral() ast.Expression {
-	return &ast.CommentLiteral{Token: p.curToken, Value: p.curToken.Literal}
-}
-
 func (p *Parser) parsePrefixExpression() ast.Expression {
 	expression := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -452,7 +551,8 @@ func (p *Parser) parsePipeExpression() ast.Expression {
 		expression.Destination = d
 		return expression
 	}
-	return nil
+	expression.Target = destination
+	return expression
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
@@ -468,6 +568,7 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	block.Rbrace = p.curToken
 
 	return block
 }
@@ -480,6 +581,7 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 	}
 
 	lit.Parameters = p.parseFunctionParameters()
+	lit.ReturnType = p.parseTypeAnnotation()
 
 	if !p.expectPeek(token.LBRACE) {
 		return nil
@@ -499,30 +601,121 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	}
 
 	p.nextToken()
-
-	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-	identifiers = append(identifiers, ident)
+	identifiers = append(identifiers, p.parseFunctionParameter())
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
-		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
-		identifiers = append(identifiers, ident)
+		identifiers = append(identifiers, p.parseFunctionParameter())
 	}
 
 	if !p.expectPeek(token.RPAREN) {
 		return nil
 	}
 
+	p.checkParameterOrder(identifiers)
 	return identifiers
 }
 
+// parseFunctionParameter parses a parameter such as `x`, `n: int = 10` or `...rest`
+func (p *Parser) parseFunctionParameter() *ast.Identifier {
+	variadic := false
+	if p.curTokenIs(token.ELLIPSIS) {
+		variadic = true
+		p.nextToken()
+	}
+	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal, Variadic: variadic}
+	ident.Type = p.parseTypeAnnotation()
+	if p.peekTokenIs(token.ASSIGN) {
+		p.nextToken()
+		p.nextToken()
+		ident.Default = p.parseExpression(LOWEST)
+	}
+	return ident
+}
+
+// checkParameterOrder requires parameters with defaults to follow the others, and a variadic parameter to be last
+func (p *Parser) checkParameterOrder(identifiers []*ast.Identifier) {
+	defaults := false
+	for i, ident := range identifiers {
+		switch {
+		case ident.Variadic && i != len(identifiers)-1:
+			p.errorf(ident.Pos(), "variadic parameter '%s' must be last", ident.Value)
+		case ident.Variadic && ident.Default != nil:
+			p.errorf(ident.Pos(), "variadic parameter '%s' cannot have a default", ident.Value)
+		case ident.Default != nil:
+			defaults = true
+		case defaults && !ident.Variadic:
+			p.errorf(ident.Pos(), "parameter '%s' needs a default, as it follows one with a default", ident.Value)
+		}
+	}
+}
+
+// parseTypeAnnotation parses an optional `: type` following a name or parameter list
+func (p *Parser) parseTypeAnnotation() *ast.TypeAnnotation {
+	if !p.peekTokenIs(token.COLON) {
+		return nil
+	}
+	p.nextToken()
+	p.nextToken()
+	if _, ok := ast.TypeNames[p.curToken.Literal]; !ok {
+		p.errorf(p.curToken.Pos(), "unknown type '%s'", p.curToken.Literal)
+		return nil
+	}
+	return &ast.TypeAnnotation{Token: p.curToken, Name: p.curToken.Literal}
+}
+
+func (p *Parser) parseSpreadExpression() ast.Expression {
+	expression := &ast.SpreadExpression{Token: p.curToken}
+	p.nextToken()
+	expression.Value = p.parseExpression(PREFIX)
+	return expression
+}
+
 func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	exp := &ast.CallExpression{Token: p.curToken, Function: function}
-	exp.Arguments = p.parseExpressionList(token.RPAREN)
+	exp.Arguments = p.parseCallArguments()
+	exp.Rparen = p.curToken
 	return exp
 }
 
+// parseCallArguments is like parseExpressionList, but also allows named arguments
+func (p *Parser) parseCallArguments() []ast.Expression {
+	list := []ast.Expression{}
+
+	if p.peekTokenIs(token.RPAREN) {
+		p.nextToken()
+		return list
+	}
+
+	p.nextToken()
+	list = append(list, p.parseCallArgument())
+
+	for p.peekTokenIs(token.COMMA) {
+		p.nextToken()
+		p.nextToken()
+		list = append(list, p.parseCallArgument())
+	}
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	return list
+}
+
+func (p *Parser) parseCallArgument() ast.Expression {
+	if !p.curTokenIs(token.IDENT) || !p.peekTokenIs(token.COLON) {
+		return p.parseExpression(LOWEST)
+	}
+	arg := &ast.NamedArgument{Token: p.curToken}
+	arg.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.nextToken()
+	p.nextToken()
+	arg.Value = p.parseExpression(LOWEST)
+	return arg
+}
+
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
 	list := []ast.Expression{}
 
@@ -551,6 +744,7 @@ func (p *Parser) parseArrayLiteral() ast.Expression {
 	array := &ast.ArrayLiteral{Token: p.curToken}
 
 	array.Elements = p.parseExpressionList(token.RBRACKET)
+	array.Rbracket = p.curToken
 
 	return array
 }
@@ -564,6 +758,7 @@ func (p *Parser) parseIndexExpression(left ast.Expression) ast.Expression {
 	if !p.expectPeek(token.RBRACKET) {
 		return nil
 	}
+	exp.Rbracket = p.curToken
 
 	return exp
 }
@@ -584,6 +779,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		value := p.parseExpression(LOWEST)
 
 		hash.Pairs[key] = value
+		hash.Keys = append(hash.Keys, key)
 
 		if !p.peekTokenIs(token.RBRACE) && !p.expectPeek(token.COMMA) {
 			return nil
@@ -593,6 +789,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 	if !p.expectPeek(token.RBRACE) {
 		return nil
 	}
+	hash.Rbrace = p.curToken
 
 	return hash
 }
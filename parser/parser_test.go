@@ -2,10 +2,12 @@ package parser
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/laher/smoosh/ast"
 	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/token"
 )
 
 func TestVarStatements(t *testing.T) {
@@ -587,6 +589,127 @@ func TestFunctionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestTypeAnnotationParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"var x: string = \"a\"", "var x: string = \"a\" "},
+		{"var x: int = 1", "var x: int = 1 "},
+		{"fn(path: string, n: int): array { path }", "fn(path: string, n: int): array {\n  path\n}\n"},
+		{"fn(f: fn, x) { x }", "fn(f: fn, x) {\n  x\n}\n"},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("program.Statements does not contain 1 statement. got=%d", len(program.Statements))
+		}
+		if actual := program.Statements[0].String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestParameterParsing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+		errors   []string
+	}{
+		{"fn(a, b = 10) { a }", "fn(a, b = 10) {\n  a\n}\n", nil},
+		{"fn(a: int = 1 + 2, ...rest: string) { a }", "fn(a: int = (1 + 2), ...rest: string) {\n  a\n}\n", nil},
+		{"f(1, ...xs)", "f(1, ...xs)", nil},
+		{"head(n: 10, lines: x + 1, \"a\")", "head(n: 10, lines: (x + 1), \"a\")", nil},
+		{"fn(...rest, a) { a }", "", []string{"1:7: variadic parameter 'rest' must be last"}},
+		{"fn(a = 1, b) { a }", "", []string{"1:11: parameter 'b' needs a default, as it follows one with a default"}},
+		{"fn(...rest = []) { rest }", "", []string{"1:7: variadic parameter 'rest' cannot have a default"}},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		if tt.errors != nil {
+			if strings.Join(p.Errors(), "\n") != strings.Join(tt.errors, "\n") {
+				t.Errorf("expected errors %q, got %q", tt.errors, p.Errors())
+			}
+			continue
+		}
+		checkParserErrors(t, p)
+		if actual := program.Statements[0].String(); actual != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, actual)
+		}
+	}
+}
+
+func TestUnknownTypeAnnotation(t *testing.T) {
+	l := lexer.New("var x: float = 1")
+	p := New(l)
+	p.ParseProgram()
+	errors := p.Errors()
+	if len(errors) == 0 || errors[0] != "1:8: unknown type 'float'" {
+		t.Errorf("unexpected errors: %v", errors)
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	l := lexer.NewFile("x.smoosh", "var x = 1\nvar y = )")
+	p := New(l)
+	p.ParseProgram()
+	errs := p.ErrorList()
+	if len(errs) == 0 {
+		t.Fatalf("expected an error")
+	}
+	if errs[0].Pos != (token.Position{File: "x.smoosh", Line: 2, Column: 9}) {
+		t.Errorf("unexpected position: %v", errs[0].Pos)
+	}
+	if errs[0].Error() != "x.smoosh:2:9: no prefix parse function for ) found" {
+		t.Errorf("unexpected error: %s", errs[0])
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `var x = ;
+echo("ok")
+var y = (1 + 2
+var f = fn() {
+  len(;
+  echo(1)
+  var = 2
+}
+echo(x)
+var g = fn(x y) {
+  echo(x)
+}
+echo(g)`
+	expected := []string{
+		"1:9: no prefix parse function for ; found",
+		"4:1: expected next token to be ), got VAR instead",
+		"5:7: no prefix parse function for ; found",
+		"7:7: expected next token to be IDENT, got = instead",
+		"10:14: expected next token to be ), got IDENT instead",
+	}
+
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	if strings.Join(p.Errors(), "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected errors:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(p.Errors(), "\n"))
+	}
+	// the statements which parsed are kept
+	if len(program.Statements) != 4 {
+		t.Fatalf("expected 4 statements, got %d", len(program.Statements))
+	}
+	if got := program.Statements[1].(*ast.AssignStatement).Value.(*ast.FunctionLiteral).Body.String(); got != "  echo(1)\n" {
+		t.Errorf("unexpected function body %q", got)
+	}
+}
+
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -1126,3 +1249,29 @@ func TestMacroLiteralParsing(t *testing.T) {
 
 	testInfixExpression(t, bodyStmt.Expression, "x", "+", "y")
 }
+
+func TestComments(t *testing.T) {
+	input := `#!/usr/bin/smoosh
+echo(1, # one
+  2)
+# done`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+	}
+	if got := program.Statements[0].String(); got != "echo(1, 2)" {
+		t.Errorf("unexpected statement %q", got)
+	}
+	expected := []string{"1:1: #!/usr/bin/smoosh", "2:9: # one", "4:1: # done"}
+	if len(program.Comments) != len(expected) {
+		t.Fatalf("expected %d comments, got %d", len(expected), len(program.Comments))
+	}
+	for i, c := range program.Comments {
+		if got := c.Pos().String() + ": " + c.Text(); got != expected[i] {
+			t.Errorf("comment %d: expected %q, got %q", i, expected[i], got)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package readline
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// History is the lines entered previously, oldest first. When it has a file, lines are
+// appended to the file as they're added, so that they're kept between sessions.
+type History struct {
+	lines []string
+	max   int
+	path  string
+}
+
+// NewHistory returns an empty history of up to max lines, which isn't saved
+func NewHistory(max int) *History {
+	return &History{max: max}
+}
+
+// LoadHistory loads the last max lines of a history file, which needn't exist yet
+func LoadHistory(path string, max int) (*History, error) {
+	h := &History{max: max, path: path}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	count := 0
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.add(line)
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if count > 2*max {
+		// compact the file, so that it doesn't grow forever
+		data := strings.Join(h.lines, "\n") + "\n"
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// Lines returns the lines in the history, oldest first
+func (h *History) Lines() []string {
+	return h.lines
+}
+
+// Add adds a line to the history, unless it's empty or repeats the last line, and
+// appends it to the history file
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || len(h.lines) > 0 && h.lines[len(h.lines)-1] == line {
+		return nil
+	}
+	h.add(line)
+	if h.path == "" {
+		return nil
+	}
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (h *History) add(line string) {
+	h.lines = append(h.lines, line)
+	if len(h.lines) > h.max {
+		h.lines = h.lines[len(h.lines)-h.max:]
+	}
+}
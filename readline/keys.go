@@ -0,0 +1,150 @@
+package readline
+
+import (
+	"bufio"
+	"strings"
+)
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUnknown
+	keyEnter
+	keyTab
+	keyBackspace
+	keyDelete
+	keyLeft
+	keyRight
+	keyWordLeft
+	keyWordRight
+	keyHome
+	keyEnd
+	keyUp
+	keyDown
+	keyDeleteWordBack
+	keyDeleteWordForward
+	keyKillStart
+	keyKillEnd
+	keyClear
+	keySearch
+	keyCancel
+	keyInterrupt
+	keyEOF
+)
+
+// key is a key press. ru is only set for keyRune.
+type key struct {
+	code keyCode
+	ru   rune
+}
+
+// control keys, as emacs and readline bind them
+var controlKeys = map[rune]keyCode{
+	1:   keyHome,           // Ctrl-A
+	2:   keyLeft,           // Ctrl-B
+	3:   keyInterrupt,      // Ctrl-C
+	4:   keyEOF,            // Ctrl-D
+	5:   keyEnd,            // Ctrl-E
+	6:   keyRight,          // Ctrl-F
+	7:   keyCancel,         // Ctrl-G
+	8:   keyBackspace,      // Ctrl-H
+	9:   keyTab,            // Ctrl-I
+	10:  keyEnter,          // Ctrl-J
+	11:  keyKillEnd,        // Ctrl-K
+	12:  keyClear,          // Ctrl-L
+	13:  keyEnter,          // Ctrl-M
+	14:  keyDown,           // Ctrl-N
+	16:  keyUp,             // Ctrl-P
+	18:  keySearch,         // Ctrl-R
+	21:  keyKillStart,      // Ctrl-U
+	23:  keyDeleteWordBack, // Ctrl-W
+	127: keyBackspace,
+}
+
+// readKey reads a key press, decoding escape sequences
+func readKey(r *bufio.Reader) (key, error) {
+	ru, _, err := r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	if ru == 0x1b {
+		return readEscape(r)
+	}
+	if code, ok := controlKeys[ru]; ok {
+		return key{code: code}, nil
+	}
+	if ru < 0x20 {
+		return key{code: keyUnknown}, nil
+	}
+	return key{code: keyRune, ru: ru}, nil
+}
+
+// readEscape reads the rest of an escape sequence: ESC [ or ESC O for special keys, or
+// ESC and a key for Alt and that key
+func readEscape(r *bufio.Reader) (key, error) {
+	next, _, err := r.ReadRune()
+	if err != nil {
+		return key{}, err
+	}
+	switch next {
+	case 'b', 'B':
+		return key{code: keyWordLeft}, nil
+	case 'f', 'F':
+		return key{code: keyWordRight}, nil
+	case 'd', 'D':
+		return key{code: keyDeleteWordForward}, nil
+	case 127, 8:
+		return key{code: keyDeleteWordBack}, nil
+	case '[', 'O':
+	default:
+		return key{code: keyUnknown}, nil
+	}
+	// parameters, then a final byte
+	params := ""
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return key{}, err
+		}
+		if b >= 0x40 && b <= 0x7e {
+			return csiKey(params, b), nil
+		}
+		params += string(b)
+	}
+}
+
+func csiKey(params string, final byte) key {
+	// modifiers follow a ';', e.g. 1;5D is Ctrl-Left
+	modified := strings.Contains(params, ";")
+	switch final {
+	case 'A':
+		return key{code: keyUp}
+	case 'B':
+		return key{code: keyDown}
+	case 'C':
+		if modified {
+			return key{code: keyWordRight}
+		}
+		return key{code: keyRight}
+	case 'D':
+		if modified {
+			return key{code: keyWordLeft}
+		}
+		return key{code: keyLeft}
+	case 'H':
+		return key{code: keyHome}
+	case 'F':
+		return key{code: keyEnd}
+	case '~':
+		switch params {
+		case "1", "7":
+			return key{code: keyHome}
+		case "4", "8":
+			return key{code: keyEnd}
+		case "3":
+			return key{code: keyDelete}
+		}
+	}
+	return key{code: keyUnknown}
+}
@@ -0,0 +1,375 @@
+// Package readline reads lines from a terminal, with emacs-style editing keys, history
+// and reverse search.
+//
+// The terminal is put into raw mode while a line is read. Input which isn't a terminal is
+// read the same way, which is mostly useful for testing.
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/laher/smoosh/term"
+)
+
+// ErrInterrupted is returned by ReadLine when Ctrl-C is pressed
+var ErrInterrupted = errors.New("interrupted")
+
+// Editor reads lines
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// fd is the input terminal, or -1 if input isn't a terminal
+	fd      int
+	History *History
+	// Complete is called for Tab with the text before the cursor. It returns the word
+	// being completed, which ends the text, and the words which could replace it.
+	Complete func(head string) (word string, candidates []string)
+}
+
+// New returns an editor reading from in and echoing to out
+func New(in io.Reader, out io.Writer, history *History) *Editor {
+	fd := -1
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd = int(f.Fd())
+	}
+	if history == nil {
+		history = NewHistory(1000)
+	}
+	return &Editor{in: bufio.NewReader(in), out: out, fd: fd, History: history}
+}
+
+// IsTerminal reports whether input is from a terminal
+func (e *Editor) IsTerminal() bool {
+	return e.fd >= 0
+}
+
+// ReadLine reads a line after printing a prompt. It returns io.EOF for Ctrl-D on an empty
+// line, and ErrInterrupted for Ctrl-C. Lines aren't added to the history.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		state, err := term.MakeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer term.Restore(e.fd, state)
+	}
+	// only the last line of the prompt is redrawn
+	if i := strings.LastIndex(prompt, "\n"); i >= 0 {
+		e.write(strings.Replace(prompt[:i+1], "\n", "\r\n", -1))
+		prompt = prompt[i+1:]
+	}
+	s := &state{e: e, prompt: prompt, history: e.History.Lines()}
+	s.hist = len(s.history)
+	s.refresh()
+	for {
+		k, err := readKey(e.in)
+		if err != nil {
+			if err == io.EOF && len(s.buf) > 0 {
+				// the last line had no newline
+				e.write("\r\n")
+				return string(s.buf), nil
+			}
+			return "", err
+		}
+		done, err := s.handle(k)
+		if err != nil || done {
+			return string(s.buf), err
+		}
+		s.refresh()
+	}
+}
+
+func (e *Editor) write(s string) {
+	io.WriteString(e.out, s)
+}
+
+// state is the state of a line being edited
+type state struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+
+	history []string
+	// hist is the history line being shown, or len(history) for the new line, which is
+	// kept in saved meanwhile
+	hist  int
+	saved []rune
+
+	searching bool
+	query     []rune
+	match     int // the history line matching query, or -1
+	failing   bool
+}
+
+// handle handles a key, reporting whether the line is done
+func (s *state) handle(k key) (bool, error) {
+	if s.searching {
+		if handled, done := s.handleSearch(k); handled {
+			return done, nil
+		}
+	}
+	switch k.code {
+	case keyRune:
+		s.insert(k.ru)
+	case keyEnter:
+		s.e.write("\r\n")
+		return true, nil
+	case keyInterrupt:
+		s.e.write("^C\r\n")
+		s.buf = nil
+		return true, ErrInterrupted
+	case keyEOF:
+		if len(s.buf) == 0 {
+			s.e.write("\r\n")
+			return true, io.EOF
+		}
+		s.delete(s.pos, s.pos+1)
+	case keyBackspace:
+		if s.pos > 0 {
+			s.delete(s.pos-1, s.pos)
+		}
+	case keyDelete:
+		s.delete(s.pos, s.pos+1)
+	case keyLeft:
+		if s.pos > 0 {
+			s.pos--
+		}
+	case keyRight:
+		if s.pos < len(s.buf) {
+			s.pos++
+		}
+	case keyHome:
+		s.pos = 0
+	case keyEnd:
+		s.pos = len(s.buf)
+	case keyWordLeft:
+		s.pos = s.wordStart()
+	case keyWordRight:
+		s.pos = s.wordEnd()
+	case keyDeleteWordBack:
+		s.delete(s.wordStart(), s.pos)
+	case keyDeleteWordForward:
+		s.delete(s.pos, s.wordEnd())
+	case keyKillStart:
+		s.delete(0, s.pos)
+	case keyKillEnd:
+		s.delete(s.pos, len(s.buf))
+	case keyUp:
+		s.showHistory(s.hist - 1)
+	case keyDown:
+		s.showHistory(s.hist + 1)
+	case keyClear:
+		s.e.write("\x1b[H\x1b[2J")
+	case keyTab:
+		s.complete()
+	case keySearch:
+		s.searching = true
+		s.query = nil
+		s.match = -1
+		s.failing = false
+	}
+	return false, nil
+}
+
+// handleSearch handles a key during reverse search. Keys which don't edit the search
+// accept the match, and are then handled as usual.
+func (s *state) handleSearch(k key) (handled, done bool) {
+	switch k.code {
+	case keyRune:
+		s.query = append(s.query, k.ru)
+		if s.match >= 0 {
+			// the current match may still match
+			s.search(s.match)
+		} else {
+			s.search(len(s.history) - 1)
+		}
+		return true, false
+	case keyBackspace:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+		}
+		s.search(len(s.history) - 1)
+		return true, false
+	case keySearch:
+		s.search(s.match - 1)
+		return true, false
+	case keyCancel:
+		s.searching = false
+		return true, false
+	case keyInterrupt:
+		s.searching = false
+		return false, false
+	}
+	s.searching = false
+	if s.match >= 0 {
+		s.buf = []rune(s.history[s.match])
+		s.pos = len(s.buf)
+		s.hist = s.match
+	}
+	return false, false
+}
+
+// search finds the latest history line containing the query, starting from a line and
+// going back. The match is kept when nothing is found.
+func (s *state) search(from int) {
+	s.failing = false
+	if len(s.query) == 0 {
+		return
+	}
+	for i := from; i >= 0; i-- {
+		if strings.Contains(s.history[i], string(s.query)) {
+			s.match = i
+			return
+		}
+	}
+	s.failing = true
+}
+
+// complete replaces the word before the cursor with its completion, or with the prefix
+// its candidates have in common. When that doesn't add anything, the candidates are
+// listed below the line.
+func (s *state) complete() {
+	if s.e.Complete == nil {
+		return
+	}
+	word, candidates := s.e.Complete(string(s.buf[:s.pos]))
+	if len(candidates) == 0 {
+		s.e.write("\a")
+		return
+	}
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		prefix = commonPrefix(prefix, c)
+	}
+	if prefix != word {
+		start := s.pos - len([]rune(word))
+		s.buf = append(s.buf[:start:start], append([]rune(prefix), s.buf[s.pos:]...)...)
+		s.pos = start + len([]rune(prefix))
+		return
+	}
+	if len(candidates) > 1 {
+		s.e.write("\r\n" + strings.Join(candidates, "  ") + "\x1b[K\r\n")
+	}
+}
+
+func commonPrefix(a, b string) string {
+	ar, br := []rune(a), []rune(b)
+	i := 0
+	for i < len(ar) && i < len(br) && ar[i] == br[i] {
+		i++
+	}
+	return string(ar[:i])
+}
+
+func (s *state) showHistory(i int) {
+	if i < 0 || i > len(s.history) {
+		return
+	}
+	if s.hist == len(s.history) {
+		s.saved = s.buf
+	}
+	s.hist = i
+	if i == len(s.history) {
+		s.buf = s.saved
+	} else {
+		s.buf = []rune(s.history[i])
+	}
+	s.pos = len(s.buf)
+}
+
+func (s *state) insert(ru rune) {
+	s.buf = append(s.buf[:s.pos], append([]rune{ru}, s.buf[s.pos:]...)...)
+	s.pos++
+}
+
+// delete deletes the runes from start to end, moving the cursor to start
+func (s *state) delete(start, end int) {
+	if end > len(s.buf) {
+		end = len(s.buf)
+	}
+	if start >= end {
+		return
+	}
+	s.buf = append(s.buf[:start:start], s.buf[end:]...)
+	s.pos = start
+}
+
+func isWordRune(ru rune) bool {
+	return unicode.IsLetter(ru) || unicode.IsDigit(ru) || ru == '_'
+}
+
+// wordStart returns the start of the word before the cursor
+func (s *state) wordStart() int {
+	i := s.pos
+	for i > 0 && !isWordRune(s.buf[i-1]) {
+		i--
+	}
+	for i > 0 && isWordRune(s.buf[i-1]) {
+		i--
+	}
+	return i
+}
+
+// wordEnd returns the end of the word after the cursor
+func (s *state) wordEnd() int {
+	i := s.pos
+	for i < len(s.buf) && !isWordRune(s.buf[i]) {
+		i++
+	}
+	for i < len(s.buf) && isWordRune(s.buf[i]) {
+		i++
+	}
+	return i
+}
+
+// refresh redraws the line, and puts the cursor in place
+func (s *state) refresh() {
+	prompt, buf, pos := s.prompt, s.buf, s.pos
+	if s.searching {
+		prompt = fmt.Sprintf("(reverse-i-search)`%s': ", string(s.query))
+		if s.failing {
+			prompt = "(failing " + prompt[1:]
+		}
+		buf, pos = nil, 0
+		if s.match >= 0 {
+			buf = []rune(s.history[s.match])
+			// when failing, the match is of a shorter query
+			if i := strings.Index(s.history[s.match], string(s.query)); i >= 0 {
+				pos = len([]rune(s.history[s.match][:i]))
+			}
+		}
+	}
+	var out bytes.Buffer
+	out.WriteString("\r" + prompt + string(buf) + "\x1b[K\r")
+	if col := Width(prompt) + pos; col > 0 {
+		fmt.Fprintf(&out, "\x1b[%dC", col)
+	}
+	s.e.write(out.String())
+}
+
+// Width returns the width of text on a terminal, leaving out escape sequences such as
+// colors
+func Width(text string) int {
+	width := 0
+	escape := false
+	for _, ru := range text {
+		switch {
+		case escape:
+			// a sequence ends with a letter
+			escape = !unicode.IsLetter(ru)
+		case ru == 0x1b:
+			escape = true
+		case unicode.IsPrint(ru):
+			width++
+		}
+	}
+	return width
+}
@@ -0,0 +1,167 @@
+package readline
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadLine(t *testing.T) {
+	history := []string{"echo(1)", "ls(l)", "echo(2)"}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"typing", "abc\r", "abc"},
+		{"insert", "ac\x1b[Db\r", "abc"},
+		{"home and end", "bc\x01a\x05d\r", "abcd"},
+		{"home and end keys", "bc\x1b[Ha\x1b[Fd\r", "abcd"},
+		{"backspace and delete", "abx\x7fc\x01\x1b[3~\r", "bc"},
+		{"word left", "one two\x1bbx\r", "one xtwo"},
+		{"ctrl-left", "one two\x1b[1;5Dx\r", "one xtwo"},
+		{"delete word back", "one two\x17\r", "one "},
+		{"delete word forward", "one two\x01\x1bd\r", " two"},
+		{"kill start", "one two\x1b[D\x15\r", "o"},
+		{"kill end", "one two\x01\x1bf\x0b\r", "one"},
+		{"history up", "\x1b[A\x1b[A\r", "ls(l)"},
+		{"history down", "new\x1b[A\x1b[A\x1b[B\x1b[B\r", "new"},
+		{"history past the start", "\x10\x10\x10\x10\r", "echo(1)"},
+		{"reverse search", "\x12echo\r", "echo(2)"},
+		{"reverse search again", "\x12echo\x12\r", "echo(1)"},
+		{"reverse search then edit", "\x12ls\x1b[D\x7fn\r", "ls(n)"},
+		{"reverse search cancelled", "x\x12ls\x07\r", "x"},
+		{"reverse search failing", "\x12zz\r", ""},
+		{"no newline at the end", "abc", "abc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHistory(10)
+			for _, line := range history {
+				h.Add(line)
+			}
+			e := New(strings.NewReader(tt.input), ioutil.Discard, h)
+			line, err := e.ReadLine("> ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, line)
+			}
+		})
+	}
+}
+
+func TestReadLineErrors(t *testing.T) {
+	e := New(strings.NewReader("abc\x03\x04"), ioutil.Discard, nil)
+	if _, err := e.ReadLine("> "); err != ErrInterrupted {
+		t.Errorf("expected ErrInterrupted, got %v", err)
+	}
+	if _, err := e.ReadLine("> "); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestRefresh(t *testing.T) {
+	out := bytes.NewBuffer([]byte{})
+	e := New(strings.NewReader("ab\x1b[D\r"), out, nil)
+	if _, err := e.ReadLine("line\n\x1b[1m>\x1b[0m "); err != nil {
+		t.Fatal(err)
+	}
+	// the cursor is put after the prompt and 'a', leaving out the prompt's colors
+	if !strings.HasSuffix(out.String(), "\r\x1b[1m>\x1b[0m ab\x1b[K\r\x1b[3C\r\n") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+	if !strings.HasPrefix(out.String(), "line\r\n") {
+		t.Errorf("expected the first prompt line once, got %q", out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "history")
+
+	h, err := LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"a", "b", "b", " ", "c", "d"} {
+		if err := h.Add(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if expected := []string{"b", "c", "d"}; !reflect.DeepEqual(h.Lines(), expected) {
+		t.Errorf("expected %v, got %v", expected, h.Lines())
+	}
+	h, err = LoadHistory(path, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := []string{"b", "c", "d"}; !reflect.DeepEqual(h.Lines(), expected) {
+		t.Errorf("expected %v loaded, got %v", expected, h.Lines())
+	}
+
+	// the file is compacted once it has more than twice as many lines as are kept
+	h, err = LoadHistory(path, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "d\n" {
+		t.Errorf("expected the file to be compacted, got %q", data)
+	}
+}
+
+func TestComplete(t *testing.T) {
+	complete := func(head string) (string, []string) {
+		word := head[strings.LastIndex(head, " ")+1:]
+		candidates := []string{}
+		for _, c := range []string{"echo", "exit", "exec", "ls"} {
+			if strings.HasPrefix(c, word) {
+				candidates = append(candidates, c)
+			}
+		}
+		return word, candidates
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		listed   bool
+	}{
+		{"one", "l\t\r", "ls", false},
+		{"common prefix", "e\t\r", "e", true},
+		{"longer prefix", "ex\t\r", "ex", true},
+		{"before the cursor", "a ech b\x1b[D\x1b[D\t\r", "a echo b", false},
+		{"none", "x\t\r", "x", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			e := New(strings.NewReader(tt.input), out, nil)
+			e.Complete = complete
+			line, err := e.ReadLine("> ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, line)
+			}
+			if listed := strings.Contains(out.String(), "  exec"); listed != tt.listed {
+				t.Errorf("expected candidates listed: %v, got %q", tt.listed, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package run
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/stdlib"
+	"github.com/laher/smoosh/token"
+)
+
+// completer completes words in the repl, using what's defined in the interpreter
+type completer struct {
+	env *object.Environment
+}
+
+// complete returns the word which ends head, and its completions. In a string, a word is
+// a path, or a command in `$("...")`. Otherwise it's a builtin, a variable, or a flag of
+// the builtin being called, unless it's a meta-command such as `:env`.
+func (c *completer) complete(head string) (string, []string) {
+	if strings.HasPrefix(head, ":") && !strings.ContainsAny(head, " \t") {
+		names := []string{}
+		for _, name := range metaNames() {
+			names = append(names, ":"+name)
+		}
+		return head, matching(names, head)
+	}
+	if start, ok := openString(head); ok {
+		text := head[start:]
+		word := text[strings.LastIndexAny(text, " \t")+1:]
+		if word == text && isCommandString(head[:start-1]) {
+			return word, stdlib.Executables(word)
+		}
+		return word, c.paths(word)
+	}
+	start := len(head)
+	for start > 0 && isIdentByte(head[start-1]) {
+		start--
+	}
+	word := head[start:]
+	names := []string{}
+	if callee := enclosingCall(head); callee != "" {
+		if _, isVar := c.env.Get(callee); !isVar {
+			for _, flag := range c.builtin(callee) {
+				names = append(names, flag.Name, flag.Long)
+			}
+		}
+	}
+	names = append(names, c.env.Names()...)
+	names = append(names, stdlib.Names()...)
+	for name := range c.env.Builtins {
+		names = append(names, name)
+	}
+	for name := range c.env.Aliases {
+		names = append(names, name)
+	}
+	return word, matching(names, word)
+}
+
+// builtin returns the flags of a builtin, preferring the interpreter's own
+func (c *completer) builtin(name string) []object.Flag {
+	if builtin, ok := c.env.GetBuiltin(name); ok {
+		return builtin.Flags
+	}
+	if builtin, ok := stdlib.GetFn(name); ok {
+		return builtin.Flags
+	}
+	return nil
+}
+
+// paths returns the files and directories which start with word, relative to the working
+// directory. Directories end with a slash.
+func (c *completer) paths(word string) []string {
+	dir, base := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		dir, base = word[:i+1], word[i+1:]
+	}
+	list := dir
+	if list == "" {
+		list = "."
+	}
+	infos, err := c.env.Files().ReadDir(list)
+	if err != nil {
+		return nil
+	}
+	paths := []string{}
+	for _, fi := range infos {
+		name := fi.Name()
+		if !strings.HasPrefix(name, base) || strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		if fi.IsDir() {
+			name += "/"
+		}
+		paths = append(paths, dir+name)
+	}
+	return paths
+}
+
+// matching returns the distinct names which start with prefix, sorted
+func matching(names []string, prefix string) []string {
+	seen := map[string]bool{}
+	matches := []string{}
+	for _, name := range names {
+		if name != "" && strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// openString returns where the content of an unterminated string starts, if text ends in
+// one
+func openString(text string) (int, bool) {
+	_, quote, start := unclosed(text)
+	return start, quote == '"'
+}
+
+// unclosed scans text as the lexer would, returning how deeply brackets are nested at its
+// end, and the quote and start of the content of a string which is still open. As in the
+// lexer, strings have no escapes.
+func unclosed(text string) (depth int, quote byte, start int) {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case quote != 0:
+			if text[i] == quote {
+				quote = 0
+			}
+		case text[i] == '"' || text[i] == '`':
+			quote, start = text[i], i+1
+		case text[i] == '#':
+			// a comment runs to the end of the line
+			for i < len(text) && text[i] != '\n' {
+				i++
+			}
+		case text[i] == '(' || text[i] == '[' || text[i] == '{':
+			depth++
+		case text[i] == ')' || text[i] == ']' || text[i] == '}':
+			depth--
+		}
+	}
+	return depth, quote, start
+}
+
+// isCommandString reports whether a string which follows text is a command run by `$`
+func isCommandString(text string) bool {
+	return strings.HasSuffix(strings.TrimRight(text, " \t"), "$(")
+}
+
+// enclosingCall returns the name of the call whose arguments end text, if any
+func enclosingCall(text string) string {
+	l := lexer.New(text)
+	stack := []string{}
+	prev := token.Token{}
+	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			callee := ""
+			if tok.Type == token.LPAREN && prev.Type == token.IDENT {
+				callee = prev.Literal
+			}
+			stack = append(stack, callee)
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		}
+		prev = tok
+	}
+	if len(stack) == 0 {
+		return ""
+	}
+	return stack[len(stack)-1]
+}
+
+// isIdentByte reports whether b can be part of an identifier, as the lexer reads them
+func isIdentByte(b byte) bool {
+	return 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || b == '_' || b == '$' || b == '.'
+}
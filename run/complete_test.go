@@ -0,0 +1,81 @@
+package run
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/laher/smoosh/vfs"
+)
+
+func TestComplete(t *testing.T) {
+	fs := vfs.NewMem()
+	for _, dir := range []string{"/src", "/src/lib", "/.git"} {
+		if err := fs.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []string{"/src/main.sm", "/src/mod.sm", "/readme"} {
+		f, err := vfs.Create(fs, file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	bin, err := ioutil.TempDir("", "smoosh-complete")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(bin)
+	for _, exe := range []string{"smoosh-one", "smoosh-two"} {
+		if err := ioutil.WriteFile(filepath.Join(bin, exe), nil, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := ioutil.WriteFile(filepath.Join(bin, "smoosh-data"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", bin)
+
+	interp, _ := newTestInterpreter()
+	interp.SetFileSystem(fs)
+	if _, err := interp.Eval(`var longer = 1; var lines = 2`); err != nil {
+		t.Fatal(err)
+	}
+	c := &completer{env: interp.env}
+	tests := []struct {
+		head     string
+		word     string
+		expected []string
+	}{
+		{"ech", "ech", []string{"echo"}},
+		{"echo(li", "li", []string{"lines"}},
+		{"ls(lo", "lo", []string{"long", "longer"}},
+		{"ls(x, lo", "lo", []string{"long", "longer"}},
+		{"ls(x) + lo", "lo", []string{"longer"}},
+		{"ls([lo", "lo", []string{"longer"}},
+		{`cat("src/m`, "src/m", []string{"src/main.sm", "src/mod.sm"}},
+		{`cat("s`, "s", []string{"src/"}},
+		{`cat("`, "", []string{"readme", "src/"}},
+		{`cat(".`, ".", []string{".git/"}},
+		{`cat("src/x") + "ok`, "ok", []string{}},
+		{`$("smoosh-`, "smoosh-", []string{"smoosh-one", "smoosh-two"}},
+		{`$("smoosh-one src/l`, "src/l", []string{"src/lib/"}},
+		{":e", ":e", []string{":env"}},
+	}
+	for _, tt := range tests {
+		word, got := c.complete(tt.head)
+		if word != tt.word {
+			t.Errorf("%s: expected word %q, got %q", tt.head, tt.word, word)
+		}
+		if len(got) == 0 && len(tt.expected) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.head, tt.expected, got)
+		}
+	}
+}
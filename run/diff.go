@@ -0,0 +1,119 @@
+package run
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+type edit struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+// unifiedDiff returns a unified diff from a to b, or "" if they are the same
+func unifiedDiff(aName, bName string, a, b []byte) string {
+	edits := diffLines(splitLines(string(a)), splitLines(string(b)))
+	// aLines[k] and bLines[k] count the lines of a and b before edits[k]
+	aLines := make([]int, len(edits)+1)
+	bLines := make([]int, len(edits)+1)
+	for k, e := range edits {
+		aLines[k+1], bLines[k+1] = aLines[k], bLines[k]
+		if e.kind != '+' {
+			aLines[k+1]++
+		}
+		if e.kind != '-' {
+			bLines[k+1]++
+		}
+	}
+
+	var out strings.Builder
+	for start := 0; start < len(edits); {
+		first := start
+		for first < len(edits) && edits[first].kind == ' ' {
+			first++
+		}
+		if first == len(edits) {
+			break
+		}
+		// changes separated by enough unchanged lines go in separate hunks
+		last := first
+		for k := first; k < len(edits) && k-last <= 2*diffContext; k++ {
+			if edits[k].kind != ' ' {
+				last = k
+			}
+		}
+		lo := first - diffContext
+		if lo < start {
+			lo = start
+		}
+		hi := last + diffContext + 1
+		if hi > len(edits) {
+			hi = len(edits)
+		}
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aLines[lo], aLines[hi]), hunkRange(bLines[lo], bLines[hi]))
+		for _, e := range edits[lo:hi] {
+			out.WriteString(string(e.kind) + e.text + "\n")
+		}
+		start = hi
+	}
+	return out.String()
+}
+
+// hunkRange formats the lines from..to of a hunk, counting from 1
+func hunkRange(from, to int) string {
+	if from == to {
+		// an empty range names the line before it
+		return fmt.Sprintf("%d,0", from)
+	}
+	return fmt.Sprintf("%d,%d", from+1, to-from)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// diffLines finds the edits from a to b, via their longest common subsequence
+func diffLines(a, b []string) []edit {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			switch {
+			case a[i] == b[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	edits := []edit{}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			edits = append(edits, edit{' ', a[i]})
+			i++
+			j++
+		case j < len(b) && (i == len(a) || lcs[i][j+1] > lcs[i+1][j]):
+			edits = append(edits, edit{'+', b[j]})
+			j++
+		default:
+			edits = append(edits, edit{'-', a[i]})
+			i++
+		}
+	}
+	return edits
+}
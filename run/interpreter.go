@@ -0,0 +1,211 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/laher/smoosh/evaluator"
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/parser"
+	"github.com/laher/smoosh/vfs"
+)
+
+// Interpreter is an embeddable smoosh interpreter.
+// Variables, macros and host builtins are kept between evaluations.
+type Interpreter struct {
+	env      *object.Environment
+	macroEnv *object.Environment
+}
+
+// NewInterpreter initializes an Interpreter which reads and writes the given streams
+func NewInterpreter(streams object.Streams) *Interpreter {
+	env := object.NewEnvironment(streams)
+	macroEnv := object.NewEnvironment(streams)
+	macroEnv.WorkDir = env.WorkDir
+	macroEnv.Options = env.Options
+	return &Interpreter{
+		env:      env,
+		macroEnv: macroEnv,
+	}
+}
+
+// Env returns the interpreter's top-level environment
+func (i *Interpreter) Env() *object.Environment {
+	return i.env
+}
+
+// SetPolicy restricts what builtins may do. A nil policy allows everything.
+func (i *Interpreter) SetPolicy(policy *object.Policy) {
+	i.env.Policy = policy
+	i.macroEnv.Policy = policy
+}
+
+// SetFileSystem replaces the filesystem used by builtins, e.g. with an in-memory or overlay filesystem
+func (i *Interpreter) SetFileSystem(fs vfs.FileSystem) {
+	i.env.FS = fs
+	i.macroEnv.FS = fs
+	if _, ok := fs.(*vfs.Mem); ok {
+		// an in-memory filesystem has no relation to the process' working directory
+		i.env.WorkDir.Set("/")
+	}
+}
+
+// Options returns the interpreter's runtime options, e.g. for switching on tracing
+func (i *Interpreter) Options() *object.Options {
+	return i.env.Options
+}
+
+// SetWorkDir changes the interpreter's working directory
+func (i *Interpreter) SetWorkDir(dir string) {
+	i.env.WorkDir.Set(dir)
+}
+
+// Set converts a Go value into a smoosh object and binds it to a variable
+func (i *Interpreter) Set(name string, value interface{}) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return fmt.Errorf("cannot set %s: %v", name, err)
+	}
+	i.env.Set(name, obj)
+	return nil
+}
+
+// Get returns the Go value of a variable, if defined
+func (i *Interpreter) Get(name string) (interface{}, bool) {
+	obj, ok := i.env.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return object.ToGo(obj), true
+}
+
+// RegisterBuiltin registers a builtin for this interpreter only.
+// Host builtins take precedence over the stdlib.
+func (i *Interpreter) RegisterBuiltin(name string, def *object.Builtin) error {
+	if _, ok := i.env.Builtins[name]; ok {
+		return fmt.Errorf("fn '%s' already defined", name)
+	}
+	i.env.Builtins[name] = def
+	return nil
+}
+
+// RegisterFn registers a 'builtin function' for this interpreter only
+func (i *Interpreter) RegisterFn(name string, def object.BuiltinFunction) error {
+	return i.RegisterBuiltin(name, &object.Builtin{
+		Fn: def,
+	})
+}
+
+// Eval evaluates a snippet of smoosh and returns the resulting object.
+// Piped output is collected into a String.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) > 0 {
+		return nil, errors.New(strings.Join(p.Errors(), "\n"))
+	}
+	evaluator.DefineMacros(program, i.macroEnv)
+	expanded, macroErr := evaluator.ExpandMacros(program, i.macroEnv)
+	if macroErr != nil {
+		return nil, evalError(macroErr)
+	}
+	return i.result(evaluator.Eval(expanded, i.env))
+}
+
+// Call calls a function defined in the interpreter, converting its arguments from Go values
+func (i *Interpreter) Call(name string, args ...interface{}) (object.Object, error) {
+	fn, ok := i.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", name)
+	}
+	objs := []object.Object{}
+	for _, arg := range args {
+		obj, err := object.FromGo(arg)
+		if err != nil {
+			return nil, fmt.Errorf("cannot call %s: %v", name, err)
+		}
+		objs = append(objs, obj)
+	}
+	return i.result(evaluator.Apply(fn, name, objs, i.env))
+}
+
+// result converts the result of evaluation, reading the output of pipes
+func (i *Interpreter) result(result object.Object) (object.Object, error) {
+	switch r := result.(type) {
+	case nil:
+		return evaluator.NULL, nil
+	case *object.Error:
+		return nil, evalError(r)
+	case *object.Pipes:
+		out, err := ioutil.ReadAll(r.Main)
+		if err != nil {
+			return nil, err
+		}
+		if r.Wait != nil {
+			if err := r.Wait(); err != nil {
+				return nil, err
+			}
+		}
+		return &object.String{Value: string(out)}, nil
+	}
+	return result, nil
+}
+
+// evalError converts an evaluation error, prefixing its position when known
+func evalError(err *object.Error) error {
+	if err.Pos.IsValid() {
+		return fmt.Errorf("%s: %s", err.Pos, err.Message)
+	}
+	return errors.New(err.Message)
+}
+
+// EvalValue evaluates a snippet and converts the result into a Go value
+func (i *Interpreter) EvalValue(src string) (interface{}, error) {
+	obj, err := i.Eval(src)
+	if err != nil {
+		return nil, err
+	}
+	return object.ToGo(obj), nil
+}
+
+// EvalString evaluates a snippet which should return a string
+func (i *Interpreter) EvalString(src string) (string, error) {
+	obj, err := i.Eval(src)
+	if err != nil {
+		return "", err
+	}
+	s, ok := obj.(*object.String)
+	if !ok {
+		return "", fmt.Errorf("type %s but expected %s", obj.Type(), object.STRING_OBJ)
+	}
+	return s.Value, nil
+}
+
+// EvalInt evaluates a snippet which should return an integer
+func (i *Interpreter) EvalInt(src string) (int64, error) {
+	obj, err := i.Eval(src)
+	if err != nil {
+		return 0, err
+	}
+	n, ok := obj.(*object.Integer)
+	if !ok {
+		return 0, fmt.Errorf("type %s but expected %s", obj.Type(), object.INTEGER_OBJ)
+	}
+	return n.Value, nil
+}
+
+// EvalBool evaluates a snippet which should return a boolean
+func (i *Interpreter) EvalBool(src string) (bool, error) {
+	obj, err := i.Eval(src)
+	if err != nil {
+		return false, err
+	}
+	b, ok := obj.(*object.Boolean)
+	if !ok {
+		return false, fmt.Errorf("type %s but expected %s", obj.Type(), object.BOOLEAN_OBJ)
+	}
+	return b.Value, nil
+}
@@ -0,0 +1,409 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/laher/smoosh/object"
+)
+
+func newTestInterpreter() (*Interpreter, *bytes.Buffer) {
+	out := bytes.NewBuffer([]byte{})
+	return NewInterpreter(object.Streams{
+		Stdin:  bytes.NewBuffer([]byte{}),
+		Stdout: out,
+		Stderr: bytes.NewBuffer([]byte{}),
+	}), out
+}
+
+func TestInterpreterSetAndEval(t *testing.T) {
+	interp, _ := newTestInterpreter()
+	if err := interp.Set("x", 40); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := interp.Set("names", []string{"a", "b"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	n, err := interp.EvalInt("x + 2")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 42 {
+		t.Errorf("got %d, expected 42", n)
+	}
+	s, err := interp.EvalString(`names[1]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if s != "b" {
+		t.Errorf("got %q, expected %q", s, "b")
+	}
+	v, err := interp.EvalValue(`[x, "y", true]`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(v, []interface{}{int64(40), "y", true}) {
+		t.Errorf("unexpected value %#v", v)
+	}
+	// state is kept between evaluations
+	if _, err := interp.Eval(`var y = x * 2`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if y, ok := interp.Get("y"); !ok || y != int64(80) {
+		t.Errorf("unexpected value for y: %v", y)
+	}
+	if _, err := interp.EvalBool(`x`); err == nil {
+		t.Errorf("expected a type error")
+	}
+	if _, err := interp.Eval(`undefinedThing`); err == nil {
+		t.Errorf("expected an error")
+	}
+}
+
+func TestInterpreterRegisterBuiltin(t *testing.T) {
+	interp, out := newTestInterpreter()
+	err := interp.RegisterFn("greet", func(scope object.Scope, args ...object.Object) (object.Operation, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1", len(args))
+		}
+		return func() object.Object {
+			fmt.Fprintf(scope.Env.Streams.Stdout, "hello %s\n", args[0].Inspect())
+			return &object.Null{}
+		}, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := interp.RegisterFn("greet", nil); err == nil {
+		t.Errorf("expected an error registering a duplicate")
+	}
+	if _, err := interp.Eval(`greet("world")`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if out.String() != "hello world\n" {
+		t.Errorf("unexpected output %q", out.String())
+	}
+
+	// host builtins are not visible to other interpreters
+	other, _ := newTestInterpreter()
+	if _, err := other.Eval(`greet("world")`); err == nil {
+		t.Errorf("expected an error from an interpreter without the builtin")
+	}
+}
+
+func TestInterpreterPolicy(t *testing.T) {
+	root, err := ioutil.TempDir("", "smoosh-sandbox")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(root)
+	inside := filepath.Join(root, "x.txt")
+	if err := ioutil.WriteFile(inside, []byte("x\n"), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// a name is allowed by listing its full path
+	ls, err := exec.LookPath("ls")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tests := []struct {
+		policy *object.Policy
+		input  string
+		expErr string
+	}{
+		{&object.Policy{DenyExec: true}, `$("echo 1")`, "1:1: sandbox: exec denied: 'echo'"},
+		{&object.Policy{ExecAllowList: []string{"ls"}}, `$("echo 1")`, "1:1: sandbox: exec denied: 'echo' is not in the allow list"},
+		{&object.Policy{ExecAllowList: []string{"ls"}}, `$("./ls")`, "1:1: sandbox: exec denied: './ls' is not in the allow list"},
+		{&object.Policy{ExecAllowList: []string{"ls"}}, `$("/tmp/evil/ls")`, "1:1: sandbox: exec denied: '/tmp/evil/ls' is not in the allow list"},
+		{&object.Policy{ExecAllowList: []string{ls}}, `$("ls")`, ""},
+		{&object.Policy{ExecAllowList: []string{ls}}, `$("` + ls + `")`, ""},
+		{&object.Policy{DenyNetwork: true}, `http.Get("http://localhost:1")`, "1:1: sandbox: network access denied: 'http://localhost:1'"},
+		{&object.Policy{DenyWrite: true}, `rm("` + inside + `")`, "1:1: sandbox: write denied: '" + inside + "'"},
+		{&object.Policy{Root: root}, `cat("/etc/hosts")`, "1:1: sandbox: path '/etc/hosts' is outside of root '" + root + "'"},
+		{&object.Policy{Root: root}, `cat("` + inside + `")`, ""},
+		{&object.Policy{DenyExit: true}, `exit(1)`, "1:1: sandbox: exit denied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			interp, _ := newTestInterpreter()
+			interp.SetPolicy(tt.policy)
+			_, err := interp.Eval(tt.input)
+			if tt.expErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("expected error %q", tt.expErr)
+			}
+			if err.Error() != tt.expErr {
+				t.Errorf("wrong error message. expected=%q, got=%q", tt.expErr, err.Error())
+			}
+		})
+	}
+	if _, err := os.Stat(inside); err != nil {
+		t.Errorf("file should not have been removed: %v", err)
+	}
+}
+
+func TestInterpreterPolicyRedirect(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "secret")
+	}))
+	defer target.Close()
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1)
+	allowed := httptest.NewServer(http.RedirectHandler(targetURL, http.StatusFound))
+	defer allowed.Close()
+	u, err := url.Parse(allowed.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	interp, _ := newTestInterpreter()
+	interp.SetPolicy(&object.Policy{NetworkAllowList: []string{u.Host}})
+	_, err = interp.Eval(`http.Get("` + allowed.URL + `")`)
+	if err == nil {
+		t.Fatalf("expected the redirect to be denied")
+	}
+	if !strings.Contains(err.Error(), "sandbox: network access denied") {
+		t.Errorf("wrong error message. got=%q", err.Error())
+	}
+}
+
+func TestInterpreterWorkDir(t *testing.T) {
+	root, err := ioutil.TempDir("", "smoosh-workdir")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(root)
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, d, "name.txt"), []byte(d+"\n"), 0644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	procWd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	interpA, outA := newTestInterpreter()
+	interpB, outB := newTestInterpreter()
+	interpA.SetWorkDir(root)
+	interpB.SetWorkDir(root)
+	tests := []struct {
+		interp *Interpreter
+		out    *bytes.Buffer
+		input  string
+		expOut string
+	}{
+		{interpA, outA, `cd("a"); cat("name.txt")`, "a\n"},
+		{interpB, outB, `cd("b"); cat("name.txt")`, "b\n"},
+		{interpA, outA, `cat("name.txt")`, "a\n"},
+		{interpA, outA, `pushd("../b"); cat("name.txt"); popd(); cat("name.txt")`, "b\na\n"},
+		{interpB, outB, `in_dir("../a", fn() { cat("name.txt") }); cat("name.txt")`, "a\nb\n"},
+		{interpB, outB, `$("cat name.txt")`, "b\n"},
+	}
+	for _, test := range tests {
+		test.out.Reset()
+		if _, err := test.interp.Eval(test.input); err != nil {
+			t.Errorf("%s: unexpected error: %v", test.input, err)
+			continue
+		}
+		if got := test.out.String(); got != test.expOut {
+			t.Errorf("%s: got %q, expected %q", test.input, got, test.expOut)
+		}
+	}
+	if wd, _ := os.Getwd(); wd != procWd {
+		t.Errorf("process working directory changed to %s", wd)
+	}
+	if _, err := interpA.Eval(`popd()`); err == nil {
+		t.Errorf("expected error popping an empty stack")
+	}
+}
+
+func TestInterpreterTraceAndDryRun(t *testing.T) {
+	tests := []struct {
+		name      string
+		dryRun    bool
+		input     string
+		expOut    string
+		expStderr string
+	}{
+		{
+			name:      "trace",
+			input:     "var x = \"a\"\nset(\"trace\")\necho(\"{{.x}}\", 1)\n$(\"echo hi there\")",
+			expOut:    "a 1\nhi there\n",
+			expStderr: "+ 3: echo(\"a\", 1)\n+ 4: $(\"echo hi there\")\n+ 4: exec \"echo\" \"hi\" \"there\"\n",
+		},
+		{
+			name:      "unset",
+			input:     "set(\"trace\")\nunset(\"trace\")\necho(\"x\")",
+			expOut:    "x\n",
+			expStderr: "+ 2: unset(\"trace\")\n",
+		},
+		{
+			name:      "dry-run",
+			dryRun:    true,
+			input:     "rm(r, \"/tmp/nope\")\necho(\"x\")|w(\"/tmp/nope.txt\")",
+			expStderr: "dry-run: 1: rm(r, \"/tmp/nope\")\ndry-run: 2: w(\"/tmp/nope.txt\")\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			stderr := bytes.NewBuffer([]byte{})
+			interp := NewInterpreter(object.Streams{
+				Stdin:  bytes.NewBuffer([]byte{}),
+				Stdout: out,
+				Stderr: stderr,
+			})
+			interp.Options().Set(object.OptDryRun, test.dryRun)
+			if _, err := interp.Eval(test.input); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != test.expOut {
+				t.Errorf("got output %q, expected %q", got, test.expOut)
+			}
+			if got := stderr.String(); got != test.expStderr {
+				t.Errorf("got stderr %q, expected %q", got, test.expStderr)
+			}
+		})
+	}
+}
+
+func TestInterpreterStrictModes(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		expOut    string
+		expErr    string
+		expStderr string
+	}{
+		{
+			name:   "unset variable renders",
+			input:  `echo("[{{.nope}}]")`,
+			expOut: "[<no value>]\n",
+		},
+		{
+			name:   "nounset",
+			input:  `set("nounset"); echo("[{{.nope}}]")`,
+			expErr: "1:17: cannot parse arg for interpolation - undefined variable: nope",
+		},
+		{
+			name:   "nounset defined",
+			input:  `set("nounset"); var x = "y"; echo("[{{if .x}}{{.x}}{{end}}]")`,
+			expOut: "[y]\n",
+		},
+		{
+			name:      "piped failure is ignored",
+			input:     `cat("/nonexistent/x")|wc(); echo("after")`,
+			expOut:    "0\nafter\n",
+			expStderr: "Error returned from piped func: [open /nonexistent/x: no such file or directory]\n",
+		},
+		{
+			name:   "pipefail",
+			input:  `set("pipefail"); cat("/nonexistent/x")|wc(); echo("after")`,
+			expOut: "0\n",
+			expErr: "1:40: piped stage 'cat' failed: open /nonexistent/x: no such file or directory",
+		},
+		{
+			name:   "errexit in pipeline",
+			input:  `set("errexit"); cat("/nonexistent/x")|echo("x")|wc(); echo("after")`,
+			expOut: "1\n",
+			expErr: "1:49: piped stage 'cat' failed: open /nonexistent/x: no such file or directory",
+		},
+		{
+			name:   "loop continues",
+			input:  `range (i, j = ["/nonexistent/x", "/dev/null"]) { cat("{{.j}}") }; echo("after")`,
+			expOut: "after\n",
+		},
+		{
+			name:   "errexit in loop",
+			input:  `set("errexit"); range (i, j = ["/nonexistent/x", "/dev/null"]) { cat("{{.j}}") }; echo("after")`,
+			expErr: "1:66: open /nonexistent/x: no such file or directory",
+		},
+		{
+			name:      "scoped",
+			input:     `set("pipefail", fn() { echo("in") }); cat("/nonexistent/x")|wc()`,
+			expOut:    "in\n0\n",
+			expStderr: "Error returned from piped func: [open /nonexistent/x: no such file or directory]\n",
+		},
+		{
+			name:      "scoped failure",
+			input:     `set("pipefail"); unset("pipefail", fn() { cat("/nonexistent/x")|wc() }); cat("/nonexistent/x")|wc()`,
+			expOut:    "0\n0\n",
+			expErr:    "1:96: piped stage 'cat' failed: open /nonexistent/x: no such file or directory",
+			expStderr: "Error returned from piped func: [open /nonexistent/x: no such file or directory]\n",
+		},
+		{
+			name:   "unknown option",
+			input:  `set("nope")`,
+			expErr: "1:1: unknown option 'nope'",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			stderr := bytes.NewBuffer([]byte{})
+			interp := NewInterpreter(object.Streams{
+				Stdin:  bytes.NewBuffer([]byte{}),
+				Stdout: out,
+				Stderr: stderr,
+			})
+			_, err := interp.Eval(test.input)
+			if test.expErr != "" {
+				if err == nil || err.Error() != test.expErr {
+					t.Errorf("got error %v, expected %q", err, test.expErr)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if got := out.String(); got != test.expOut {
+				t.Errorf("got output %q, expected %q", got, test.expOut)
+			}
+			if got := stderr.String(); got != test.expStderr {
+				t.Errorf("got stderr %q, expected %q", got, test.expStderr)
+			}
+		})
+	}
+}
+
+func TestInterpreterCall(t *testing.T) {
+	interp, _ := newTestInterpreter()
+	if _, err := interp.Eval(`var add = fn(a, b) { a + b }; var name = fn(h) { h["name"] }`); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	res, err := interp.Call("add", 1, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Inspect() != "3" {
+		t.Errorf("expected 3, got %s", res.Inspect())
+	}
+	res, err = interp.Call("name", map[string]interface{}{"name": "x"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.Inspect() != "x" {
+		t.Errorf("expected x, got %s", res.Inspect())
+	}
+	if _, err := interp.Call("add", 1); err == nil {
+		t.Errorf("expected error for too few arguments")
+	}
+	if _, err := interp.Call("missing"); err == nil {
+		t.Errorf("expected error for an undefined function")
+	}
+}
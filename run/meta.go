@@ -0,0 +1,174 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/parser"
+	"github.com/laher/smoosh/stdlib"
+)
+
+// repl is the state of a repl session which meta-commands can look at and change
+type repl struct {
+	r         *Runner
+	streams   object.Streams
+	interp    *Interpreter
+	completer *completer
+}
+
+// metaCommand is a repl command which starts with a colon, e.g. `:env`. Its argument is
+// the rest of the line.
+type metaCommand struct {
+	usage string
+	help  string
+	run   func(rp *repl, arg string) error
+}
+
+var metaCommands map[string]*metaCommand
+
+func init() {
+	// assigned in init, because :help refers to metaCommands
+	metaCommands = map[string]*metaCommand{
+		"env":    {":env", "list the variables in the environment, with their types", (*repl).env},
+		"help":   {":help [BUILTIN]", "describe a builtin, or list the meta-commands", (*repl).help},
+		"ast":    {":ast EXPR", "print the AST of EXPR as JSON", (*repl).ast},
+		"tokens": {":tokens EXPR", "print the tokens of EXPR", (*repl).tokens},
+		"time":   {":time EXPR", "run EXPR, then print how long it took", (*repl).time},
+		"load":   {":load FILE", "run FILE in this session", (*repl).load},
+		"reset":  {":reset", "start again with a new interpreter, running the rc file", (*repl).reset},
+		"macros": {":macros", "list the macros which are defined", (*repl).macros},
+	}
+}
+
+// isMetaCommand reports whether a line is a meta-command rather than smoosh
+func isMetaCommand(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), ":")
+}
+
+// meta runs a meta-command
+func (rp *repl) meta(line string) error {
+	line = strings.TrimPrefix(strings.TrimSpace(line), ":")
+	name, arg := line, ""
+	if i := strings.IndexAny(line, " \t"); i >= 0 {
+		name, arg = line[:i], strings.TrimSpace(line[i+1:])
+	}
+	cmd, ok := metaCommands[name]
+	if !ok {
+		return fmt.Errorf("unknown command :%s, see :help", name)
+	}
+	return cmd.run(rp, arg)
+}
+
+func metaNames() []string {
+	names := []string{}
+	for name := range metaCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (rp *repl) env(arg string) error {
+	env := rp.interp.env
+	for _, name := range env.Names() {
+		obj, _ := env.Get(name)
+		fmt.Fprintf(rp.streams.Stdout, "%s: %s\n", name, obj.Type())
+	}
+	return nil
+}
+
+func (rp *repl) help(arg string) error {
+	if arg == "" {
+		for _, name := range metaNames() {
+			cmd := metaCommands[name]
+			fmt.Fprintf(rp.streams.Stdout, "%-16s %s\n", cmd.usage, cmd.help)
+		}
+		return nil
+	}
+	// aliases are looked up first, as they are when called
+	var builtin *object.Builtin
+	alias, ok := rp.interp.env.Aliases[arg]
+	if ok {
+		builtin = alias.Builtin
+	} else {
+		builtin, ok = rp.interp.env.GetBuiltin(arg)
+	}
+	if !ok {
+		builtin, ok = stdlib.GetFn(arg)
+	}
+	if !ok {
+		return fmt.Errorf("no builtin named %s", arg)
+	}
+	_, err := fmt.Fprintln(rp.streams.Stdout, stdlib.Help(builtin))
+	return err
+}
+
+func (rp *repl) ast(arg string) error {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		return parseErrors(arg, errs)
+	}
+	return printAST(rp.streams.Stdout, program)
+}
+
+func (rp *repl) tokens(arg string) error {
+	return printTokens(rp.streams.Stdout, lexer.New(arg))
+}
+
+func (rp *repl) time(arg string) error {
+	start := time.Now()
+	err := rp.r.eval(arg, rp.streams.Stdout, rp.interp)
+	fmt.Fprintf(rp.streams.Stderr, "time: %s\n", time.Since(start))
+	return err
+}
+
+func (rp *repl) load(arg string) error {
+	if arg == "" {
+		return errors.New("usage: :load FILE")
+	}
+	// the file is read as scripts read files, so the same policy applies
+	if err := rp.interp.env.CheckRead(arg); err != nil {
+		return err
+	}
+	f, err := rp.interp.env.Files().Open(arg)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	data, err := ioutil.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	return rp.r.runData(string(data), arg, rp.streams.Stdout, rp.interp)
+}
+
+func (rp *repl) reset(arg string) error {
+	rp.interp = rp.r.newInterpreter(rp.streams)
+	rp.completer.env = rp.interp.env
+	rp.r.loadRc(rp.streams.Stdout, rp.streams.Stderr, rp.interp)
+	return nil
+}
+
+func (rp *repl) macros(arg string) error {
+	env := rp.interp.macroEnv
+	for _, name := range env.Names() {
+		obj, _ := env.Get(name)
+		macro, ok := obj.(*object.Macro)
+		if !ok {
+			continue
+		}
+		params := []string{}
+		for _, p := range macro.Parameters {
+			params = append(params, p.String())
+		}
+		fmt.Fprintf(rp.streams.Stdout, "%s(%s)\n", name, strings.Join(params, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package run
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/laher/smoosh/object"
+)
+
+// promptFn is the function which users can define, e.g. in ~/.smooshrc, to build the
+// repl's prompt. It's called with a hash describing the session, if it takes an argument.
+const promptFn = "prompt"
+
+// session is what the repl knows about itself, for building the prompt
+type session struct {
+	username string
+	// status is the exit status of the last entry: 0 if it succeeded, the command's exit
+	// code if a command failed, or 1 otherwise
+	status int
+}
+
+func newSession() *session {
+	s := &session{username: "?"}
+	if u, err := user.Current(); err == nil {
+		s.username = u.Username
+	}
+	return s
+}
+
+// setStatus records how the last entry went
+func (s *session) setStatus(err error) {
+	// as for a command's *exec.ExitError, or a runtime error from a failed command
+	var exitErr interface{ ExitCode() int }
+	switch {
+	case err == nil:
+		s.status = 0
+	case errors.As(err, &exitErr):
+		s.status = exitErr.ExitCode()
+	default:
+		s.status = 1
+	}
+}
+
+// info describes the session to the prompt function
+func (s *session) info(env *object.Environment) map[string]interface{} {
+	cwd := env.WorkDir.Get()
+	return map[string]interface{}{
+		"user":   s.username,
+		"cwd":    cwd,
+		"dir":    path.Base(cwd),
+		"status": s.status,
+		"branch": gitBranch(cwd),
+		"time":   time.Now().Format("15:04:05"),
+		// smoosh doesn't run background jobs yet
+		"jobs": 0,
+	}
+}
+
+// prompt returns the prompt for a new entry, from the user's prompt function if they have
+// defined one
+func (s *session) prompt(interp *Interpreter) (string, error) {
+	fallback := fmt.Sprintf("[%s]/[%s]> ", s.username, path.Base(interp.env.WorkDir.Get()))
+	fn, ok := interp.env.Get(promptFn)
+	if !ok {
+		return fallback, nil
+	}
+	args := []interface{}{}
+	if f, ok := fn.(*object.Function); !ok || len(f.Parameters) > 0 {
+		args = append(args, s.info(interp.env))
+	}
+	res, err := interp.Call(promptFn, args...)
+	if err != nil {
+		return fallback, fmt.Errorf("%s(): %v", promptFn, err)
+	}
+	if str, ok := res.(*object.String); ok {
+		return str.Value, nil
+	}
+	return fallback, fmt.Errorf("%s(): expected a STRING, got %s", promptFn, res.Type())
+}
+
+// gitBranch returns the branch checked out in the git repository containing dir, or its
+// abbreviated commit when detached. It reads .git/HEAD rather than running git.
+func gitBranch(dir string) string {
+	for {
+		gitDir := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(gitDir); err == nil {
+			if !fi.IsDir() {
+				// a worktree or submodule: .git says where the git directory is
+				data, err := ioutil.ReadFile(gitDir)
+				if err != nil {
+					return ""
+				}
+				gitDir = strings.TrimSpace(strings.TrimPrefix(string(data), "gitdir:"))
+				if !filepath.IsAbs(gitDir) {
+					gitDir = filepath.Join(dir, gitDir)
+				}
+			}
+			data, err := ioutil.ReadFile(filepath.Join(gitDir, "HEAD"))
+			if err != nil {
+				return ""
+			}
+			head := strings.TrimSpace(string(data))
+			if ref := strings.TrimPrefix(head, "ref: "); ref != head {
+				return strings.TrimPrefix(ref, "refs/heads/")
+			}
+			if len(head) > 7 {
+				head = head[:7]
+			}
+			return head
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
@@ -7,12 +7,15 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/user"
-	"path"
+	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/readline"
 )
 
+// historySize is how many lines of history the repl keeps
+const historySize = 1000
+
 func isPipedInput(in io.Reader) bool {
 	if stdin, ok := in.(*os.File); ok {
 		stat, _ := stdin.Stat()
@@ -23,69 +26,141 @@ func isPipedInput(in io.Reader) bool {
 	return false
 }
 
-// Start starts a line-by-line processor
+// Start starts a line-by-line processor. Input is read until it's a complete program,
+// which is then run. Errors are printed, and the session carries on.
 func (r *Runner) Start(in io.Reader, out io.Writer, stderr io.Writer) {
 	streams := object.Streams{
 		Stdin:  in,
 		Stdout: out,
 		Stderr: stderr,
 	}
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment(streams)
-	macroEnv := object.NewEnvironment(streams)
-	user, err := user.Current()
-	if err != nil {
-		panic(err)
-	}
+	interp := r.newInterpreter(streams)
 	if isPipedInput(in) {
 		all, err := ioutil.ReadAll(in)
-		if err != nil {
-			panic(err)
+		if err == nil {
+			err = r.runData(string(all), "", out, interp)
 		}
-		err = r.runData(string(all), out, env, macroEnv)
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(stderr, err)
 		}
 		return
 	}
+	r.loadRc(out, stderr, interp)
+	session := newSession()
+	rp := &repl{r: r, streams: streams, interp: interp, completer: &completer{env: interp.env}}
+	editor := readline.New(in, out, nil)
+	editor.Complete = rp.completer.complete
+	readLine := editor.ReadLine
+	if editor.IsTerminal() {
+		editor.History = r.loadHistory(stderr)
+	} else {
+		scanner := bufio.NewScanner(in)
+		readLine = func(prompt string) (string, error) {
+			fmt.Fprint(out, prompt)
+			if !scanner.Scan() {
+				if err := scanner.Err(); err != nil {
+					return "", err
+				}
+				return "", io.EOF
+			}
+			return scanner.Text(), nil
+		}
+	}
+	src := ""
 	for {
-		pwd, err := os.Getwd()
-		if err != nil {
-			panic(err)
+		prompt := continuationPrompt
+		if src == "" {
+			p, err := session.prompt(rp.interp)
+			if err != nil {
+				fmt.Fprintln(stderr, err)
+			}
+			prompt = p
+		}
+		line, err := readLine(prompt)
+		if err == readline.ErrInterrupted {
+			src = ""
+			continue
 		}
-		prompt := fmt.Sprintf("[%s]/[%s]> ", user.Username, path.Base(pwd))
-		fmt.Printf(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
+		if err != nil {
 			return
 		}
-
-		line := scanner.Text()
-		err = r.runData(line, out, env, macroEnv)
+		if err := editor.History.Add(line); err != nil {
+			fmt.Fprintln(stderr, err)
+		}
+		if src == "" && isMetaCommand(line) {
+			err = rp.meta(line)
+		} else {
+			src += line + "\n"
+			if incomplete(src) {
+				continue
+			}
+			err = r.eval(src, out, rp.interp)
+		}
 		if err != nil {
-			panic(err)
+			fmt.Fprintln(stderr, err)
 		}
+		session.setStatus(err)
+		src = ""
 	}
 }
 
-const MONKEY_FACE = `            __,__
-   .--.  .-"     "-.  .--.
-  / .. \/  .-. .-.  \/ .. \
- | |  '|  /   Y   \  |'  | |
- | \   \  \ 0 | 0 /  /   / |
-  \ '- ,\.-"""""""-./, -' /
-   ''-' /_   ^ ^   _\ '-''
-       |  \._   _./  |
-       \   \ '~' /   /
-        '._ '-=-' _.'
-           '-----'
-`
+// continuationPrompt is shown while more input is needed to complete a program
+const continuationPrompt = "... "
+
+// eval runs a program entered in the repl, turning a panic into an error so that the
+// session carries on
+func (r *Runner) eval(src string, out io.Writer, interp *Interpreter) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return r.runData(src, "", out, interp)
+}
+
+// incomplete reports whether src has brackets or a string which are still open, so that
+// more input is needed
+func incomplete(src string) bool {
+	depth, quote, _ := unclosed(src)
+	return depth > 0 || quote != 0
+}
+
+// loadRc runs the user's rc file, which needn't exist, to set up the repl
+func (r *Runner) loadRc(out, stderr io.Writer, interp *Interpreter) {
+	file := r.RcFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		file = filepath.Join(home, ".smooshrc")
+	}
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return
+	}
+	if err == nil {
+		err = r.runData(string(data), file, out, interp)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+	}
+}
 
-func printParserErrors(out io.Writer, errors []string) {
-	io.WriteString(out, MONKEY_FACE)
-	io.WriteString(out, "Woops! We ran into some monkey business here!\n")
-	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+// loadHistory loads the repl's history file. Without one, history is kept for the session.
+func (r *Runner) loadHistory(stderr io.Writer) *readline.History {
+	file := r.HistoryFile
+	if file == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return readline.NewHistory(historySize)
+		}
+		file = filepath.Join(home, ".smoosh_history")
+	}
+	history, err := readline.LoadHistory(file, historySize)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return readline.NewHistory(historySize)
 	}
+	return history
 }
@@ -0,0 +1,226 @@
+package run
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/laher/smoosh/object"
+)
+
+var promptPattern = regexp.MustCompile(`\[[^]]*\]/\[[^]]*\]> |\.\.\. `)
+
+func TestStart(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		out    string
+		errors []string
+	}{
+		{"lines", "var x = 1\necho(x)\n", "1\n", nil},
+		{"function over lines", "var f = fn(x) {\n  x * 2\n}\nf(3)\n", "6\n", nil},
+		{"string over lines", "echo(\"a\nb\")\n", "a\nb\n", nil},
+		{"errors carry on", "var x = 1\necho(y)\nvar = \necho(x)\n", "1\n", []string{"identifier not found: y", "expected next token to be IDENT"}},
+		{"closing too much", "echo(1))\necho(2)\n", "2\n", []string{"no prefix parse function for )"}},
+		{"macros are kept", "var unless = macro(c, a) { quote(if (!(unquote(c))) { unquote(a) }) }\nunless(false, echo(5))\n", "5\n", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			stderr := bytes.NewBuffer([]byte{})
+			r := NewRunner()
+			r.RcFile = "testdata/none"
+			r.Start(strings.NewReader(tt.input), out, stderr)
+			// leave out the prompts
+			got := promptPattern.ReplaceAllString(out.String(), "")
+			if got != tt.out {
+				t.Errorf("expected output %q, got %q", tt.out, got)
+			}
+			for _, e := range tt.errors {
+				if !strings.Contains(stderr.String(), e) {
+					t.Errorf("expected error %q, got %q", e, stderr.String())
+				}
+			}
+			if len(tt.errors) == 0 && stderr.Len() > 0 {
+				t.Errorf("unexpected errors %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestStartRc(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-rc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	rc := filepath.Join(dir, "rc")
+	src := `var double = fn(x) { x * 2 }
+var mark = fn(status) { if (status == 0) { "$ " } else { "! " } }
+var prompt = fn(p) { color("green", p["dir"]) + " " + mark(p["status"]) }`
+	if err := ioutil.WriteFile(rc, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.NewBuffer([]byte{})
+	stderr := bytes.NewBuffer([]byte{})
+	r := NewRunner()
+	r.RcFile = rc
+	r.Start(strings.NewReader("double(2)\necho(y)\n"), out, stderr)
+	green := "\x1b[32m" + filepath.Base(wd) + "\x1b[0m "
+	if expected := green + "$ 4\n" + green + "$ " + green + "! "; out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestGitBranch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-git")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"repo/.git/HEAD":     "ref: refs/heads/feature/x\n",
+		"detached/.git/HEAD": "0123456789abcdef\n",
+		"worktree/.git":      "gitdir: ../repo/.git\n",
+	}
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "repo", "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]string{
+		"repo":     "feature/x",
+		"repo/sub": "feature/x",
+		"detached": "0123456",
+		"worktree": "feature/x",
+		"":         "",
+	}
+	for sub, expected := range tests {
+		if got := gitBranch(filepath.Join(dir, sub)); got != expected {
+			t.Errorf("%s: expected %q, got %q", sub, expected, got)
+		}
+	}
+}
+
+func TestStartMeta(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "script.sm")
+	if err := ioutil.WriteFile(script, []byte("var loaded = 7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		input  string
+		out    []string
+		stderr []string
+	}{
+		{"env", "var x = 1\nvar s = \"a\"\n:env\n", []string{"s: STRING\nx: INTEGER\n"}, nil},
+		{"help", ":help\n:help basename\n", []string{":env ", ":reset ", "Usage: basename NAME"}, nil},
+		{"help unknown", ":help nope\n", nil, []string{"no builtin named nope"}},
+		{"help alias", "alias(\"b\", \"basename()\")\n:help b\n", []string{"alias for basename()", "Usage: basename NAME"}, nil},
+		{"ast", ":ast 1 + 2\n", []string{`"Operator": "+"`}, nil},
+		{"tokens", ":tokens x\n", []string{`Type:"IDENT", Literal:"x"`}, nil},
+		{"time", ":time echo(3)\n", []string{"3\n"}, []string{"time: "}},
+		{"load", ":load " + script + "\necho(loaded)\n", []string{"7\n"}, nil},
+		{"reset", "var x = 1\n:reset\necho(x)\n", nil, []string{"identifier not found: x"}},
+		{"macros", "var unless = macro(c, a) { quote(if (!(unquote(c))) { unquote(a) }) }\n:macros\n", []string{"unless(c, a)\n"}, nil},
+		{"unknown", ":nope\n", nil, []string{"unknown command :nope"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			stderr := bytes.NewBuffer([]byte{})
+			r := NewRunner()
+			r.RcFile = "testdata/none"
+			r.Start(strings.NewReader(tt.input), out, stderr)
+			got := promptPattern.ReplaceAllString(out.String(), "")
+			for _, expected := range tt.out {
+				if !strings.Contains(got, expected) {
+					t.Errorf("expected output to contain %q, got %q", expected, got)
+				}
+			}
+			for _, expected := range tt.stderr {
+				if !strings.Contains(stderr.String(), expected) {
+					t.Errorf("expected error %q, got %q", expected, stderr.String())
+				}
+			}
+			if len(tt.stderr) == 0 && stderr.Len() > 0 {
+				t.Errorf("unexpected errors %q", stderr.String())
+			}
+		})
+	}
+}
+
+func TestStartMetaLoadPolicy(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-meta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "script.sm")
+	if err := ioutil.WriteFile(script, []byte("var loaded = 7\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	root, err := ioutil.TempDir("", "smoosh-root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	out := bytes.NewBuffer([]byte{})
+	stderr := bytes.NewBuffer([]byte{})
+	r := NewRunner()
+	r.RcFile = "testdata/none"
+	r.Policy = &object.Policy{Root: root}
+	r.Start(strings.NewReader(":load "+script+"\n"), out, stderr)
+	if !strings.Contains(stderr.String(), "is outside of root") {
+		t.Errorf("expected :load to be denied, got %q", stderr.String())
+	}
+}
+
+func TestSessionStatus(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-status")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	script := filepath.Join(dir, "fail.sh")
+	if err := ioutil.WriteFile(script, []byte("exit 3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input  string
+		status int
+	}{
+		{`echo(1)`, 0},
+		{`echo(y)`, 1},
+		{`$("sh", "` + script + `")`, 3},
+		{`var f = fn() { $("sh", "` + script + `") }; f()`, 3},
+	}
+	for _, tt := range tests {
+		interp, out := newTestInterpreter()
+		s := newSession()
+		s.setStatus(NewRunner().runData(tt.input, "", out, interp))
+		if s.status != tt.status {
+			t.Errorf("%s: expected status %d, got %d", tt.input, tt.status, s.status)
+		}
+	}
+}
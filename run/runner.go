@@ -1,24 +1,31 @@
 package run
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
+	"strings"
 
+	"github.com/laher/smoosh/ast"
+	"github.com/laher/smoosh/checker"
 	"github.com/laher/smoosh/evaluator"
+	"github.com/laher/smoosh/format"
 	"github.com/laher/smoosh/lexer"
+	"github.com/laher/smoosh/lint"
 	"github.com/laher/smoosh/object"
 	"github.com/laher/smoosh/parser"
 	_ "github.com/laher/smoosh/stdlib" //stdlib should always be loaded along with the evaluator ... how to do packages ... ?
 	"github.com/laher/smoosh/token"
+	"github.com/laher/smoosh/vfs"
 )
 
 // NewRunner initializes a Runner
 func NewRunner() *Runner {
-	return &Runner{true, true, false}
+	return &Runner{Parse: true, Evaluate: true}
 }
 
 // Runner can run a repl or a program
@@ -26,6 +33,38 @@ type Runner struct {
 	Parse    bool
 	Evaluate bool
 	Format   bool
+	// FormatWrite, FormatList and FormatDiff change what FormatFile does with a file
+	// which is not formatted: rewrite it, print its name, or print a diff
+	FormatWrite bool
+	FormatList  bool
+	FormatDiff  bool
+	// Check type-checks the program instead of evaluating it
+	Check bool
+	// Lint reports likely mistakes in the program instead of evaluating it
+	Lint bool
+	// Policy restricts what builtins may do (nil means unrestricted)
+	Policy *object.Policy
+	// FS is the filesystem used by builtins (nil means the OS filesystem)
+	FS vfs.FileSystem
+	// Trace prints each builtin call to stderr before it runs
+	Trace bool
+	// DryRun logs mutating builtins instead of running them
+	DryRun bool
+	// RcFile is run when the repl starts ("" means ~/.smooshrc)
+	RcFile string
+	// HistoryFile keeps the repl's history between sessions ("" means ~/.smoosh_history)
+	HistoryFile string
+}
+
+func (r *Runner) newInterpreter(streams object.Streams) *Interpreter {
+	interp := NewInterpreter(streams)
+	interp.SetPolicy(r.Policy)
+	if r.FS != nil {
+		interp.SetFileSystem(r.FS)
+	}
+	interp.Options().Set(object.OptTrace, r.Trace)
+	interp.Options().Set(object.OptDryRun, r.DryRun)
+	return interp
 }
 
 // RunFile runs a file as a single program
@@ -35,11 +74,15 @@ func (r *Runner) RunFile(filename string, out io.Writer, stderr io.Writer) error
 		return err
 	}
 	defer f.Close()
-	return r.Run(f, out, stderr)
+	return r.run(f, filename, out, stderr)
 }
 
 // Run runs an io.Reader as a single program
 func (r *Runner) Run(rdr io.Reader, out io.Writer, stderr io.Writer) error {
+	return r.run(rdr, "", out, stderr)
+}
+
+func (r *Runner) run(rdr io.Reader, filename string, out io.Writer, stderr io.Writer) error {
 	streams := object.Streams{
 		Stdin:  rdr,
 		Stdout: out,
@@ -49,24 +92,77 @@ func (r *Runner) Run(rdr io.Reader, out io.Writer, stderr io.Writer) error {
 	if err != nil {
 		return fmt.Errorf("could not read: %v", err)
 	}
-	env := object.NewEnvironment(streams)
-	macroEnv := object.NewEnvironment(streams)
-	return r.runData(string(data), out, env, macroEnv)
+	return r.runData(string(data), filename, out, r.newInterpreter(streams))
+}
+
+// FormatFile formats a file, printing the result unless one of the FormatWrite, FormatList
+// or FormatDiff modes is set
+func (r *Runner) FormatFile(filename string, out io.Writer) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	p := parser.New(lexer.NewFile(filename, string(src)))
+	program := p.ParseProgram()
+	if errs := p.ErrorList(); len(errs) > 0 {
+		return parseErrors(string(src), errs)
+	}
+	var buf bytes.Buffer
+	if err := format.Fprint(&buf, program); err != nil {
+		return err
+	}
+	res := buf.Bytes()
+	if !r.FormatWrite && !r.FormatList && !r.FormatDiff {
+		_, err := out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if r.FormatList {
+		if _, err := fmt.Fprintln(out, filename); err != nil {
+			return err
+		}
+	}
+	if r.FormatWrite {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if r.FormatDiff {
+		if _, err := io.WriteString(out, unifiedDiff(filename+".orig", filename, src, res)); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *Runner) runData(data string, out io.Writer, env, macroEnv *object.Environment) error {
-	l := lexer.New(data)
+func (r *Runner) runData(data, filename string, out io.Writer, interp *Interpreter) error {
+	l := lexer.NewFile(filename, data)
 	if r.Parse {
 		p := parser.New(l)
 		program := p.ParseProgram()
-		if len(p.Errors()) > 0 {
-			return errors.New(p.Errors()[0])
+		if errs := p.ErrorList(); len(errs) > 0 {
+			return parseErrors(data, errs)
+		}
+		if r.Check {
+			return checkProgram(program, data, interp)
+		}
+		if r.Lint {
+			return lintProgram(program, data)
 		}
 
 		if r.Evaluate {
-			evaluator.DefineMacros(program, macroEnv)
-			expanded := evaluator.ExpandMacros(program, macroEnv)
-			result := evaluator.Eval(expanded, env)
+			evaluator.DefineMacros(program, interp.macroEnv)
+			expanded, macroErr := evaluator.ExpandMacros(program, interp.macroEnv)
+			if macroErr != nil {
+				return runtimeError(data, macroErr)
+			}
+			result := evaluator.Eval(expanded, interp.env)
 			if result == nil {
 				return nil
 			}
@@ -75,7 +171,7 @@ func (r *Runner) runData(data string, out io.Writer, env, macroEnv *object.Envir
 			case *object.Null:
 				return nil
 			case *object.Error:
-				return fmt.Errorf("%s", r.Message)
+				return runtimeError(data, r)
 
 			case *object.Pipes:
 				pipes := r
@@ -94,21 +190,28 @@ func (r *Runner) runData(data string, out io.Writer, env, macroEnv *object.Envir
 			_, err := io.WriteString(out, result.Inspect()+"\n")
 			return err
 		} else {
-			// TODO detect type-checking errors
 			// TODO detact macro errors
 		}
 		if r.Format {
-			_, err := io.WriteString(out, program.String())
-			return err
-		}
-		b, err := json.MarshalIndent(program, "", "  ")
-		if err != nil {
-			return err
+			return format.Fprint(out, program)
 		}
-		_, err = fmt.Fprintf(out, "%s\n", string(b))
-		return err
+		return printAST(out, program)
+	}
+	return printTokens(out, l)
+}
 
+// printAST prints a program as JSON
+func printAST(out io.Writer, program *ast.Program) error {
+	b, err := json.MarshalIndent(program, "", "  ")
+	if err != nil {
+		return err
 	}
+	_, err = fmt.Fprintf(out, "%s\n", string(b))
+	return err
+}
+
+// printTokens prints the tokens which the lexer reads
+func printTokens(out io.Writer, l *lexer.Lexer) error {
 	for tok := l.NextToken(); tok.Type != token.EOF; tok = l.NextToken() {
 		_, err := fmt.Fprintf(out, "%#v\n", tok)
 		if err != nil {
@@ -117,3 +220,90 @@ func (r *Runner) runData(data string, out io.Writer, env, macroEnv *object.Envir
 	}
 	return nil
 }
+
+// parseErrors reports all syntax errors as a single error
+func parseErrors(src string, errs []*parser.Error) error {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, sourceError(src, err.Pos, err.Msg))
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// checkProgram reports all problems found by the checker as a single error
+func checkProgram(program *ast.Program, src string, interp *Interpreter) error {
+	errs := checker.Check(program, interp.env)
+	if len(errs) == 0 {
+		return nil
+	}
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, sourceError(src, err.Pos, err.Msg))
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// lintProgram reports all problems found by the linter as a single error
+func lintProgram(program *ast.Program, src string) error {
+	problems := lint.Lint(program)
+	if len(problems) == 0 {
+		return nil
+	}
+	msgs := []string{}
+	for _, p := range problems {
+		msgs = append(msgs, sourceError(src, p.Pos, fmt.Sprintf("%s (%s)", p.Msg, p.Rule)))
+	}
+	return errors.New(strings.Join(msgs, "\n"))
+}
+
+// runtimeError formats an evaluation error with its source line and call stack
+func runtimeError(src string, err *object.Error) error {
+	msg := sourceError(src, err.Pos, err.Message)
+	if len(err.Stack) > 0 {
+		msg += "\ncall stack:"
+		for _, frame := range err.Stack {
+			msg += "\n  " + frame.String()
+		}
+	}
+	if err.ExitCode != 0 {
+		return &exitError{msg: msg, code: err.ExitCode}
+	}
+	return errors.New(msg)
+}
+
+// exitError is a runtime error from a command which failed, keeping its exit status
+type exitError struct {
+	msg  string
+	code int
+}
+
+func (e *exitError) Error() string { return e.msg }
+
+// ExitCode returns the exit status of the command
+func (e *exitError) ExitCode() int { return e.code }
+
+// sourceError formats a message with its position, followed by the offending line of source and a caret under the column
+func sourceError(src string, pos token.Position, msg string) string {
+	if !pos.IsValid() {
+		return msg
+	}
+	out := fmt.Sprintf("%s: %s", pos, msg)
+	lines := strings.Split(src, "\n")
+	if pos.Line > len(lines) {
+		return out
+	}
+	line := strings.TrimRight(lines[pos.Line-1], "\r")
+	caret := []rune{}
+	for i, ru := range []rune(line) {
+		if i >= pos.Column-1 {
+			break
+		}
+		if ru == '\t' {
+			// keep tabs so that the caret lines up
+			caret = append(caret, ru)
+		} else {
+			caret = append(caret, ' ')
+		}
+	}
+	return out + "\n" + line + "\n" + string(caret) + "^"
+}
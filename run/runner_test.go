@@ -0,0 +1,98 @@
+package run
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "smoosh-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	messy := filepath.Join(dir, "messy.smoosh")
+	tidy := filepath.Join(dir, "tidy.smoosh")
+	if err := ioutil.WriteFile(messy, []byte("echo(1)\nvar x=2 # two\necho(x)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(tidy, []byte("echo(1)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	formatted := "echo(1)\nvar x = 2 # two\necho(x)\n"
+	tests := []struct {
+		name     string
+		runner   Runner
+		expected string
+	}{
+		{"print", Runner{}, formatted + "echo(1)\n"},
+		{"list", Runner{FormatList: true}, messy + "\n"},
+		{"diff", Runner{FormatDiff: true}, "--- " + messy + ".orig\n+++ " + messy + "\n@@ -1,3 +1,3 @@\n echo(1)\n-var x=2 # two\n+var x = 2 # two\n echo(x)\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := bytes.NewBuffer([]byte{})
+			for _, f := range []string{messy, tidy} {
+				if err := tt.runner.FormatFile(f, out); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if out.String() != tt.expected {
+				t.Errorf("expected:\n%s\ngot:\n%s", tt.expected, out.String())
+			}
+		})
+	}
+
+	r := Runner{FormatWrite: true}
+	if err := r.FormatFile(messy, ioutil.Discard); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadFile(messy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != formatted {
+		t.Errorf("expected file to be rewritten as:\n%s\ngot:\n%s", formatted, got)
+	}
+
+	bad := filepath.Join(dir, "bad.smoosh")
+	if err := ioutil.WriteFile(bad, []byte("echo(1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.FormatFile(bad, ioutil.Discard); err == nil {
+		t.Error("expected a syntax error")
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n"
+	b := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\n12\n13\n"
+	expected := `--- a
++++ b
+@@ -1,6 +1,6 @@
+ 1
+ 2
+-3
++three
+ 4
+ 5
+ 6
+@@ -8,5 +8,5 @@
+ 8
+ 9
+ 10
+-11
+ 12
++13
+`
+	if got := unifiedDiff("a", "b", []byte(a), []byte(b)); got != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, got)
+	}
+	if got := unifiedDiff("a", "b", []byte(a), []byte(a)); got != "" {
+		t.Errorf("expected no diff, got:\n%s", got)
+	}
+}
@@ -28,7 +28,7 @@ func basename(scope object.Scope, args ...object.Object) (object.Operation, erro
 		case *object.Flag:
 			return nil, fmt.Errorf("flag %s not supported", arg.Name)
 		case *object.String:
-			d, err := Interpolate(scope.Env.Export(), arg.Value)
+			d, err := InterpolateEnv(scope.Env, nil, arg.Value)
 			if err != nil {
 				return nil, fmt.Errorf(err.Error())
 			}
@@ -2,6 +2,7 @@ package stdlib
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/laher/smoosh/object"
 )
@@ -30,9 +31,20 @@ func GetFn(name string) (*object.Builtin, bool) {
 	return bi, ok
 }
 
+// Names returns the names of all builtins, sorted
+func Names() []string {
+	names := []string{}
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 var builtins = map[string]*object.Builtin{
 	"len": &object.Builtin{
-		Help: "Return the length of an array",
+		Help:  "Return the length of an array",
+		Arity: &object.Arity{Min: 1, Max: 1},
 		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
@@ -55,7 +67,8 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"first": &object.Builtin{
-		Help: "Return the first item of an array",
+		Help:  "Return the first item of an array",
+		Arity: &object.Arity{Min: 1, Max: 1},
 		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
@@ -75,7 +88,8 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"last": &object.Builtin{
-		Help: "Return the last item of an array",
+		Help:  "Return the last item of an array",
+		Arity: &object.Arity{Min: 1, Max: 1},
 		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
@@ -98,7 +112,8 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"rest": &object.Builtin{
-		Help: "Return the rest of an array apart from the first element",
+		Help:  "Return the rest of an array apart from the first element",
+		Arity: &object.Arity{Min: 1, Max: 1},
 		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if len(args) != 1 {
 				return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
@@ -123,7 +138,8 @@ var builtins = map[string]*object.Builtin{
 		},
 	},
 	"push": &object.Builtin{
-		Help: "Push an item onto the end of an array",
+		Help:  "Push an item onto the end of an array",
+		Arity: &object.Arity{Min: 2, Max: 2},
 		Fn: func(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if len(args) != 2 {
 				return nil, fmt.Errorf("wrong number of arguments. got=%d, want=2",
@@ -4,17 +4,17 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"strings"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "E"},
-		object.Flag{Name: "n"},
-		object.Flag{Name: "s"},
+		object.Flag{Name: "E", Long: "show_ends"},
+		object.Flag{Name: "n", Long: "number"},
+		object.Flag{Name: "s", Long: "squeeze_blank"},
 	}
 	RegisterBuiltin("cat", &object.Builtin{
 		Fn:    cat,
@@ -49,7 +49,10 @@ func cat(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		}
 	}
 
-	op := catIt(scope.Env.Streams.Stdin, scope.Env.Streams.Stdout, fileNames, showEnds, number, squeezeBlank)
+	if err := scope.Env.CheckRead(fileNames...); err != nil {
+		return nil, err
+	}
+	op := catIt(scope.Env.Files(), scope.Env.Streams.Stdin, scope.Env.Streams.Stdout, fileNames, showEnds, number, squeezeBlank)
 	return func() object.Object {
 		err := op()
 		if err != nil {
@@ -61,12 +64,12 @@ func cat(scope object.Scope, args ...object.Object) (object.Operation, error) {
 
 type op func() error
 
-func catIt(stdin io.Reader, stdout io.Writer, fileNames []string, showEnds, number, squeezeBlank bool) op {
+func catIt(fs vfs.FileSystem, stdin io.Reader, stdout io.Writer, fileNames []string, showEnds, number, squeezeBlank bool) op {
 	var op op
 	if len(fileNames) > 0 {
 		op = func() error {
 			for _, fileName := range fileNames {
-				file, err := os.Open(fileName)
+				file, err := fs.Open(fileName)
 				if err != nil {
 					return err
 				}
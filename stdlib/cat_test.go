@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"reflect"
 	"testing"
+
+	"github.com/laher/smoosh/vfs"
 )
 
 func TestCat(t *testing.T) {
@@ -23,7 +25,7 @@ func TestCat(t *testing.T) {
 	for _, test := range tests {
 		in := bytes.NewBuffer([]byte(test.stdin))
 		out := bytes.NewBuffer([]byte{})
-		op := catIt(in, out, test.f, test.ends, test.n, test.sq)
+		op := catIt(vfs.NewMem(), in, out, test.f, test.ends, test.n, test.sq)
 		err := op()
 		if err != nil {
 			t.Errorf("unexpected error %v", err)
@@ -2,36 +2,103 @@ package stdlib
 
 import (
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/laher/smoosh/object"
 )
 
 func init() {
-	RegisterFn("cd", cd)
+	RegisterBuiltin("cd", &object.Builtin{
+		Fn:    cd,
+		Arity: &object.Arity{Min: 1, Max: 1},
+	})
+	RegisterBuiltin("pushd", &object.Builtin{
+		Fn:    pushd,
+		Help:  "Save the current directory and change to another one",
+		Arity: &object.Arity{Min: 1, Max: 1},
+	})
+	RegisterBuiltin("popd", &object.Builtin{
+		Fn:    popd,
+		Help:  "Return to the most recently pushed directory",
+		Arity: &object.Arity{Min: 0, Max: 0},
+	})
+	RegisterBuiltin("dirs", &object.Builtin{
+		Fn:    dirs,
+		Help:  "Print the directory stack",
+		Arity: &object.Arity{Min: 0, Max: 0},
+	})
 }
 
 func cd(scope object.Scope, args ...object.Object) (object.Operation, error) {
-	if len(args) != 1 {
-		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=1",
+	d, err := dirArg("cd", scope, args)
+	if err != nil {
+		return nil, err
+	}
+	return func() object.Object {
+		scope.Env.WorkDir.Set(d)
+		return Null
+	}, nil
+}
+
+func pushd(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	d, err := dirArg("pushd", scope, args)
+	if err != nil {
+		return nil, err
+	}
+	return func() object.Object {
+		scope.Env.WorkDir.Push(d)
+		return Null
+	}, nil
+}
+
+func popd(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=0",
 			len(args))
 	}
-	switch arg := args[0].(type) {
-	case *object.String:
-		d, err := Interpolate(scope.Env.Export(), arg.Value)
-		if err != nil {
-			return nil, err
+	return func() object.Object {
+		if _, err := scope.Env.WorkDir.Pop(); err != nil {
+			return object.NewError("%s", err)
 		}
-		return func() object.Object {
-			err = os.Chdir(d)
-			if err != nil {
-				return object.NewError(err.Error())
-			}
-			return Null
-		}, nil
-	default:
-		return nil, fmt.Errorf("argument to `cd` not supported, got %s",
-			args[0].Type())
+		return Null
+	}, nil
+}
+
+func dirs(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 0 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=0",
+			len(args))
 	}
+	return func() object.Object {
+		fmt.Fprintln(scope.Env.Streams.Stdout, strings.Join(scope.Env.WorkDir.Stack(), " "))
+		return Null
+	}, nil
+}
 
+// dirArg interpolates a directory argument, and checks that it's an existing directory
+func dirArg(name string, scope object.Scope, args []object.Object) (string, error) {
+	if len(args) != 1 {
+		return "", fmt.Errorf("wrong number of arguments. got=%d, want=1",
+			len(args))
+	}
+	arg, ok := args[0].(*object.String)
+	if !ok {
+		return "", fmt.Errorf("argument to `%s` not supported, got %s",
+			name, args[0].Type())
+	}
+	d, err := InterpolateEnv(scope.Env, nil, arg.Value)
+	if err != nil {
+		return "", err
+	}
+	if err := scope.Env.CheckRead(d); err != nil {
+		return "", err
+	}
+	fi, err := scope.Env.Files().Stat(d)
+	if err != nil {
+		return "", err
+	}
+	if !fi.IsDir() {
+		return "", fmt.Errorf("%s: not a directory: %s", name, d)
+	}
+	return d, nil
 }
@@ -0,0 +1,65 @@
+package stdlib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/laher/smoosh/object"
+)
+
+// colors are the ANSI SGR codes of color names and styles
+var colors = map[string]int{
+	"bold":      1,
+	"dim":       2,
+	"underline": 4,
+	"black":     30,
+	"red":       31,
+	"green":     32,
+	"yellow":    33,
+	"blue":      34,
+	"magenta":   35,
+	"cyan":      36,
+	"white":     37,
+}
+
+func colorNames() []string {
+	names := []string{}
+	for name := range colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	RegisterBuiltin("color", &object.Builtin{
+		Fn:    color,
+		Arity: &object.Arity{Min: 2, Max: 2},
+		Help: `Usage: color(COLOR, TEXT)
+Wrap TEXT in the ANSI escape codes for COLOR, e.g. for a prompt.
+Colors: ` + strings.Join(colorNames(), ", "),
+	})
+}
+
+func color(scope object.Scope, args ...object.Object) (object.Operation, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("wrong number of arguments. got=%d, want=2",
+			len(args))
+	}
+	name, ok := args[0].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 1 to `color` not supported, got %s", args[0].Type())
+	}
+	code, ok := colors[name.Value]
+	if !ok {
+		return nil, fmt.Errorf("unknown color %q", name.Value)
+	}
+	text, ok := args[1].(*object.String)
+	if !ok {
+		return nil, fmt.Errorf("argument 2 to `color` not supported, got %s", args[1].Type())
+	}
+	return func() object.Object {
+		return &object.String{Value: fmt.Sprintf("\x1b[%dm%s\x1b[0m", code, text.Value)}
+	}, nil
+}
@@ -8,13 +8,15 @@ import (
 	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	RegisterBuiltin("cp", &object.Builtin{
-		Fn: cp,
+		Fn:       cp,
+		Mutating: true,
 		Flags: []object.Flag{
-			object.Flag{Name: "r", Help: "copy directories recursively"},
+			object.Flag{Name: "r", Long: "recursive", Help: "copy directories recursively"},
 		},
 		Help: `➜  smoosh git:(master) ✗ cp --help
 Usage: cp [OPTION]... SOURCE DEST
@@ -46,9 +48,15 @@ func cp(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}
 	srces := all[:len(all)-1]
 	dest := all[len(all)-1] //if more than 2 args then dest should be a directory
+	if err := scope.Env.CheckRead(srces...); err != nil {
+		return nil, err
+	}
+	if err := scope.Env.CheckWrite(dest); err != nil {
+		return nil, err
+	}
 	return func() object.Object {
 		for _, src := range srces {
-			err := copyFile(src, dest, recursive)
+			err := copyFile(scope.Env.Files(), src, dest, recursive)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -57,14 +65,14 @@ func cp(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func copyFile(src, dest string, recursive bool) error {
+func copyFile(fs vfs.FileSystem, src, dest string, recursive bool) error {
 	//println("copy "+src+" to "+dest)
 
-	srcFile, err := os.Open(src)
-	defer srcFile.Close()
+	srcFile, err := fs.Open(src)
 	if err != nil {
 		return err
 	}
+	defer srcFile.Close()
 	sinf, err := srcFile.Stat()
 	if err != nil {
 		return err
@@ -75,7 +83,7 @@ func copyFile(src, dest string, recursive bool) error {
 
 	//check if destination given is full filename or its (existing) parent dir
 	var destFull string
-	dinf, err := os.Stat(dest)
+	dinf, err := fs.Stat(dest)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -93,7 +101,7 @@ func copyFile(src, dest string, recursive bool) error {
 	//println("copy "+src+" to "+destFull)
 
 	var destExists bool
-	dinf, err = os.Stat(destFull)
+	dinf, err = fs.Stat(destFull)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -111,7 +119,7 @@ func copyFile(src, dest string, recursive bool) error {
 		//println("copying dir")
 		if !destExists {
 			//println("mkdir")
-			err = os.Mkdir(destFull, sinf.Mode())
+			err = fs.Mkdir(destFull, sinf.Mode())
 			if err != nil {
 				return err
 			}
@@ -127,7 +135,7 @@ func copyFile(src, dest string, recursive bool) error {
 			return err
 		}
 		for _, fi := range contents {
-			copyFile(filepath.Join(src, fi.Name()), destFull, recursive)
+			copyFile(fs, filepath.Join(src, fi.Name()), destFull, recursive)
 		}
 	} else {
 		flags := os.O_WRONLY
@@ -136,11 +144,11 @@ func copyFile(src, dest string, recursive bool) error {
 		} else {
 			flags = flags + os.O_TRUNC
 		}
-		destFile, err := os.OpenFile(destFull, flags, sinf.Mode())
-		defer destFile.Close()
+		destFile, err := fs.OpenFile(destFull, flags, sinf.Mode())
 		if err != nil {
 			return err
 		}
+		defer destFile.Close()
 		_, err = io.Copy(destFile, srcFile)
 		if err != nil {
 			return err
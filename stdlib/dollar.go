@@ -1,17 +1,26 @@
 package stdlib
 
 import (
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"os/exec"
+	"strconv"
 	"strings"
 	"unicode"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/term"
 )
 
 func init() {
-	RegisterFn("$", dollar)
+	// external commands may do anything, so they're treated as mutating
+	RegisterBuiltin("$", &object.Builtin{
+		Fn:       dollar,
+		Mutating: true,
+		Arity:    &object.Arity{Min: 1, Max: -1},
+	})
 }
 
 func dollar(scope object.Scope, args ...object.Object) (object.Operation, error) {
@@ -26,7 +35,7 @@ func dollar(scope object.Scope, args ...object.Object) (object.Operation, error)
 		case *object.String:
 			strings := parseArgv(arg.Value)
 			for _, s := range strings {
-				input, err := Interpolate(envV, s)
+				input, err := InterpolateEnv(scope.Env, envV, s)
 				if err != nil {
 					return nil, fmt.Errorf("cannot parse arg for interpolation - %s",
 						err)
@@ -38,7 +47,12 @@ func dollar(scope object.Scope, args ...object.Object) (object.Operation, error)
 				args[i].Type())
 		}
 	}
+	if err := scope.Env.Policy.CheckExec(inputs[0]); err != nil {
+		return nil, err
+	}
+	scope.Env.Options.Tracef(scope.Line, "exec %s", quoteArgv(inputs))
 	cmd := exec.Command(inputs[0], inputs[1:]...)
+	cmd.Dir = scope.Env.WorkDir.Get()
 	if scope.Out != nil {
 		stdOut, err := cmd.StdoutPipe()
 		if err != nil {
@@ -60,15 +74,44 @@ func dollar(scope object.Scope, args ...object.Object) (object.Operation, error)
 	} else {
 		cmd.Stdin = scope.Env.Streams.Stdin
 	}
+	run := cmd.Run
+	if fd, ok := interactive(scope); ok {
+		// the command gets the terminal itself, e.g. for an editor or a password prompt
+		cmd.Stdin = os.Stdin
+		run = func() error { return runInteractive(cmd, fd) }
+	}
 	return func() object.Object {
-		err := cmd.Run()
+		err := run()
 		if err != nil {
-			return object.NewError(err.Error())
+			e := object.NewError("%s", err)
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				e.ExitCode = exitErr.ExitCode()
+			}
+			return e
 		}
 		return Null
 	}, nil
 }
 
+// interactive reports whether a command should be run interactively, returning the
+// terminal's fd: it's a statement of its own, smoosh's stdin is a terminal, and its output
+// goes to the terminal too, rather than being piped or captured
+func interactive(scope object.Scope) (int, bool) {
+	if !scope.Statement || scope.In != nil || scope.Out != nil {
+		return 0, false
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return 0, false
+	}
+	out, ok := scope.Env.Streams.Stdout.(*os.File)
+	if !ok || !term.IsTerminal(int(out.Fd())) {
+		return 0, false
+	}
+	return fd, true
+}
+
 func parseArgv(p string) []string {
 	lastQuote := rune(0)
 	f := func(c rune) bool {
@@ -89,3 +132,11 @@ func parseArgv(p string) []string {
 	m := strings.FieldsFunc(p, f)
 	return m
 }
+
+func quoteArgv(argv []string) string {
+	quoted := make([]string, 0, len(argv))
+	for _, arg := range argv {
+		quoted = append(quoted, strconv.Quote(arg))
+	}
+	return strings.Join(quoted, " ")
+}
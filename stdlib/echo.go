@@ -8,7 +8,10 @@ import (
 )
 
 func init() {
-	RegisterFn("echo", echo)
+	RegisterBuiltin("echo", &object.Builtin{
+		Fn:    echo,
+		Arity: &object.Arity{Min: 1, Max: -1},
+	})
 }
 
 func echo(scope object.Scope, args ...object.Object) (object.Operation, error) {
@@ -17,7 +20,7 @@ func echo(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	for i := range args {
 		switch arg := args[i].(type) {
 		case *object.String:
-			input, err := Interpolate(envV, arg.Value)
+			input, err := InterpolateEnv(scope.Env, envV, arg.Value)
 			if err != nil {
 				return nil, fmt.Errorf("cannot parse arg for interpolation - %s",
 					err)
@@ -8,7 +8,10 @@ import (
 )
 
 func init() {
-	RegisterFn("exit", exit)
+	RegisterBuiltin("exit", &object.Builtin{
+		Fn:    exit,
+		Arity: &object.Arity{Min: 0, Max: 1},
+	})
 }
 
 func exit(scope object.Scope, args ...object.Object) (object.Operation, error) {
@@ -26,6 +29,9 @@ func exit(scope object.Scope, args ...object.Object) (object.Operation, error) {
 				args[0].Type())
 		}
 	}
+	if err := scope.Env.Policy.CheckExit(); err != nil {
+		return nil, err
+	}
 
 	return func() object.Object {
 		os.Exit(code)
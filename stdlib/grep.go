@@ -4,24 +4,23 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 	"regexp"
 	"strings"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "P"},
-		object.Flag{Name: "E"},
-		object.Flag{Name: "i"},
-		object.Flag{Name: "H"},
-		object.Flag{Name: "n"},
-		object.Flag{Name: "v"},
-		object.Flag{Name: "r"},
+		object.Flag{Name: "P", Long: "perl"},
+		object.Flag{Name: "E", Long: "extended"},
+		object.Flag{Name: "i", Long: "ignore_case"},
+		object.Flag{Name: "H", Long: "with_filename"},
+		object.Flag{Name: "n", Long: "line_number"},
+		object.Flag{Name: "v", Long: "invert_match"},
+		object.Flag{Name: "r", Long: "recursive"},
 	}
 	RegisterBuiltin("grep", &object.Builtin{
 		Fn:    grep,
@@ -88,18 +87,18 @@ func grep(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	if len(grep.paths) > 1 {
 		grep.IsPrintFilename = true
 	}
-
-	reg, err := compile(grep)
-	if err != nil {
+	if err := scope.Env.CheckRead(grep.paths...); err != nil {
 		return nil, err
 	}
-	cwd, err := os.Getwd()
+
+	reg, err := compile(grep)
 	if err != nil {
 		return nil, err
 	}
+	cwd := scope.Env.WorkDir.Get()
 	return func() object.Object {
 		if len(grep.paths) > 0 {
-			err = grepAll(reg, cwd, grep.paths, grep, scope.Env.Streams.Stdout)
+			err = grepAll(scope.Env.Files(), reg, cwd, grep.paths, grep, scope.Env.Streams.Stdout)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -118,24 +117,24 @@ func grep(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func grepAll(reg *regexp.Regexp, cwd string, files []string, grep *Grep, out io.Writer) error {
+func grepAll(fs vfs.FileSystem, reg *regexp.Regexp, cwd string, files []string, grep *Grep, out io.Writer) error {
 	for _, filename := range files {
-		fi, err := os.Stat(filename)
+		fi, err := fs.Stat(filename)
 		if err != nil {
 			return err
 		}
 		if fi.IsDir() {
 			//recurse here
 			if grep.IsRecurse {
-				entries, err := ioutil.ReadDir(filename)
+				entries, err := fs.ReadDir(filename)
 				if err != nil {
 					return err
 				}
-				fs := []string{}
+				paths := []string{}
 				for _, e := range entries {
-					fs = append(fs, filepath.Join(filename, e.Name()))
+					paths = append(paths, filepath.Join(filename, e.Name()))
 				}
-				err = grepAll(reg, filename, fs, grep, out)
+				err = grepAll(fs, reg, filename, paths, grep, out)
 				if err != nil {
 					return err
 				}
@@ -143,7 +142,7 @@ func grepAll(reg *regexp.Regexp, cwd string, files []string, grep *Grep, out io.
 			}
 			continue
 		}
-		file, err := os.Open(filename)
+		file, err := fs.Open(filename)
 		if err != nil {
 			return err
 		}
@@ -4,20 +4,21 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "t", Help: "Test archive data"},
-		object.Flag{Name: "k", Help: "keep gzip file"},
-		object.Flag{Name: "c", Help: "output will go to the standard output"},
+		object.Flag{Name: "t", Long: "test", Help: "Test archive data"},
+		object.Flag{Name: "k", Long: "keep", Help: "keep gzip file"},
+		object.Flag{Name: "c", Long: "stdout", Help: "output will go to the standard output"},
 	}
 	RegisterBuiltin("gunzip", &object.Builtin{
-		Fn:    gunzip,
-		Flags: opts,
+		Fn:       gunzip,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -27,10 +28,12 @@ type Gunzip struct {
 	IsKeep    bool
 	IsPipeOut bool
 	Filenames []string
+	env       *object.Environment
+	fs        vfs.FileSystem
 }
 
 func gunzip(scope object.Scope, args ...object.Object) (object.Operation, error) {
-	gunzip := &Gunzip{}
+	gunzip := &Gunzip{env: scope.Env, fs: scope.Env.Files()}
 	var err error
 	gunzip.Filenames, err = interpolateArgs(scope.Env, args, true)
 	if err != nil {
@@ -52,9 +55,18 @@ func gunzip(scope object.Scope, args ...object.Object) (object.Operation, error)
 		}
 	}
 
+	if gunzip.IsTest || (gunzip.IsPipeOut && gunzip.IsKeep) {
+		err = scope.Env.CheckRead(gunzip.Filenames...)
+	} else {
+		err = scope.Env.CheckWrite(gunzip.Filenames...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	return func() object.Object {
 		if gunzip.IsTest {
-			err := TestGzipItems(gunzip.Filenames)
+			err := TestGzipItems(scope.Env.Files(), gunzip.Filenames)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -68,9 +80,9 @@ func gunzip(scope object.Scope, args ...object.Object) (object.Operation, error)
 	}, nil
 }
 
-func TestGzipItems(items []string) error {
+func TestGzipItems(fs vfs.FileSystem, items []string) error {
 	for _, item := range items {
-		fh, err := os.Open(item)
+		fh, err := fs.Open(item)
 		if err != nil {
 			return err
 		}
@@ -101,7 +113,7 @@ func (gunzip *Gunzip) gunzipItems(inPipe io.Reader, outPipe io.Writer, errPipe i
 		}
 	} else {
 		for _, item := range gunzip.Filenames {
-			fh, err := os.Open(item)
+			fh, err := gunzip.fs.Open(item)
 			if err != nil {
 				return err
 			}
@@ -114,7 +126,7 @@ func (gunzip *Gunzip) gunzipItems(inPipe io.Reader, outPipe io.Writer, errPipe i
 				return err
 			}
 			if !gunzip.IsKeep {
-				err = os.Remove(item)
+				err = gunzip.fs.Remove(item)
 				if err != nil {
 					return err
 				}
@@ -137,12 +149,15 @@ func (gunzip *Gunzip) gunzipItem(item io.Reader, outPipe io.Writer, errPipe io.W
 		}
 	} else {
 		destFileName := r.Header.Name
+		if err := gunzip.env.CheckWrite(destFileName); err != nil {
+			return err
+		}
 		fmt.Fprintln(errPipe, "Filename", destFileName)
-		destFile, err := os.Create(destFileName)
-		defer destFile.Close()
+		destFile, err := vfs.Create(gunzip.fs, destFileName)
 		if err != nil {
 			return err
 		}
+		defer destFile.Close()
 		_, err = io.Copy(destFile, r)
 		if err != nil {
 			return err
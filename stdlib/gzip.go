@@ -5,22 +5,23 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "t", Help: "Test archive data"},
-		object.Flag{Name: "k", Help: "keep gzip file"},
-		object.Flag{Name: "c", Help: "output will go to the standard output"},
+		object.Flag{Name: "t", Long: "test", Help: "Test archive data"},
+		object.Flag{Name: "k", Long: "keep", Help: "keep gzip file"},
+		object.Flag{Name: "c", Long: "stdout", Help: "output will go to the standard output"},
 	}
 	RegisterBuiltin("gzip", &object.Builtin{
-		Fn:    gz,
-		Flags: opts,
+		Fn:       gz,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -53,6 +54,20 @@ func gz(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		}
 	}
 
+	if gz.IsStdout {
+		err = scope.Env.CheckRead(gz.Filenames...)
+	} else {
+		err = scope.Env.CheckWrite(gz.Filenames...)
+		for _, f := range gz.Filenames {
+			if err == nil {
+				err = scope.Env.CheckWrite(f + ".gz")
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	return func() object.Object {
 		if len(gz.Filenames) < 1 {
 			//pipe in?
@@ -61,7 +76,7 @@ func gz(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			if gz.outFile != "" {
 				outputFilename = gz.outFile
 				var err error
-				w, err := os.Create(outputFilename)
+				w, err := vfs.Create(scope.Env.Files(), outputFilename)
 				if err != nil {
 					return object.NewError(err.Error())
 				}
@@ -79,7 +94,7 @@ func gz(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		} else {
 			//todo make sure it closes saved file cleanly
 			for _, inputFilename := range gz.Filenames {
-				inputFile, err := os.Open(inputFilename)
+				inputFile, err := scope.Env.Files().Open(inputFilename)
 				if err != nil {
 					return object.NewError(err.Error())
 				}
@@ -88,7 +103,7 @@ func gz(scope object.Scope, args ...object.Object) (object.Operation, error) {
 				var writer io.Writer
 				if !gz.IsStdout {
 					outputFilename := inputFilename + ".gz"
-					gzf, err := os.Create(outputFilename)
+					gzf, err := vfs.Create(scope.Env.Files(), outputFilename)
 					if err != nil {
 						return object.NewError(err.Error())
 					}
@@ -109,7 +124,7 @@ func gz(scope object.Scope, args ...object.Object) (object.Operation, error) {
 
 				// only remove source if specified and possible
 				if !gz.IsKeep && !gz.IsStdout {
-					err = os.Remove(inputFilename)
+					err = scope.Env.Files().Remove(inputFilename)
 					if err != nil {
 						return object.NewError(err.Error())
 					}
@@ -4,14 +4,14 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "n", ParamType: object.INTEGER_OBJ},
+		object.Flag{Name: "n", Long: "lines", ParamType: object.INTEGER_OBJ},
 	}
 	RegisterBuiltin("head", &object.Builtin{
 		Fn:    head,
@@ -49,8 +49,12 @@ func head(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		}
 	}
 
+	if err := scope.Env.CheckRead(head.Filenames...); err != nil {
+		return nil, err
+	}
+
 	return func() object.Object {
-		err := head.do(scope.Env.Streams)
+		err := head.do(scope.Env.Files(), scope.Env.Streams)
 		if err != nil {
 			return object.NewError(err.Error())
 		}
@@ -58,10 +62,10 @@ func head(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func (head *Head) do(streams object.Streams) error {
+func (head *Head) do(fs vfs.FileSystem, streams object.Streams) error {
 	if len(head.Filenames) > 0 {
 		for _, fileName := range head.Filenames {
-			file, err := os.Open(fileName)
+			file, err := fs.Open(fileName)
 			if err != nil {
 				return err
 			}
@@ -16,13 +16,7 @@ func help(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	for i := range args {
 		switch arg := args[i].(type) {
 		case *object.Builtin:
-			h := arg.Help
-			for _, flag := range arg.Flags {
-				if flag.ParamType == "" {
-					flag.ParamType = object.BOOLEAN_OBJ
-				}
-				h = fmt.Sprintf("%s\n%s (%s):\t%s", h, flag.Name, flag.ParamType, flag.Help)
-			}
+			h := Help(arg)
 			return func() object.Object {
 				return &object.String{
 					Value: h,
@@ -34,3 +28,19 @@ func help(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		return &object.String{Value: "use help(fn) to find out more about a particular function fn"}
 	}, nil
 }
+
+// Help describes a builtin and its flags
+func Help(builtin *object.Builtin) string {
+	h := builtin.Help
+	for _, flag := range builtin.Flags {
+		if flag.ParamType == "" {
+			flag.ParamType = object.BOOLEAN_OBJ
+		}
+		name := flag.Name
+		if flag.Long != "" {
+			name += ", " + flag.Long
+		}
+		h = fmt.Sprintf("%s\n%s (%s):\t%s", h, name, flag.ParamType, flag.Help)
+	}
+	return h
+}
@@ -9,8 +9,14 @@ import (
 	"github.com/laher/smoosh/object"
 )
 
+// maxRedirects is how many redirects http.Get follows, as for net/http's default client
+const maxRedirects = 10
+
 func init() {
-	RegisterFn("http.Get", get)
+	RegisterBuiltin("http.Get", &object.Builtin{
+		Fn:    get,
+		Arity: &object.Arity{Min: 1, Max: 1},
+	})
 }
 func get(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	if len(args) != 1 {
@@ -19,12 +25,24 @@ func get(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}
 	switch arg := args[0].(type) {
 	case *object.String:
-		a, err := Interpolate(scope.Env.Export(), arg.Value)
+		a, err := InterpolateEnv(scope.Env, nil, arg.Value)
 		if err != nil {
 			return nil, fmt.Errorf(err.Error())
 		}
+		if err := scope.Env.Policy.CheckNetwork(a); err != nil {
+			return nil, err
+		}
+		client := &http.Client{
+			// each redirect is checked against the policy too
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return scope.Env.Policy.CheckNetwork(req.URL.String())
+			},
+		}
 		return func() object.Object {
-			resp, err := http.Get(a)
+			resp, err := client.Get(a)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package stdlib
+
+import "os/exec"
+
+// runInteractive runs a command which has the terminal on fd as its stdin
+func runInteractive(cmd *exec.Cmd, fd int) error {
+	return cmd.Run()
+}
@@ -0,0 +1,33 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd || dragonfly
+
+package stdlib
+
+import (
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/laher/smoosh/term"
+)
+
+// runInteractive runs a command which has the terminal on fd as its stdin. The command
+// runs as its own process group in the terminal's foreground, so that keys such as Ctrl-C
+// and Ctrl-Z signal it rather than smoosh. Afterwards, smoosh takes the terminal back and
+// restores its state, in case the command left it changed.
+func runInteractive(cmd *exec.Cmd, fd int) error {
+	state, err := term.GetState(fd)
+	if err != nil {
+		return cmd.Run()
+	}
+	defer term.Restore(fd, state)
+	// changing the foreground from the background raises SIGTTOU, which would stop smoosh
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	// Ctty is the command's stdin
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Foreground: true, Ctty: 0}
+	err = cmd.Run()
+	if fgErr := term.SetForeground(fd, syscall.Getpgrp()); err == nil {
+		err = fgErr
+	}
+	return err
+}
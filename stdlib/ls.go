@@ -5,7 +5,6 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"math"
 	"os"
 	"path/filepath"
@@ -13,14 +12,15 @@ import (
 	"text/tabwriter"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "l", Help: "Use a long listing format"},
-		object.Flag{Name: "R", Help: "List subdirectories recursively"},
-		object.Flag{Name: "a", Help: "All files (do not ignore entries starting with .)"},
-		object.Flag{Name: "h", Help: "Print human readable sizes (e.g., 1K 234M 2G)"},
+		object.Flag{Name: "l", Long: "long", Help: "Use a long listing format"},
+		object.Flag{Name: "R", Long: "recursive", Help: "List subdirectories recursively"},
+		object.Flag{Name: "a", Long: "all", Help: "All files (do not ignore entries starting with .)"},
+		object.Flag{Name: "h", Long: "human_readable", Help: "Print human readable sizes (e.g., 1K 234M 2G)"},
 	}
 
 	RegisterBuiltin("ls", &object.Builtin{
@@ -48,6 +48,8 @@ type Ls struct {
 	Filenames []string
 
 	counter int
+	env     *object.Environment
+	fs      vfs.FileSystem
 }
 
 /*
@@ -70,7 +72,7 @@ func (bi bi) builtinW(scope object.Scope, args ...object.Object) (object.Operati
 
 func ls(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	//object.Object {
-	ls := &Ls{}
+	ls := &Ls{env: scope.Env, fs: scope.Env.Files()}
 	if scope.In != nil {
 		ls.Stdin = true
 	}
@@ -92,7 +94,7 @@ func ls(scope object.Scope, args ...object.Object) (object.Operation, error) {
 				ls.Human = true
 			case "1":
 				ls.OnePerLine = true
-			case "r":
+			case "R":
 				ls.Recursive = true
 			default:
 				return nil, fmt.Errorf("flag %s not supported", arg.Name)
@@ -117,6 +119,9 @@ func (ls *Ls) Go(streams object.Streams) error {
 	if err != nil {
 		return err
 	}
+	if err := ls.env.CheckRead(args...); err != nil {
+		return err
+	}
 
 	ls.counter = 0
 	lastWasDir := false
@@ -124,7 +129,7 @@ func (ls *Ls) Go(streams object.Streams) error {
 	for i, arg := range args {
 		if !strings.HasPrefix(arg, ".") || ls.AllFiles ||
 			strings.HasPrefix(arg, "..") || "." == arg {
-			argInfo, err := os.Stat(arg)
+			argInfo, err := ls.fs.Stat(arg)
 			if err != nil {
 				fmt.Fprintln(streams.Stderr, "stat failed for ", arg)
 				return err
@@ -144,13 +149,13 @@ func (ls *Ls) Go(streams object.Streams) error {
 
 				//show . and ..
 				if ls.AllFiles {
-					df, err := os.Stat(filepath.Dir(dir))
+					df, err := ls.fs.Stat(filepath.Dir(dir))
 					if err != nil {
 						fmt.Fprintf(tout, "Error opening parent dir: %v", err)
 					} else {
 						printEntry("..", df, tout, ls)
 					}
-					df, err = os.Stat(dir)
+					df, err = ls.fs.Stat(dir)
 					if err != nil {
 						fmt.Fprintf(tout, "Error opening dir: %v", err)
 					} else {
@@ -187,7 +192,7 @@ func list(out *tabwriter.Writer, errPipe io.Writer, dir, prefix string, ls *Ls)
 	if !strings.HasPrefix(dir, ".") || ls.AllFiles ||
 		strings.HasPrefix(dir, "..") || "." == dir {
 
-		entries, err := ioutil.ReadDir(dir)
+		entries, err := ls.fs.ReadDir(dir)
 		if err != nil {
 			fmt.Fprintf(errPipe, "Error reading dir '%s'", dir)
 			return endswithNewline, err
@@ -345,7 +350,7 @@ func getDirList(ls *Ls, inPipe io.Reader) ([]string, error) {
 			}
 			args := []string{}
 			for _, glob := range globs {
-				results, err := filepath.Glob(glob)
+				results, err := vfs.Glob(ls.fs, glob)
 				if err != nil {
 					return args, err
 				}
@@ -358,8 +363,7 @@ func getDirList(ls *Ls, inPipe io.Reader) ([]string, error) {
 			return args, nil
 		}
 		//NOT piping. Just use cwd by default.
-		cwd, err := os.Getwd()
-		return []string{cwd}, err
+		return []string{ls.env.WorkDir.Get()}, nil
 
 	}
 	return ls.Filenames, nil
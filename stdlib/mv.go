@@ -7,11 +7,13 @@ import (
 	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	RegisterBuiltin("mv", &object.Builtin{
-		Fn: mv,
+		Fn:       mv,
+		Mutating: true,
 	})
 
 }
@@ -30,11 +32,14 @@ func mv(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	if len(all) < 2 {
 		return nil, errors.New("Missing operand")
 	}
+	if err := scope.Env.CheckWrite(all...); err != nil {
+		return nil, err
+	}
 	srcs := all[:len(all)-1]
 	dest := all[len(all)-1]
 	return func() object.Object {
 		for _, src := range srcs {
-			err := moveFile(src, dest)
+			err := moveFile(scope.Env.Files(), src, dest)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -43,10 +48,10 @@ func mv(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func moveFile(src, dest string) error {
+func moveFile(fs vfs.FileSystem, src, dest string) error {
 	//wd, err := os.Getwd()
 	//fmt.Printf("%s: %s -> %s\n", wd, src, dest)
-	srcFile, err := os.Open(src)
+	srcFile, err := fs.Open(src)
 	if err != nil {
 		return err
 	}
@@ -61,7 +66,7 @@ func moveFile(src, dest string) error {
 
 	//check if destination given is full filename or its (existing) parent dir
 	var destFull string
-	dinf, err := os.Stat(dest)
+	dinf, err := fs.Stat(dest)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
@@ -76,6 +81,6 @@ func moveFile(src, dest string) error {
 			destFull = dest
 		}
 	}
-	err = os.Rename(src, destFull)
+	err = fs.Rename(src, destFull)
 	return err
 }
@@ -2,13 +2,15 @@ package stdlib
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/laher/smoosh/object"
 )
 
 func init() {
-	RegisterFn("pwd", pwd)
+	RegisterBuiltin("pwd", &object.Builtin{
+		Fn:    pwd,
+		Arity: &object.Arity{Min: 0, Max: 0},
+	})
 }
 
 func pwd(scope object.Scope, args ...object.Object) (object.Operation, error) {
@@ -17,12 +19,9 @@ func pwd(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			len(args))
 	}
 	return func() object.Object {
-		d, err := os.Getwd()
-		if err != nil {
-			return object.NewError(err.Error())
-		}
+		d := scope.Env.WorkDir.Get()
 		if scope.Out != nil {
-			fmt.Fprintf(scope.Env.Streams.Stdout, d)
+			fmt.Fprintln(scope.Env.Streams.Stdout, d)
 			return Null
 		}
 		//fmt.Println(d) //TODO make the repl print this somehow instead
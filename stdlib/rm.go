@@ -2,20 +2,20 @@ package stdlib
 
 import (
 	"fmt"
-	"io/ioutil"
-	"os"
 	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "r"},
+		object.Flag{Name: "r", Long: "recursive"},
 	}
 	RegisterBuiltin("rm", &object.Builtin{
-		Fn:    rm,
-		Flags: opts,
+		Fn:       rm,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -41,9 +41,13 @@ func rm(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		}
 	}
 
+	if err := scope.Env.CheckWrite(allFiles...); err != nil {
+		return nil, err
+	}
+
 	return func() object.Object {
 		for _, file := range allFiles {
-			err := deleteFile(file, isRecursive)
+			err := deleteFile(scope.Env.Files(), file, isRecursive)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -52,13 +56,13 @@ func rm(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func deleteFile(file string, recursive bool) error {
-	fi, e := os.Stat(file)
+func deleteFile(fs vfs.FileSystem, file string, recursive bool) error {
+	fi, e := fs.Stat(file)
 	if e != nil {
 		return e
 	}
 	if fi.IsDir() && recursive {
-		e := deleteDir(file)
+		e := deleteDir(fs, file)
 		if e != nil {
 			return e
 		}
@@ -66,16 +70,16 @@ func deleteFile(file string, recursive bool) error {
 		//do nothing
 		return fmt.Errorf("'%s' is a directory. Use -r", file)
 	}
-	return os.Remove(file)
+	return fs.Remove(file)
 }
 
-func deleteDir(dir string) error {
-	files, e := ioutil.ReadDir(dir)
+func deleteDir(fs vfs.FileSystem, dir string) error {
+	files, e := fs.ReadDir(dir)
 	if e != nil {
 		return e
 	}
 	for _, file := range files {
-		e = deleteFile(filepath.Join(dir, file.Name()), true)
+		e = deleteFile(fs, filepath.Join(dir, file.Name()), true)
 		if e != nil {
 			return e
 		}
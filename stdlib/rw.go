@@ -11,9 +11,13 @@ import (
 
 func init() {
 	RegisterBuiltin("w", &object.Builtin{
-		Fn:    write,
-		Flags: []object.Flag{{Name: "a"}}})
-	RegisterFn("r", read)
+		Fn:       write,
+		Mutating: true,
+		Flags:    []object.Flag{{Name: "a", Long: "append"}}})
+	RegisterBuiltin("r", &object.Builtin{
+		Fn:    read,
+		Arity: &object.Arity{Min: 1, Max: 2},
+	})
 }
 
 func write(scope object.Scope, args ...object.Object) (object.Operation, error) {
@@ -41,6 +45,14 @@ func write(scope object.Scope, args ...object.Object) (object.Operation, error)
 	if scope.In == nil {
 		return nil, fmt.Errorf("Nothing to write. 'w' expects an input stream")
 	}
+	for _, input := range inputs {
+		if input == "" {
+			continue
+		}
+		if err := scope.Env.CheckWrite(input); err != nil {
+			return nil, err
+		}
+	}
 	return func() object.Object {
 		opts := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
 		if app {
@@ -48,7 +60,7 @@ func write(scope object.Scope, args ...object.Object) (object.Operation, error)
 		}
 		// stdout
 		if inputs[0] != "" {
-			f, err := os.OpenFile(inputs[0], opts, 0666)
+			f, err := scope.Env.Files().OpenFile(inputs[0], opts, 0666)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -66,7 +78,7 @@ func write(scope object.Scope, args ...object.Object) (object.Operation, error)
 		}
 		// stderr
 		if len(inputs) > 1 && inputs[1] != "" && scope.In.Err != nil {
-			f, err := os.OpenFile(inputs[1], opts, 0666)
+			f, err := scope.Env.Files().OpenFile(inputs[1], opts, 0666)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -96,7 +108,10 @@ func read(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	if err != nil {
 		return nil, err
 	}
-	f, err := os.Open(inputs[0])
+	if err := scope.Env.CheckRead(inputs[0]); err != nil {
+		return nil, err
+	}
+	f, err := scope.Env.Files().Open(inputs[0])
 	if err != nil {
 		return nil, err
 	}
@@ -22,7 +22,7 @@ func sleep(scope object.Scope, args ...object.Object) (object.Operation, error)
 		case *object.Integer:
 			sl.amount = arg.Value
 		case *object.String:
-			d, err := Interpolate(scope.Env.Export(), arg.Value)
+			d, err := InterpolateEnv(scope.Env, nil, arg.Value)
 			if err != nil {
 				return nil, fmt.Errorf(err.Error())
 			}
@@ -3,10 +3,11 @@ package stdlib
 import (
 	"bytes"
 	"fmt"
-	"path/filepath"
 
 	"github.com/alecthomas/template"
+	"github.com/alecthomas/template/parse"
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 var (
@@ -20,13 +21,13 @@ func interpolateArgs(env *object.Environment, args []object.Object, glob bool) (
 	for i := range args {
 		switch arg := args[i].(type) {
 		case *object.String:
-			input, err := Interpolate(envV, arg.Value)
+			input, err := InterpolateEnv(env, envV, arg.Value)
 			if err != nil {
 				return nil, fmt.Errorf("cannot parse arg for interpolation - %s",
 					err)
 			}
 			if glob {
-				ss, err := filepath.Glob(input)
+				ss, err := vfs.Glob(env.Files(), input)
 				if err != nil {
 					return nil, err
 				}
@@ -66,3 +67,83 @@ func Interpolate(envV map[string]interface{}, value string) (string, error) {
 	}
 	return buf.String(), nil
 }
+
+// InterpolateEnv replaces strings using a template, with the environment's variables.
+// envV may be passed in when interpolating several values, otherwise it's exported from env.
+// With the nounset option, referencing an undefined variable is an error.
+func InterpolateEnv(env *object.Environment, envV map[string]interface{}, value string) (string, error) {
+	if envV == nil {
+		envV = env.Export()
+	}
+	if !env.Options.Is(object.OptNoUnset) {
+		return Interpolate(envV, value)
+	}
+	tmpl, err := template.New("test").Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if tmpl.Tree != nil {
+		if err := checkUnset(tmpl.Tree.Root, envV); err != nil {
+			return "", err
+		}
+	}
+	buf := bytes.NewBuffer([]byte{})
+	err = tmpl.Execute(buf, envV)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// checkUnset walks a template, checking that top-level fields are defined
+func checkUnset(node parse.Node, envV map[string]interface{}) error {
+	switch node := node.(type) {
+	case *parse.ListNode:
+		if node == nil {
+			return nil
+		}
+		for _, n := range node.Nodes {
+			if err := checkUnset(n, envV); err != nil {
+				return err
+			}
+		}
+	case *parse.ActionNode:
+		return checkUnset(node.Pipe, envV)
+	case *parse.PipeNode:
+		if node == nil {
+			return nil
+		}
+		for _, cmd := range node.Cmds {
+			for _, arg := range cmd.Args {
+				if err := checkUnset(arg, envV); err != nil {
+					return err
+				}
+			}
+		}
+	case *parse.IfNode:
+		return checkBranch(&node.BranchNode, envV, true)
+	case *parse.RangeNode:
+		// the body of range and with has a different dot
+		return checkBranch(&node.BranchNode, envV, false)
+	case *parse.WithNode:
+		return checkBranch(&node.BranchNode, envV, false)
+	case *parse.FieldNode:
+		if _, ok := envV[node.Ident[0]]; !ok {
+			return fmt.Errorf("undefined variable: %s", node.Ident[0])
+		}
+	}
+	return nil
+}
+
+func checkBranch(node *parse.BranchNode, envV map[string]interface{}, checkList bool) error {
+	if err := checkUnset(node.Pipe, envV); err != nil {
+		return err
+	}
+	if !checkList {
+		return nil
+	}
+	if err := checkUnset(node.List, envV); err != nil {
+		return err
+	}
+	return checkUnset(node.ElseList, envV)
+}
@@ -4,17 +4,17 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 	"time"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "n", ParamType: object.INTEGER_OBJ},
-		object.Flag{Name: "s", ParamType: object.INTEGER_OBJ},
-		object.Flag{Name: "F"},
+		object.Flag{Name: "n", Long: "lines", ParamType: object.INTEGER_OBJ},
+		object.Flag{Name: "s", Long: "sleep_interval", ParamType: object.INTEGER_OBJ},
+		object.Flag{Name: "F", Long: "follow"},
 	}
 	RegisterBuiltin("tail", &object.Builtin{
 		Fn:    tail,
@@ -63,8 +63,11 @@ func tail(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			}
 		}
 	}
+	if err := scope.Env.CheckRead(tail.Filenames...); err != nil {
+		return nil, err
+	}
 	return func() object.Object {
-		err := tail.do(scope.Env.Streams.Stdout, scope.Env.Streams.Stdin)
+		err := tail.do(scope.Env.Files(), scope.Env.Streams.Stdout, scope.Env.Streams.Stdin)
 		if err != nil {
 			return object.NewError(err.Error())
 		}
@@ -72,15 +75,15 @@ func tail(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func (tail *Tail) do(stdout io.Writer, stdin io.Reader) error {
+func (tail *Tail) do(fs vfs.FileSystem, stdout io.Writer, stdin io.Reader) error {
 	if len(tail.Filenames) > 0 {
 
 		for _, fileName := range tail.Filenames {
-			finf, err := os.Stat(fileName)
+			finf, err := fs.Stat(fileName)
 			if err != nil {
 				return err
 			}
-			file, err := os.Open(fileName)
+			file, err := fs.Open(fileName)
 			if err != nil {
 				return err
 			}
@@ -108,11 +111,11 @@ func (tail *Tail) do(stdout io.Writer, stdin io.Reader) error {
 					//sleep n.x seconds
 					//use milliseconds to get some accuracy with the int64
 					time.Sleep(sleepIntervalMs * time.Millisecond)
-					finf, err := os.Stat(fileName)
+					finf, err := fs.Stat(fileName)
 					if err != nil {
 						return err
 					}
-					file, err := os.Open(fileName)
+					file, err := fs.Open(fileName)
 					if err != nil {
 						return err
 					}
@@ -10,11 +10,12 @@ import (
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "a"},
+		object.Flag{Name: "a", Long: "append"},
 	}
 	RegisterBuiltin("tee", &object.Builtin{
-		Fn:    tee,
-		Flags: opts,
+		Fn:       tee,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -32,6 +33,9 @@ func tee(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		return nil, fmt.Errorf(err.Error())
 	}
 	tee.args = inputs
+	if err := scope.Env.CheckWrite(inputs...); err != nil {
+		return nil, err
+	}
 	for i := range args {
 		switch arg := args[i].(type) {
 		case *object.Flag:
@@ -60,7 +64,7 @@ func (tee *Tee) do(scope object.Scope) error {
 	closers := []io.WriteCloser{}
 	writers := []io.Writer{scope.Env.Streams.Stdout}
 	for _, file := range tee.args {
-		f, err := os.OpenFile(file, flag, 0666)
+		f, err := scope.Env.Files().OpenFile(file, flag, 0666)
 		if err != nil {
 			return err
 		}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
@@ -12,8 +13,9 @@ func init() {
 		object.Flag{Name: "a"},
 	}
 	RegisterBuiltin("touch", &object.Builtin{
-		Fn:    touch,
-		Flags: opts,
+		Fn:       touch,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -22,9 +24,12 @@ func touch(scope object.Scope, args ...object.Object) (object.Operation, error)
 	if err != nil {
 		return nil, err
 	}
+	if err := scope.Env.CheckWrite(files...); err != nil {
+		return nil, err
+	}
 	return func() object.Object {
 		for _, f := range files {
-			err := touchFile(f)
+			err := touchFile(scope.Env.Files(), f)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -33,11 +38,11 @@ func touch(scope object.Scope, args ...object.Object) (object.Operation, error)
 	}, nil
 }
 
-func touchFile(filename string) error {
-	_, err := os.Stat(filename)
+func touchFile(fs vfs.FileSystem, filename string) error {
+	_, err := fs.Stat(filename)
 	if err != nil {
 		if os.IsNotExist(err) {
-			file, err := os.Create(filename)
+			file, err := vfs.Create(fs, filename)
 			if err != nil {
 				return err
 			}
@@ -46,6 +51,6 @@ func touchFile(filename string) error {
 		return err
 	}
 	//set access times
-	os.Chtimes(filename, time.Now(), time.Now())
+	fs.Chtimes(filename, time.Now(), time.Now())
 	return nil
 }
@@ -9,16 +9,18 @@ import (
 	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "t", Help: "Test archive data"},
-		object.Flag{Name: "d", Help: "destination directory", ParamType: object.STRING_OBJ},
+		object.Flag{Name: "t", Long: "test", Help: "Test archive data"},
+		object.Flag{Name: "d", Long: "dir", Help: "destination directory", ParamType: object.STRING_OBJ},
 	}
 	RegisterBuiltin("unzip", &object.Builtin{
-		Fn:    unzip,
-		Flags: opts,
+		Fn:       unzip,
+		Mutating: true,
+		Flags:    opts,
 	})
 }
 
@@ -59,14 +61,23 @@ func unzip(scope object.Scope, args ...object.Object) (object.Operation, error)
 		}
 	}
 
+	if err := scope.Env.CheckRead(unzip.ZipFile); err != nil {
+		return nil, err
+	}
+	if !unzip.isTest {
+		if err := scope.Env.CheckWrite(unzip.destDir); err != nil {
+			return nil, err
+		}
+	}
+
 	return func() object.Object {
 		if unzip.isTest {
-			err := testItems(unzip.ZipFile, unzip.Filenames, scope.Env.Streams.Stdout, scope.Env.Streams.Stderr)
+			err := testItems(scope.Env.Files(), unzip.ZipFile, unzip.Filenames, scope.Env.Streams.Stdout, scope.Env.Streams.Stderr)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
 		} else {
-			err := unzipItems(unzip.ZipFile, unzip.destDir, unzip.Filenames, scope.Env.Streams.Stderr)
+			err := unzipItems(scope.Env.Files(), unzip.ZipFile, unzip.destDir, unzip.Filenames, scope.Env.Streams.Stderr, scope.Env)
 			if err != nil {
 				return object.NewError(err.Error())
 			}
@@ -75,12 +86,12 @@ func unzip(scope object.Scope, args ...object.Object) (object.Operation, error)
 	}, nil
 }
 
-func testItems(zipfile string, includeFiles []string, outPipe io.Writer, errPipe io.Writer) error {
-	r, err := zip.OpenReader(zipfile)
+func testItems(fs vfs.FileSystem, zipfile string, includeFiles []string, outPipe io.Writer, errPipe io.Writer) error {
+	r, closer, err := openZip(fs, zipfile)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	defer closer.Close()
 	for _, f := range r.File {
 		flags := f.FileHeader.Flags
 		if len(includeFiles) == 0 || containsGlob(includeFiles, f.Name, errPipe) {
@@ -94,6 +105,25 @@ func testItems(zipfile string, includeFiles []string, outPipe io.Writer, errPipe
 	return nil
 }
 
+// openZip opens a zip archive for reading from the filesystem
+func openZip(fs vfs.FileSystem, zipfile string) (*zip.Reader, io.Closer, error) {
+	f, err := fs.Open(zipfile)
+	if err != nil {
+		return nil, nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	r, err := zip.NewReader(f, fi.Size())
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return r, f, nil
+}
+
 func containsGlob(haystack []string, needle string, errPipe io.Writer) bool {
 	for _, item := range haystack {
 		m, err := filepath.Match(item, needle)
@@ -108,21 +138,21 @@ func containsGlob(haystack []string, needle string, errPipe io.Writer) bool {
 	return false
 }
 
-func unzipItems(zipfile, destDir string, includeFiles []string, errPipe io.Writer) error {
+func unzipItems(fs vfs.FileSystem, zipfile, destDir string, includeFiles []string, errPipe io.Writer, env *object.Environment) error {
 
-	r, err := zip.OpenReader(zipfile)
+	r, closer, err := openZip(fs, zipfile)
 	if err != nil {
 		return err
 	}
-	defer r.Close()
+	defer closer.Close()
 
-	dinf, err := os.Stat(destDir)
+	dinf, err := fs.Stat(destDir)
 	if err != nil {
 		if !os.IsNotExist(err) {
 			return err
 		} else {
 			//doesnt exist
-			err = os.MkdirAll(destDir, 0777) //TODO review permissions
+			err = fs.MkdirAll(destDir, 0777) //TODO review permissions
 			if err != nil {
 				return err
 			}
@@ -146,15 +176,19 @@ func unzipItems(zipfile, destDir string, includeFiles []string, errPipe io.Write
 				return err
 			}
 			destFileName := filepath.Join(destDir, f.Name)
+			if err := env.CheckWrite(destFileName); err != nil {
+				rc.Close()
+				return err
+			}
 			if finf.IsDir() {
 				//mkdir ...
-				fdinf, err := os.Stat(destFileName)
+				fdinf, err := fs.Stat(destFileName)
 				if err != nil {
 					if !os.IsNotExist(err) {
 						return err
 					}
 					//doesnt exist
-					err = os.MkdirAll(destFileName, finf.Mode())
+					err = fs.MkdirAll(destFileName, finf.Mode())
 					if err != nil {
 						return err
 					}
@@ -166,13 +200,13 @@ func unzipItems(zipfile, destDir string, includeFiles []string, errPipe io.Write
 			} else {
 				fileDestDir := filepath.Dir(destFileName)
 				if fileDestDir != destDir {
-					fdinf, err := os.Stat(fileDestDir)
+					fdinf, err := fs.Stat(fileDestDir)
 					if err != nil {
 						if !os.IsNotExist(err) {
 							return err
 						}
 						//doesnt exist
-						err = os.MkdirAll(fileDestDir, 0777) //TODO review dir permissions
+						err = fs.MkdirAll(fileDestDir, 0777) //TODO review dir permissions
 						if err != nil {
 							return err
 						}
@@ -183,11 +217,11 @@ func unzipItems(zipfile, destDir string, includeFiles []string, errPipe io.Write
 					}
 				}
 				//TODO remove on error
-				destFile, err := os.OpenFile(destFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, finf.Mode())
-				defer destFile.Close()
+				destFile, err := fs.OpenFile(destFileName, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, finf.Mode())
 				if err != nil {
 					return err
 				}
+				defer destFile.Close()
 				_, err = io.Copy(destFile, rc)
 				if err != nil {
 					return err
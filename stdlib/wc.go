@@ -4,16 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"io"
-	"os"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "l", Help: "Count lines"},
-		object.Flag{Name: "w", Help: "Count words"},
-		object.Flag{Name: "c", Help: "Count bytes"},
+		object.Flag{Name: "l", Long: "lines", Help: "Count lines"},
+		object.Flag{Name: "w", Long: "words", Help: "Count words"},
+		object.Flag{Name: "c", Long: "bytes", Help: "Count bytes"},
 	}
 	RegisterBuiltin("wc", &object.Builtin{
 		Fn:    wc,
@@ -40,7 +40,7 @@ func wc(scope object.Scope, args ...object.Object) (object.Operation, error) {
 		switch arg := args[i].(type) {
 		case *object.Flag:
 			switch arg.Name {
-			case "b":
+			case "c":
 				wc.IsBytes = true
 			case "w":
 				wc.IsWords = true
@@ -51,8 +51,11 @@ func wc(scope object.Scope, args ...object.Object) (object.Operation, error) {
 			}
 		}
 	}
+	if err := scope.Env.CheckRead(wc.args...); err != nil {
+		return nil, err
+	}
 	return func() object.Object {
-		err := wc.do(scope.Env.Streams.Stdout, scope.Env.Streams.Stdin)
+		err := wc.do(scope.Env.Files(), scope.Env.Streams.Stdout, scope.Env.Streams.Stdin)
 		if err != nil {
 			return object.NewError(err.Error())
 		}
@@ -61,7 +64,7 @@ func wc(scope object.Scope, args ...object.Object) (object.Operation, error) {
 }
 
 // Invoke actually performs the wc
-func (wc *Wc) do(stdout io.Writer, stdin io.Reader) error {
+func (wc *Wc) do(fs vfs.FileSystem, stdout io.Writer, stdin io.Reader) error {
 	if len(wc.args) > 0 {
 		//treat no args as all args
 		if !wc.IsWords && !wc.IsLines && !wc.IsBytes {
@@ -74,7 +77,7 @@ func (wc *Wc) do(stdout io.Writer, stdin io.Reader) error {
 			words := int64(0)
 			lines := int64(0)
 			//get byte count
-			file, err := os.Open(fileName)
+			file, err := fs.Open(fileName)
 			if err != nil {
 				return err
 			}
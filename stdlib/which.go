@@ -3,9 +3,11 @@ package stdlib
 import (
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 
 	"github.com/laher/smoosh/object"
@@ -13,7 +15,7 @@ import (
 
 func init() {
 	var opts = []object.Flag{
-		object.Flag{Name: "a", Help: "All"},
+		object.Flag{Name: "a", Long: "all", Help: "All"},
 	}
 	RegisterBuiltin("which", &object.Builtin{
 		Fn:    which,
@@ -56,11 +58,7 @@ type Which struct {
 
 // Exec actually performs the which
 func (which *Which) do(stdout io.Writer, stdin io.Reader) error {
-	path := os.Getenv("PATH")
-	if runtime.GOOS == "windows" {
-		path = ".;" + path
-	}
-	pl := filepath.SplitList(path)
+	pl := searchPath()
 	for _, arg := range which.args {
 		checkPathParts(arg, pl, which, stdout)
 	}
@@ -68,6 +66,44 @@ func (which *Which) do(stdout io.Writer, stdin io.Reader) error {
 
 }
 
+// searchPath returns the directories which are searched for commands
+func searchPath() []string {
+	path := os.Getenv("PATH")
+	if runtime.GOOS == "windows" {
+		path = ".;" + path
+	}
+	return filepath.SplitList(path)
+}
+
+// Executables returns the names of commands on the PATH which start with prefix, sorted
+func Executables(prefix string) []string {
+	seen := map[string]bool{}
+	names := []string{}
+	for _, dir := range searchPath() {
+		infos, err := ioutil.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, fi := range infos {
+			name := fi.Name()
+			if runtime.GOOS == "windows" {
+				if !strings.HasSuffix(name, ".exe") {
+					continue
+				}
+				name = strings.TrimSuffix(name, ".exe")
+			} else if fi.IsDir() || fi.Mode()&0111 == 0 {
+				continue
+			}
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 func checkPathParts(arg string, pathParts []string, which *Which, outPipe io.Writer) {
 	for _, pathPart := range pathParts {
 		fi, err := os.Stat(pathPart)
@@ -8,11 +8,13 @@ import (
 	"path/filepath"
 
 	"github.com/laher/smoosh/object"
+	"github.com/laher/smoosh/vfs"
 )
 
 func init() {
 	RegisterBuiltin("zip", &object.Builtin{
-		Fn: z,
+		Fn:       z,
+		Mutating: true,
 	})
 }
 
@@ -26,8 +28,14 @@ func z(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}
 	zipFilename := filenames[0]
 	itemsToArchive := filenames[1:]
+	if err := scope.Env.CheckWrite(zipFilename); err != nil {
+		return nil, err
+	}
+	if err := scope.Env.CheckRead(itemsToArchive...); err != nil {
+		return nil, err
+	}
 	return func() object.Object {
-		err := zipItems(zipFilename, itemsToArchive)
+		err := zipItems(scope.Env.Files(), zipFilename, itemsToArchive)
 		if err != nil {
 			return object.NewError(err.Error())
 		}
@@ -35,12 +43,12 @@ func z(scope object.Scope, args ...object.Object) (object.Operation, error) {
 	}, nil
 }
 
-func zipItems(zipFilename string, itemsToArchive []string) error {
-	_, err := os.Stat(zipFilename)
-	var zf *os.File
+func zipItems(fs vfs.FileSystem, zipFilename string, itemsToArchive []string) error {
+	_, err := fs.Stat(zipFilename)
+	var zf vfs.File
 	if err != nil {
 		if os.IsNotExist(err) {
-			zf, err = os.Create(zipFilename)
+			zf, err = vfs.Create(fs, zipFilename)
 			if err != nil {
 				return err
 			}
@@ -48,7 +56,7 @@ func zipItems(zipFilename string, itemsToArchive []string) error {
 			return err
 		}
 	} else {
-		zf, err = os.Create(zipFilename)
+		zf, err = vfs.Create(fs, zipFilename)
 		if err != nil {
 			return err
 		}
@@ -62,7 +70,7 @@ func zipItems(zipFilename string, itemsToArchive []string) error {
 	for _, itemS := range itemsToArchive {
 		//todo: relative/full path checking
 		item := archiveItem{itemS, itemS}
-		err = addFileToZIP(zw, item)
+		err = addFileToZIP(fs, zw, item)
 		if err != nil {
 			return err
 		}
@@ -77,9 +85,9 @@ type archiveItem struct {
 	archivePath    string
 }
 
-func addFileToZIP(zw *zip.Writer, item archiveItem) error {
+func addFileToZIP(fs vfs.FileSystem, zw *zip.Writer, item archiveItem) error {
 	//fmt.Printf("Adding %s\n", item.FileSystemPath)
-	binfo, err := os.Stat(item.fileSystemPath)
+	binfo, err := fs.Stat(item.fileSystemPath)
 	if err != nil {
 		return err
 	}
@@ -94,13 +102,12 @@ func addFileToZIP(zw *zip.Writer, item archiveItem) error {
 		if err != nil {
 			return err
 		}
-		file, err := os.Open(item.fileSystemPath)
+		fis, err := fs.ReadDir(item.fileSystemPath)
 		if err != nil {
 			return err
 		}
-		fis, err := file.Readdir(0)
 		for _, fi := range fis {
-			err = addFileToZIP(zw, archiveItem{filepath.Join(item.fileSystemPath, fi.Name()), filepath.Join(item.archivePath, fi.Name())})
+			err = addFileToZIP(fs, zw, archiveItem{filepath.Join(item.fileSystemPath, fi.Name()), filepath.Join(item.archivePath, fi.Name())})
 			if err != nil {
 				return err
 			}
@@ -116,7 +123,7 @@ func addFileToZIP(zw *zip.Writer, item archiveItem) error {
 		if err != nil {
 			return err
 		}
-		bf, err := os.Open(item.fileSystemPath)
+		bf, err := fs.Open(item.fileSystemPath)
 		if err != nil {
 			return err
 		}
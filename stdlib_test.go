@@ -7,6 +7,7 @@ import (
 	"testing"
 
 	"github.com/laher/smoosh/run"
+	"github.com/laher/smoosh/vfs"
 )
 
 func TestStdLibNonDestructive(t *testing.T) {
@@ -52,6 +53,51 @@ func TestStdLibNonDestructive(t *testing.T) {
 			input:  `ls("testdata/hello.txt")`,
 			expOut: "hello.txt \n",
 		},
+		{
+			name:   "named flag",
+			input:  `head(n: 2, "testdata/100.txt")`,
+			expOut: "1\n2\n",
+		},
+		{
+			name:   "long flag",
+			input:  `head(lines: 2, "testdata/100.txt")`,
+			expOut: "1\n2\n",
+		},
+		{
+			name:   "flag value from variable",
+			input:  `var n = 2; head(n: n, "testdata/100.txt")`,
+			expOut: "1\n2\n",
+		},
+		{
+			name:   "variable not shadowed by flag",
+			input:  `var n = "testdata/hello.txt"; cat(n)`,
+			expOut: "hello\n",
+		},
+		{
+			name:   "boolean named flag",
+			input:  `cat(number: false, "testdata/hello.txt")`,
+			expOut: "hello\n",
+		},
+		{
+			name:   "unknown named flag",
+			input:  `head(nope: 2, "testdata/100.txt")`,
+			expErr: true,
+		},
+		{
+			name:   "named flag type",
+			input:  `head(n: "2", "testdata/100.txt")`,
+			expErr: true,
+		},
+		{
+			name:   "variadic wrapper",
+			input:  `var e = fn(first, ...rest) { echo(first, ...rest) }; e("a", "b", "c")`,
+			expOut: "a b c\n",
+		},
+		{
+			name:   "default parameter",
+			input:  `var top = fn(path, lines = 1) { head(n(lines), path) }; top("testdata/100.txt")`,
+			expOut: "1\n",
+		},
 		{
 			name:   "echo|ls",
 			input:  `echo("testdata/hello.txt")|ls()`,
@@ -321,3 +367,78 @@ func TestStdLibDestructive(t *testing.T) {
 		}
 	*/
 }
+
+func TestStdLibMemFS(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		expOut string
+		check  func(fs vfs.FileSystem)
+	}{
+		{
+			name:   "cat",
+			input:  `cat("/data/hello.txt")`,
+			expOut: "hello\n",
+		},
+		{
+			name:   "ls",
+			input:  `ls("/data")`,
+			expOut: "hello.txt \n",
+		},
+		{
+			name:   "glob",
+			input:  `cat("/data/*.txt")`,
+			expOut: "hello\n",
+		},
+		{
+			name:  "cp;mv;rm",
+			input: `cp("/data/hello.txt", "/data/a.txt"); mv("/data/a.txt", "/data/b.txt"); rm("/data/hello.txt")`,
+			check: func(fs vfs.FileSystem) {
+				if _, err := fs.Stat("/data/hello.txt"); !os.IsNotExist(err) {
+					t.Errorf("hello.txt should not exist [%v]", err)
+				}
+				if _, err := fs.Stat("/data/a.txt"); !os.IsNotExist(err) {
+					t.Errorf("a.txt should not exist [%v]", err)
+				}
+				if _, err := fs.Stat("/data/b.txt"); err != nil {
+					t.Errorf("b.txt should exist [%v]", err)
+				}
+			},
+		},
+		{
+			name:   "w;head",
+			input:  `echo("1")|w("/data/1.txt"); head("/data/1.txt")`,
+			expOut: "1\n",
+		},
+		{
+			name:   "zip;unzip",
+			input:  `zip("/data/tmp.zip", "/data/hello.txt"); unzip(dir: "/out", "/data/tmp.zip"); cat("/out/data/hello.txt")`,
+			expOut: "hello\n",
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			fs := vfs.NewMem()
+			fs.Mkdir("/data", 0755)
+			f, _ := vfs.Create(fs, "/data/hello.txt")
+			f.Write([]byte("hello\n"))
+			f.Close()
+
+			r := run.NewRunner()
+			r.FS = fs
+			rbuf := bytes.NewBuffer([]byte(test.input))
+			wbuf := bytes.NewBuffer([]byte{})
+			ebuf := bytes.NewBuffer([]byte{})
+			if err := r.Run(rbuf, wbuf, ebuf); err != nil {
+				t.Errorf("Unexpected error: [%s]", err.Error())
+			}
+			if out := wbuf.String(); out != test.expOut {
+				t.Errorf("Unexpected output: [%s] (expected [%s])", out, test.expOut)
+			}
+			if test.check != nil {
+				test.check(fs)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+// Package term puts terminals into raw mode, for reading input a key at a time, and hands
+// them over to interactive programs.
+package term
+
+import "errors"
+
+// ErrUnsupported is returned on platforms without terminal support
+var ErrUnsupported = errors.New("terminal not supported on this platform")
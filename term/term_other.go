@@ -0,0 +1,31 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package term
+
+// State is a terminal's state, to be restored after raw mode
+type State struct{}
+
+// IsTerminal reports whether fd is a terminal
+func IsTerminal(fd int) bool {
+	return false
+}
+
+// MakeRaw puts a terminal into raw mode
+func MakeRaw(fd int) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+// Restore returns a terminal to a previous state
+func Restore(fd int, state *State) error {
+	return ErrUnsupported
+}
+
+// GetState returns a terminal's state
+func GetState(fd int) (*State, error) {
+	return nil, ErrUnsupported
+}
+
+// SetForeground makes a process group the terminal's foreground
+func SetForeground(fd, pgid int) error {
+	return ErrUnsupported
+}
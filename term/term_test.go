@@ -0,0 +1,25 @@
+package term
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestIsTerminal(t *testing.T) {
+	f, err := ioutil.TempFile("", "smoosh-term")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if IsTerminal(int(f.Fd())) {
+		t.Error("a file is not a terminal")
+	}
+	if _, err := MakeRaw(int(f.Fd())); err == nil {
+		t.Error("expected an error making a file raw")
+	}
+	if _, err := GetState(int(f.Fd())); err == nil {
+		t.Error("expected an error getting the state of a file")
+	}
+}
@@ -1,5 +1,10 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+)
+
 type TokenType string
 
 const (
@@ -30,6 +35,7 @@ const (
 	COMMA     = ","
 	SEMICOLON = ";"
 	COLON     = ":"
+	ELLIPSIS  = "..."
 
 	LPAREN   = "("
 	RPAREN   = ")"
@@ -62,7 +68,40 @@ const (
 type Token struct {
 	Type    TokenType
 	Literal string
+	File    string
 	Line    int
+	Column  int
+}
+
+// Pos returns the position of the start of the token
+func (t Token) Pos() Position {
+	return Position{File: t.File, Line: t.Line, Column: t.Column}
+}
+
+// Position is a location in the source. Line and Column start at 1, and Column counts runes.
+type Position struct {
+	File   string
+	Line   int
+	Column int
+}
+
+// IsValid reports whether the position is known
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// String formats the position as file:line:col, or line:col when there is no file
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.File != "" {
+			return p.File
+		}
+		return "-"
+	}
+	if p.File == "" {
+		return fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", p.File, p.Line, p.Column)
 }
 
 var keywords = map[string]TokenType{
@@ -78,6 +117,16 @@ var keywords = map[string]TokenType{
 	"macro":  MACRO,
 }
 
+// Keywords returns the language's keywords, sorted
+func Keywords() []string {
+	all := []string{}
+	for keyword := range keywords {
+		all = append(all, keyword)
+	}
+	sort.Strings(all)
+	return all
+}
+
 func LookupIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
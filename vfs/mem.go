@@ -0,0 +1,401 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errIsDir       = errors.New("is a directory")
+	errNotDir      = errors.New("not a directory")
+	errNotEmpty    = errors.New("directory not empty")
+	errReadOnly    = errors.New("file not opened for writing")
+	errWriteOnly   = errors.New("file not opened for reading")
+	errClosed      = os.ErrClosed
+	errInvalidSeek = errors.New("invalid seek")
+)
+
+// Mem is an in-memory FileSystem.
+// Relative names are treated as relative to the root directory.
+type Mem struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewMem creates an empty in-memory FileSystem
+func NewMem() *Mem {
+	return &Mem{
+		nodes: map[string]*memNode{
+			"/": {mode: os.ModeDir | 0755, modTime: time.Now()},
+		},
+	}
+}
+
+func memPath(name string) string {
+	return path.Clean("/" + filepath.ToSlash(name))
+}
+
+func pathError(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// checkParent verifies that the parent of p is an existing directory. The caller must hold the lock.
+func (m *Mem) checkParent(p string) error {
+	parent, ok := m.nodes[path.Dir(p)]
+	if !ok {
+		return os.ErrNotExist
+	}
+	if !parent.mode.IsDir() {
+		return errNotDir
+	}
+	return nil
+}
+
+// Open opens a file for reading
+func (m *Mem) Open(name string) (File, error) {
+	return m.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file with the given flags
+func (m *Mem) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[p]
+	if ok {
+		if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+			return nil, pathError("open", name, os.ErrExist)
+		}
+		if node.mode.IsDir() && isWritable(flag) {
+			return nil, pathError("open", name, errIsDir)
+		}
+		if flag&os.O_TRUNC != 0 {
+			node.data = nil
+			node.modTime = time.Now()
+		}
+	} else {
+		if flag&os.O_CREATE == 0 {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+		if err := m.checkParent(p); err != nil {
+			return nil, pathError("open", name, err)
+		}
+		node = &memNode{mode: perm.Perm(), modTime: time.Now()}
+		m.nodes[p] = node
+	}
+	return &memFile{fs: m, name: name, path: p, node: node, flag: flag}, nil
+}
+
+// Stat returns file info
+func (m *Mem) Stat(name string) (os.FileInfo, error) {
+	p := memPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, ok := m.nodes[p]
+	if !ok {
+		return nil, pathError("stat", name, os.ErrNotExist)
+	}
+	return node.info(p), nil
+}
+
+// ReadDir returns a directory's entries, sorted by name
+func (m *Mem) ReadDir(name string) ([]os.FileInfo, error) {
+	p := memPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, ok := m.nodes[p]
+	if !ok {
+		return nil, pathError("readdir", name, os.ErrNotExist)
+	}
+	if !node.mode.IsDir() {
+		return nil, pathError("readdir", name, errNotDir)
+	}
+	list := []os.FileInfo{}
+	for k, n := range m.nodes {
+		if k != "/" && path.Dir(k) == p {
+			list = append(list, n.info(k))
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// Mkdir creates a directory
+func (m *Mem) Mkdir(name string, perm os.FileMode) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.nodes[p]; ok {
+		return pathError("mkdir", name, os.ErrExist)
+	}
+	if err := m.checkParent(p); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	m.nodes[p] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+// MkdirAll creates a directory along with any necessary parents
+func (m *Mem) MkdirAll(name string, perm os.FileMode) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	dir := "/"
+	for _, part := range strings.Split(strings.TrimPrefix(p, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		dir = path.Join(dir, part)
+		node, ok := m.nodes[dir]
+		if !ok {
+			m.nodes[dir] = &memNode{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}
+			continue
+		}
+		if !node.mode.IsDir() {
+			return pathError("mkdir", name, errNotDir)
+		}
+	}
+	return nil
+}
+
+// Remove removes a file or empty directory
+func (m *Mem) Remove(name string) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[p]
+	if !ok || p == "/" {
+		return pathError("remove", name, os.ErrNotExist)
+	}
+	if node.mode.IsDir() && m.hasChildren(p) {
+		return pathError("remove", name, errNotEmpty)
+	}
+	delete(m.nodes, p)
+	return nil
+}
+
+// Rename moves a file or directory
+func (m *Mem) Rename(oldname, newname string) error {
+	src, dest := memPath(oldname), memPath(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[src]
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if src == dest {
+		return nil
+	}
+	if err := m.checkParent(dest); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if existing, ok := m.nodes[dest]; ok && existing.mode.IsDir() {
+		if !node.mode.IsDir() {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errIsDir}
+		}
+		if m.hasChildren(dest) {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errNotEmpty}
+		}
+	}
+	if strings.HasPrefix(dest, src+"/") {
+		// as for os.Rename, a directory can't be moved inside itself
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrInvalid}
+	}
+	// collect the children first, so that moved nodes aren't visited again
+	children := []string{}
+	for k := range m.nodes {
+		if strings.HasPrefix(k, src+"/") {
+			children = append(children, k)
+		}
+	}
+	for _, k := range children {
+		n := m.nodes[k]
+		delete(m.nodes, k)
+		m.nodes[dest+strings.TrimPrefix(k, src)] = n
+	}
+	delete(m.nodes, src)
+	m.nodes[dest] = node
+	return nil
+}
+
+// Chtimes changes the modification time
+func (m *Mem) Chtimes(name string, atime, mtime time.Time) error {
+	p := memPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[p]
+	if !ok {
+		return pathError("chtimes", name, os.ErrNotExist)
+	}
+	node.modTime = mtime
+	return nil
+}
+
+func (m *Mem) hasChildren(p string) bool {
+	for k := range m.nodes {
+		if k != "/" && path.Dir(k) == p {
+			return true
+		}
+	}
+	return false
+}
+
+func isWritable(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+func (n *memNode) info(p string) os.FileInfo {
+	return &memFileInfo{
+		name:    path.Base(p),
+		size:    int64(len(n.data)),
+		mode:    n.mode,
+		modTime: n.modTime,
+	}
+}
+
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *memFileInfo) Name() string       { return fi.name }
+func (fi *memFileInfo) Size() int64        { return fi.size }
+func (fi *memFileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *memFileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memFileInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memFileInfo) Sys() interface{}   { return nil }
+
+type memFile struct {
+	fs      *Mem
+	name    string
+	path    string
+	node    *memNode
+	flag    int
+	offset  int64
+	dirRead int
+	closed  bool
+}
+
+func (f *memFile) Name() string { return f.name }
+
+func (f *memFile) Read(b []byte) (int, error) {
+	n, err := f.ReadAt(b, f.offset)
+	f.offset += int64(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	if f.closed {
+		return 0, pathError("read", f.name, errClosed)
+	}
+	if f.flag&os.O_WRONLY != 0 {
+		return 0, pathError("read", f.name, errWriteOnly)
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if f.node.mode.IsDir() {
+		return 0, pathError("read", f.name, errIsDir)
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	if f.closed {
+		return 0, pathError("write", f.name, errClosed)
+	}
+	if !isWritable(f.flag) {
+		return 0, pathError("write", f.name, errReadOnly)
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(b))
+	if end > int64(len(f.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	copy(f.node.data[f.offset:], b)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(b), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, pathError("seek", f.name, errClosed)
+	}
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	default:
+		return 0, pathError("seek", f.name, errInvalidSeek)
+	}
+	if offset < 0 {
+		return 0, pathError("seek", f.name, errInvalidSeek)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return pathError("close", f.name, errClosed)
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(f.path), nil
+}
+
+func (f *memFile) Readdir(n int) ([]os.FileInfo, error) {
+	list, err := f.fs.ReadDir(f.path)
+	if err != nil {
+		return nil, err
+	}
+	list = list[f.dirRead:]
+	if n > 0 {
+		if len(list) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(list) {
+			list = list[:n]
+		}
+	}
+	f.dirRead += len(list)
+	return list, nil
+}
@@ -0,0 +1,104 @@
+package vfs
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func writeFile(t *testing.T, fs FileSystem, name, content string) {
+	f, err := Create(fs, name)
+	if err != nil {
+		t.Fatalf("create %s: %v", name, err)
+	}
+	defer f.Close()
+	if _, err := f.Write([]byte(content)); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, fs FileSystem, name string) string {
+	f, err := fs.Open(name)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	defer f.Close()
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+	return string(b)
+}
+
+func names(t *testing.T, fs FileSystem, dir string) []string {
+	list, err := fs.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("readdir %s: %v", dir, err)
+	}
+	ret := []string{}
+	for _, fi := range list {
+		ret = append(ret, fi.Name())
+	}
+	return ret
+}
+
+func TestMem(t *testing.T) {
+	fs := NewMem()
+	if err := fs.MkdirAll("a/b", 0755); err != nil {
+		t.Fatalf("mkdirall: %v", err)
+	}
+	writeFile(t, fs, "a/b/c.txt", "hello")
+	writeFile(t, fs, "/a/x.txt", "x")
+
+	if got := readFile(t, fs, "/a/b/c.txt"); got != "hello" {
+		t.Errorf("got %q, expected %q", got, "hello")
+	}
+	if got := names(t, fs, "a"); !reflect.DeepEqual(got, []string{"b", "x.txt"}) {
+		t.Errorf("unexpected entries %v", got)
+	}
+
+	f, err := fs.OpenFile("a/x.txt", os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("open for append: %v", err)
+	}
+	f.Write([]byte("yz"))
+	f.Close()
+	if got := readFile(t, fs, "a/x.txt"); got != "xyz" {
+		t.Errorf("got %q, expected %q", got, "xyz")
+	}
+
+	if _, err := fs.Open("a/nope.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error, got %v", err)
+	}
+	if _, err := Create(fs, "nope/c.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist error for missing parent, got %v", err)
+	}
+	if err := fs.Remove("a/b"); err == nil {
+		t.Errorf("expected error removing non-empty directory")
+	}
+
+	if err := fs.Rename("a/b", "a/d"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if got := readFile(t, fs, "a/d/c.txt"); got != "hello" {
+		t.Errorf("got %q after rename, expected %q", got, "hello")
+	}
+	if _, err := fs.Stat("a/b/c.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected renamed file to be gone, got %v", err)
+	}
+	if err := fs.Rename("a", "a/d/e"); err == nil {
+		t.Errorf("expected error renaming a directory inside itself")
+	}
+	if got := readFile(t, fs, "a/d/c.txt"); got != "hello" {
+		t.Errorf("got %q after failed rename, expected %q", got, "hello")
+	}
+
+	matches, err := Glob(fs, "a/*/*.txt")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if !reflect.DeepEqual(matches, []string{"a/d/c.txt"}) {
+		t.Errorf("unexpected glob matches %v", matches)
+	}
+}
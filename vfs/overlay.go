@@ -0,0 +1,416 @@
+package vfs
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Overlay is a copy-on-write FileSystem. Reads fall through to a read-only base,
+// while all changes are kept in memory, so the base is never modified.
+// Relative names are resolved against the process working directory.
+type Overlay struct {
+	base  FileSystem
+	upper *Mem
+	mu    sync.RWMutex
+	// whiteouts hides deleted base files, along with anything beneath them
+	whiteouts map[string]bool
+}
+
+// NewOverlay creates a copy-on-write layer over base
+func NewOverlay(base FileSystem) *Overlay {
+	return &Overlay{
+		base:      base,
+		upper:     NewMem(),
+		whiteouts: map[string]bool{},
+	}
+}
+
+func overlayKey(name string) string {
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return filepath.Clean(name)
+	}
+	return abs
+}
+
+func (o *Overlay) hidden(key string) bool {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	for {
+		if o.whiteouts[key] {
+			return true
+		}
+		parent := filepath.Dir(key)
+		if parent == key {
+			return false
+		}
+		key = parent
+	}
+}
+
+func (o *Overlay) inUpper(key string) bool {
+	_, err := o.upper.Stat(key)
+	return err == nil
+}
+
+// baseStat returns file info from the base, unless it has been deleted
+func (o *Overlay) baseStat(name, key string) (os.FileInfo, error) {
+	if o.hidden(key) {
+		return nil, pathError("stat", name, os.ErrNotExist)
+	}
+	return o.base.Stat(name)
+}
+
+// Stat returns file info
+func (o *Overlay) Stat(name string) (os.FileInfo, error) {
+	key := overlayKey(name)
+	if fi, err := o.upper.Stat(key); err == nil {
+		return fi, nil
+	}
+	return o.baseStat(name, key)
+}
+
+// Open opens a file for reading
+func (o *Overlay) Open(name string) (File, error) {
+	return o.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// OpenFile opens a file with the given flags. Base files are copied up before being opened for writing.
+func (o *Overlay) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	key := overlayKey(name)
+	fi, err := o.Stat(name)
+	if err == nil && fi.IsDir() {
+		if isWritable(flag) {
+			return nil, pathError("open", name, errIsDir)
+		}
+		return &overlayDir{fs: o, name: name, info: fi}, nil
+	}
+	if !isWritable(flag) && flag&os.O_CREATE == 0 {
+		if err != nil {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+		if o.inUpper(key) {
+			return o.upperFile(name, key, flag, perm)
+		}
+		return o.base.Open(name)
+	}
+	if err != nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, pathError("open", name, os.ErrNotExist)
+		}
+		if err := o.prepareParent(name, key); err != nil {
+			return nil, pathError("open", name, err)
+		}
+	} else if !o.inUpper(key) {
+		if err := o.copyUp(name, key, fi, flag&os.O_TRUNC != 0); err != nil {
+			return nil, err
+		}
+	}
+	return o.upperFile(name, key, flag, perm)
+}
+
+func (o *Overlay) upperFile(name, key string, flag int, perm os.FileMode) (File, error) {
+	f, err := o.upper.OpenFile(key, flag, perm)
+	if err != nil {
+		return nil, pathError("open", name, os.ErrNotExist)
+	}
+	f.(*memFile).name = name
+	return f, nil
+}
+
+// prepareParent checks that the parent directory exists, and creates it in the upper layer
+func (o *Overlay) prepareParent(name, key string) error {
+	fi, err := o.Stat(filepath.Dir(name))
+	if err != nil {
+		return os.ErrNotExist
+	}
+	if !fi.IsDir() {
+		return errNotDir
+	}
+	return o.upper.MkdirAll(filepath.Dir(key), 0755)
+}
+
+// copyUp copies a base file into the upper layer
+func (o *Overlay) copyUp(name, key string, fi os.FileInfo, truncate bool) error {
+	if err := o.upper.MkdirAll(filepath.Dir(key), 0755); err != nil {
+		return err
+	}
+	var data []byte
+	if !truncate {
+		f, err := o.base.Open(name)
+		if err != nil {
+			return err
+		}
+		data, err = ioutil.ReadAll(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+	f, err := o.upper.OpenFile(key, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(key, fi.ModTime(), fi.ModTime())
+}
+
+// ReadDir returns the merged entries of a directory, sorted by name
+func (o *Overlay) ReadDir(name string) ([]os.FileInfo, error) {
+	key := overlayKey(name)
+	fi, err := o.Stat(name)
+	if err != nil {
+		return nil, pathError("readdir", name, os.ErrNotExist)
+	}
+	if !fi.IsDir() {
+		return nil, pathError("readdir", name, errNotDir)
+	}
+	entries := map[string]os.FileInfo{}
+	if bfi, err := o.baseStat(name, key); err == nil && bfi.IsDir() {
+		list, err := o.base.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			if !o.hidden(filepath.Join(key, e.Name())) {
+				entries[e.Name()] = e
+			}
+		}
+	}
+	if o.inUpper(key) {
+		list, err := o.upper.ReadDir(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			entries[e.Name()] = e
+		}
+	}
+	list := make([]os.FileInfo, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// Mkdir creates a directory
+func (o *Overlay) Mkdir(name string, perm os.FileMode) error {
+	key := overlayKey(name)
+	if _, err := o.Stat(name); err == nil {
+		return pathError("mkdir", name, os.ErrExist)
+	}
+	if err := o.prepareParent(name, key); err != nil {
+		return pathError("mkdir", name, err)
+	}
+	return o.upper.Mkdir(key, perm)
+}
+
+// MkdirAll creates a directory along with any necessary parents
+func (o *Overlay) MkdirAll(name string, perm os.FileMode) error {
+	fi, err := o.Stat(name)
+	if err == nil {
+		if !fi.IsDir() {
+			return pathError("mkdir", name, errNotDir)
+		}
+		return nil
+	}
+	parent := filepath.Dir(name)
+	if parent != name {
+		if err := o.MkdirAll(parent, perm); err != nil {
+			return err
+		}
+	}
+	return o.Mkdir(name, perm)
+}
+
+// Remove removes a file or empty directory
+func (o *Overlay) Remove(name string) error {
+	key := overlayKey(name)
+	fi, err := o.Stat(name)
+	if err != nil {
+		return pathError("remove", name, os.ErrNotExist)
+	}
+	if fi.IsDir() {
+		list, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(list) > 0 {
+			return pathError("remove", name, errNotEmpty)
+		}
+	}
+	o.removeTree(name, key)
+	return nil
+}
+
+// removeTree removes key and its descendants from the upper layer, and hides any base file at key
+func (o *Overlay) removeTree(name, key string) {
+	o.upper.mu.Lock()
+	mkey := memPath(key)
+	for k := range o.upper.nodes {
+		if k == mkey || strings.HasPrefix(k, mkey+"/") {
+			delete(o.upper.nodes, k)
+		}
+	}
+	o.upper.mu.Unlock()
+	if _, err := o.baseStat(name, key); err == nil {
+		o.mu.Lock()
+		o.whiteouts[key] = true
+		o.mu.Unlock()
+	}
+}
+
+// Rename moves a file or directory, copying it into the upper layer
+func (o *Overlay) Rename(oldname, newname string) error {
+	oldKey, newKey := overlayKey(oldname), overlayKey(newname)
+	fi, err := o.Stat(oldname)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if oldKey == newKey {
+		return nil
+	}
+	if strings.HasPrefix(newKey, oldKey+string(filepath.Separator)) {
+		// as for os.Rename, a directory can't be moved inside itself
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrInvalid}
+	}
+	if err := o.prepareParent(newname, newKey); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if existing, err := o.Stat(newname); err == nil && existing.IsDir() {
+		if !fi.IsDir() {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errIsDir}
+		}
+		list, err := o.ReadDir(newname)
+		if err != nil {
+			return err
+		}
+		if len(list) > 0 {
+			return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: errNotEmpty}
+		}
+	}
+	if err := o.copyTree(oldname, newKey, fi); err != nil {
+		return err
+	}
+	o.removeTree(oldname, oldKey)
+	return nil
+}
+
+func (o *Overlay) copyTree(name, destKey string, fi os.FileInfo) error {
+	if fi.IsDir() {
+		if err := o.upper.MkdirAll(destKey, fi.Mode().Perm()); err != nil {
+			return err
+		}
+		list, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, e := range list {
+			if err := o.copyTree(filepath.Join(name, e.Name()), filepath.Join(destKey, e.Name()), e); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	src, err := o.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dest, err := o.upper.OpenFile(destKey, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dest, src); err != nil {
+		dest.Close()
+		return err
+	}
+	if err := dest.Close(); err != nil {
+		return err
+	}
+	return o.upper.Chtimes(destKey, fi.ModTime(), fi.ModTime())
+}
+
+// Chtimes changes the modification time, copying base files up first
+func (o *Overlay) Chtimes(name string, atime, mtime time.Time) error {
+	key := overlayKey(name)
+	fi, err := o.Stat(name)
+	if err != nil {
+		return pathError("chtimes", name, os.ErrNotExist)
+	}
+	if !o.inUpper(key) {
+		if fi.IsDir() {
+			err = o.upper.MkdirAll(key, fi.Mode().Perm())
+		} else {
+			err = o.copyUp(name, key, fi, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return o.upper.Chtimes(key, atime, mtime)
+}
+
+// overlayDir is an open directory, listing the merged entries of both layers
+type overlayDir struct {
+	fs      *Overlay
+	name    string
+	info    os.FileInfo
+	dirRead int
+}
+
+func (d *overlayDir) Name() string { return d.name }
+
+func (d *overlayDir) Stat() (os.FileInfo, error) { return d.info, nil }
+
+func (d *overlayDir) Close() error { return nil }
+
+func (d *overlayDir) Read(b []byte) (int, error) {
+	return 0, pathError("read", d.name, errIsDir)
+}
+
+func (d *overlayDir) ReadAt(b []byte, off int64) (int, error) {
+	return 0, pathError("read", d.name, errIsDir)
+}
+
+func (d *overlayDir) Write(b []byte) (int, error) {
+	return 0, pathError("write", d.name, errIsDir)
+}
+
+func (d *overlayDir) Seek(offset int64, whence int) (int64, error) {
+	return 0, pathError("seek", d.name, errIsDir)
+}
+
+func (d *overlayDir) Readdir(n int) ([]os.FileInfo, error) {
+	list, err := d.fs.ReadDir(d.name)
+	if err != nil {
+		return nil, err
+	}
+	if d.dirRead > len(list) {
+		d.dirRead = len(list)
+	}
+	list = list[d.dirRead:]
+	if n > 0 {
+		if len(list) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(list) {
+			list = list[:n]
+		}
+	}
+	d.dirRead += len(list)
+	return list, nil
+}
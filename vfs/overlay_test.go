@@ -0,0 +1,58 @@
+package vfs
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	base := NewMem()
+	base.MkdirAll("/dir", 0755)
+	writeFile(t, base, "/dir/a.txt", "a")
+	writeFile(t, base, "/dir/b.txt", "b")
+
+	fs := NewOverlay(base)
+	writeFile(t, fs, "/dir/a.txt", "changed")
+	writeFile(t, fs, "/dir/c.txt", "c")
+	if err := fs.Remove("/dir/b.txt"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+
+	if got := readFile(t, fs, "/dir/a.txt"); got != "changed" {
+		t.Errorf("got %q from overlay, expected %q", got, "changed")
+	}
+	if got := names(t, fs, "/dir"); !reflect.DeepEqual(got, []string{"a.txt", "c.txt"}) {
+		t.Errorf("unexpected overlay entries %v", got)
+	}
+	if _, err := fs.Stat("/dir/b.txt"); !os.IsNotExist(err) {
+		t.Errorf("expected removed file to be hidden, got %v", err)
+	}
+
+	// the base is untouched
+	if got := readFile(t, base, "/dir/a.txt"); got != "a" {
+		t.Errorf("got %q from base, expected %q", got, "a")
+	}
+	if got := names(t, base, "/dir"); !reflect.DeepEqual(got, []string{"a.txt", "b.txt"}) {
+		t.Errorf("unexpected base entries %v", got)
+	}
+
+	if err := fs.Rename("/dir", "/moved"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if got := names(t, fs, "/moved"); !reflect.DeepEqual(got, []string{"a.txt", "c.txt"}) {
+		t.Errorf("unexpected entries after rename %v", got)
+	}
+	if _, err := fs.Stat("/dir"); !os.IsNotExist(err) {
+		t.Errorf("expected renamed dir to be hidden, got %v", err)
+	}
+	if _, err := base.Stat("/dir/b.txt"); err != nil {
+		t.Errorf("base should be unchanged after rename: %v", err)
+	}
+	if err := fs.Rename("/moved", "/moved/sub"); err == nil {
+		t.Errorf("expected error renaming a directory inside itself")
+	}
+	if got := names(t, fs, "/moved"); !reflect.DeepEqual(got, []string{"a.txt", "c.txt"}) {
+		t.Errorf("unexpected entries after failed rename %v", got)
+	}
+}
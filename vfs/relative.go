@@ -0,0 +1,61 @@
+package vfs
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Relative resolves relative names against dir before passing them to fs.
+// Absolute names are passed through unchanged.
+func Relative(fs FileSystem, dir string) FileSystem {
+	return &relative{fs: fs, dir: dir}
+}
+
+type relative struct {
+	fs  FileSystem
+	dir string
+}
+
+func (r *relative) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(r.dir, name)
+}
+
+func (r *relative) Open(name string) (File, error) {
+	return r.fs.Open(r.path(name))
+}
+
+func (r *relative) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	return r.fs.OpenFile(r.path(name), flag, perm)
+}
+
+func (r *relative) Stat(name string) (os.FileInfo, error) {
+	return r.fs.Stat(r.path(name))
+}
+
+func (r *relative) ReadDir(name string) ([]os.FileInfo, error) {
+	return r.fs.ReadDir(r.path(name))
+}
+
+func (r *relative) Mkdir(name string, perm os.FileMode) error {
+	return r.fs.Mkdir(r.path(name), perm)
+}
+
+func (r *relative) MkdirAll(name string, perm os.FileMode) error {
+	return r.fs.MkdirAll(r.path(name), perm)
+}
+
+func (r *relative) Remove(name string) error {
+	return r.fs.Remove(r.path(name))
+}
+
+func (r *relative) Rename(oldname, newname string) error {
+	return r.fs.Rename(r.path(oldname), r.path(newname))
+}
+
+func (r *relative) Chtimes(name string, atime, mtime time.Time) error {
+	return r.fs.Chtimes(r.path(name), atime, mtime)
+}
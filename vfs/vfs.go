@@ -0,0 +1,177 @@
+/*
+Package vfs abstracts the filesystem used by smoosh builtins.
+
+Builtins access files through a FileSystem held by the environment, so that scripts can run against
+the OS, an in-memory filesystem (e.g. for hermetic tests), or a copy-on-write overlay (e.g. for dry-runs).
+*/
+package vfs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// File is an open file. *os.File implements File
+type File interface {
+	io.Reader
+	io.ReaderAt
+	io.Writer
+	io.Seeker
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	Readdir(n int) ([]os.FileInfo, error)
+}
+
+// FileSystem provides the file operations used by builtins. Method semantics follow package os.
+type FileSystem interface {
+	Open(name string) (File, error)
+	OpenFile(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	// ReadDir returns a directory's entries, sorted by name
+	ReadDir(name string) ([]os.FileInfo, error)
+	Mkdir(name string, perm os.FileMode) error
+	MkdirAll(name string, perm os.FileMode) error
+	Remove(name string) error
+	Rename(oldname, newname string) error
+	Chtimes(name string, atime, mtime time.Time) error
+}
+
+// Create creates or truncates the named file, like os.Create
+func Create(fs FileSystem, name string) (File, error) {
+	return fs.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0666)
+}
+
+// OS is the FileSystem of the operating system
+type OS struct{}
+
+// Open opens a file for reading
+func (OS) Open(name string) (File, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// OpenFile opens a file with the given flags
+func (OS) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Stat returns file info
+func (OS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+// ReadDir returns a directory's entries, sorted by name
+func (OS) ReadDir(name string) ([]os.FileInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	list, err := f.Readdir(-1)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// Mkdir creates a directory
+func (OS) Mkdir(name string, perm os.FileMode) error { return os.Mkdir(name, perm) }
+
+// MkdirAll creates a directory along with any necessary parents
+func (OS) MkdirAll(name string, perm os.FileMode) error { return os.MkdirAll(name, perm) }
+
+// Remove removes a file or empty directory
+func (OS) Remove(name string) error { return os.Remove(name) }
+
+// Rename moves a file
+func (OS) Rename(oldname, newname string) error { return os.Rename(oldname, newname) }
+
+// Chtimes changes access and modification times
+func (OS) Chtimes(name string, atime, mtime time.Time) error { return os.Chtimes(name, atime, mtime) }
+
+// Glob returns the names of all files matching pattern, like filepath.Glob
+func Glob(fs FileSystem, pattern string) ([]string, error) {
+	if _, ok := fs.(OS); ok {
+		return filepath.Glob(pattern)
+	}
+	// check pattern is well-formed
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	if !hasMeta(pattern) {
+		if _, err := fs.Stat(pattern); err != nil {
+			return nil, nil
+		}
+		return []string{pattern}, nil
+	}
+
+	dir, file := filepath.Split(pattern)
+	dir = cleanGlobPath(dir)
+	if !hasMeta(dir) {
+		return glob(fs, dir, file, nil)
+	}
+	// prevent infinite recursion
+	if dir == pattern {
+		return nil, filepath.ErrBadPattern
+	}
+	dirs, err := Glob(fs, dir)
+	if err != nil {
+		return nil, err
+	}
+	var matches []string
+	for _, d := range dirs {
+		matches, err = glob(fs, d, file, matches)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return matches, nil
+}
+
+func glob(fs FileSystem, dir, pattern string, matches []string) ([]string, error) {
+	fi, err := fs.Stat(dir)
+	if err != nil || !fi.IsDir() {
+		// ignore I/O errors, like filepath.Glob
+		return matches, nil
+	}
+	entries, err := fs.ReadDir(dir)
+	if err != nil {
+		return matches, nil
+	}
+	for _, e := range entries {
+		matched, err := filepath.Match(pattern, e.Name())
+		if err != nil {
+			return matches, err
+		}
+		if matched {
+			matches = append(matches, filepath.Join(dir, e.Name()))
+		}
+	}
+	return matches, nil
+}
+
+func cleanGlobPath(path string) string {
+	switch path {
+	case "":
+		return "."
+	case string(filepath.Separator):
+		return path
+	default:
+		return path[0 : len(path)-1] // chop off trailing separator
+	}
+}
+
+func hasMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[\`)
+}